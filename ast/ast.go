@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"magot/token"
+	"math/big"
 	"strings"
 )
 
@@ -89,6 +90,21 @@ func (i *IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+// BigIntegerLiteral is an integer literal out of the range of IntegerLiteral,
+// which evaluates to a BIGINT.
+type BigIntegerLiteral struct {
+	Token token.Token // token.INT
+	Value *big.Int
+}
+
+func (i *BigIntegerLiteral) expressionNode() {}
+
+func (i *BigIntegerLiteral) TokenLiteral() string { return i.Token.Literal }
+
+func (i *BigIntegerLiteral) String() string {
+	return i.Token.Literal
+}
+
 type StringLiteral struct {
 	Token token.Token // token.STRING
 	Value string
@@ -102,6 +118,57 @@ func (s *StringLiteral) String() string {
 	return s.Token.Literal
 }
 
+// InterpolatedString is a string holding ${expression} interpolations. Its
+// parts are the expressions and the text between them, as StringLiterals,
+// whose runs of dollar signs before a brace or an interpolation are written
+// back doubled.
+type InterpolatedString struct {
+	Token token.Token // token.INTERPOLATED
+	Parts []Expression
+}
+
+func (s *InterpolatedString) expressionNode() {}
+
+func (s *InterpolatedString) TokenLiteral() string { return s.Token.Literal }
+
+func (s *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for i, part := range s.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(escapeDollars(text.Value, i < len(s.Parts)-1))
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	return out.String()
+}
+
+// escapeDollars doubles the runs of dollar signs of text that come before a
+// brace, or at its end when an interpolation follows it.
+func escapeDollars(text string, interpolation bool) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		end := i
+		for end < len(text) && text[end] == '$' {
+			end++
+		}
+		if end == i {
+			out.WriteByte(text[i])
+			i++
+			continue
+		}
+		if end < len(text) && text[end] == '{' || end == len(text) && interpolation {
+			out.WriteString(text[i:end])
+		}
+		out.WriteString(text[i:end])
+		i = end
+	}
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // token.STRING
 	Elements []Expression
@@ -166,6 +233,28 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// YieldStatement hands a value over to the consumer of the generator whose
+// function it belongs to.
+type YieldStatement struct {
+	Token token.Token // token.YIELD
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode() {}
+
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+
+func (ys *YieldStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ys.TokenLiteral() + " ")
+	if ys.Value != nil {
+		out.WriteString(ys.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -197,6 +286,9 @@ func (pe *PrefixExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
+	if pe.Token.Type == token.SPAWN || pe.Token.Type == token.AWAIT {
+		out.WriteString(" ")
+	}
 	out.WriteString(pe.Right.String())
 	out.WriteString(")")
 	return out.String()
@@ -260,6 +352,31 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// ForExpression evaluates its body with the variable bound to each value of
+// an array or an iterator in turn.
+type ForExpression struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForExpression) expressionNode() {}
+
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fe.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
@@ -348,3 +465,208 @@ func (h *HashLiteral) String() string {
 	out.WriteString("}")
 	return out.String()
 }
+
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+	return out.String()
+}
+
+// SelectExpression waits until one of its cases can send or receive, and
+// takes its default case instead when it has one and none can.
+type SelectExpression struct {
+	Token   token.Token // the 'select' token
+	Cases   []*SelectCase
+	Default *BlockStatement
+}
+
+// SelectCase is a call to recv or send in a select expression, along with
+// the name the value received is bound to, if any, and the block evaluated
+// when the case is chosen.
+type SelectCase struct {
+	Token     token.Token // the 'let' token, or the first one of the call
+	Name      *Identifier
+	Operation *CallExpression
+	Body      *BlockStatement
+}
+
+func (se *SelectExpression) expressionNode() {}
+
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SelectExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("select { ")
+	for _, c := range se.Cases {
+		out.WriteString(c.String())
+		out.WriteString(" ")
+	}
+	if se.Default != nil {
+		out.WriteString("default { ")
+		out.WriteString(se.Default.String())
+		out.WriteString(" } ")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	if sc.Name != nil {
+		out.WriteString("let ")
+		out.WriteString(sc.Name.String())
+		out.WriteString(" = ")
+	}
+	out.WriteString(sc.Operation.String())
+	out.WriteString(" { ")
+	out.WriteString(sc.Body.String())
+	out.WriteString(" }")
+	return out.String()
+}
+
+// MatchExpression evaluates to the value of its first arm whose pattern the
+// value matches and whose guard, if any, holds.
+type MatchExpression struct {
+	Token token.Token // the 'match' token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm is a pattern of a match expression, along with its guard, if any,
+// and the expression the match evaluates to when the arm is chosen.
+type MatchArm struct {
+	Token   token.Token // the first token of the pattern
+	Pattern Pattern
+	Guard   Expression
+	Value   Expression
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	out.WriteString("match (")
+	out.WriteString(me.Value.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Value.String())
+	return out.String()
+}
+
+// Pattern is the pattern of a match arm, which the values matched against it
+// match or not.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// LiteralPattern is matched by the values equal to an integer, string or
+// boolean literal, the integers being possibly negated.
+type LiteralPattern struct {
+	Token token.Token // the first token of the literal
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode() {}
+
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+
+func (lp *LiteralPattern) String() string { return lp.Value.String() }
+
+// BindingPattern is matched by every value, which is bound to its name unless
+// the name is _.
+type BindingPattern struct {
+	Token token.Token // the token.IDENT token
+	Name  *Identifier
+}
+
+func (bp *BindingPattern) patternNode() {}
+
+func (bp *BindingPattern) TokenLiteral() string { return bp.Token.Literal }
+
+func (bp *BindingPattern) String() string { return bp.Name.String() }
+
+// ArrayPattern is matched by the arrays whose elements match its patterns in
+// turn. Without a rest name, the arrays must have as many elements as it has
+// patterns; with one, the elements left over are bound to it as an array.
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []Pattern
+	Rest     *Identifier
+}
+
+func (ap *ArrayPattern) patternNode() {}
+
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern is matched by the hashes holding each of its keys, with a value
+// matching the pattern of the key. The hashes may hold other keys.
+type HashPattern struct {
+	Token  token.Token // the '{' token
+	Keys   []*LiteralPattern
+	Values []Pattern
+}
+
+func (hp *HashPattern) patternNode() {}
+
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+":"+hp.Values[i].String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,179 @@
+package ast
+
+import "math/big"
+
+// Copy returns a deep copy of node, so that rewriting it with Modify leaves the
+// original tree untouched.
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: copyStatements(n.Statements)}
+	case *LetStatement:
+		return &LetStatement{Token: n.Token, Name: copyIdentifier(n.Name), Value: copyExpression(n.Value)}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: n.Token, ReturnValue: copyExpression(n.ReturnValue)}
+	case *YieldStatement:
+		return &YieldStatement{Token: n.Token, Value: copyExpression(n.Value)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: n.Token, Expression: copyExpression(n.Expression)}
+	case *BlockStatement:
+		return copyBlock(n)
+	case *Identifier:
+		return copyIdentifier(n)
+	case *IntegerLiteral:
+		return &IntegerLiteral{Token: n.Token, Value: n.Value}
+	case *BigIntegerLiteral:
+		return &BigIntegerLiteral{Token: n.Token, Value: new(big.Int).Set(n.Value)}
+	case *StringLiteral:
+		return &StringLiteral{Token: n.Token, Value: n.Value}
+	case *Boolean:
+		return &Boolean{Token: n.Token, Value: n.Value}
+	case *PrefixExpression:
+		return &PrefixExpression{Token: n.Token, Operator: n.Operator, Right: copyExpression(n.Right)}
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    n.Token,
+			Left:     copyExpression(n.Left),
+			Operator: n.Operator,
+			Right:    copyExpression(n.Right),
+		}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       n.Token,
+			Condition:   copyExpression(n.Condition),
+			Consequence: copyBlock(n.Consequence),
+			Alternative: copyBlock(n.Alternative),
+		}
+	case *FunctionLiteral:
+		return &FunctionLiteral{Token: n.Token, Parameters: copyIdentifiers(n.Parameters), Body: copyBlock(n.Body)}
+	case *MacroLiteral:
+		return &MacroLiteral{Token: n.Token, Parameters: copyIdentifiers(n.Parameters), Body: copyBlock(n.Body)}
+	case *CallExpression:
+		return &CallExpression{Token: n.Token, Function: copyExpression(n.Function), Arguments: copyExpressions(n.Arguments)}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: n.Token, Elements: copyExpressions(n.Elements)}
+	case *InterpolatedString:
+		return &InterpolatedString{Token: n.Token, Parts: copyExpressions(n.Parts)}
+	case *ForExpression:
+		return &ForExpression{
+			Token:    n.Token,
+			Variable: copyIdentifier(n.Variable),
+			Iterable: copyExpression(n.Iterable),
+			Body:     copyBlock(n.Body),
+		}
+	case *SelectExpression:
+		cases := make([]*SelectCase, len(n.Cases))
+		for i, c := range n.Cases {
+			cases[i] = &SelectCase{Token: c.Token, Name: copyIdentifier(c.Name), Body: copyBlock(c.Body)}
+			if c.Operation != nil {
+				cases[i].Operation, _ = Copy(c.Operation).(*CallExpression)
+			}
+		}
+		return &SelectExpression{Token: n.Token, Cases: cases, Default: copyBlock(n.Default)}
+	case *MatchExpression:
+		arms := make([]*MatchArm, len(n.Arms))
+		for i, arm := range n.Arms {
+			arms[i] = &MatchArm{
+				Token:   arm.Token,
+				Pattern: copyPattern(arm.Pattern),
+				Guard:   copyExpression(arm.Guard),
+				Value:   copyExpression(arm.Value),
+			}
+		}
+		return &MatchExpression{Token: n.Token, Value: copyExpression(n.Value), Arms: arms}
+	case *LiteralPattern:
+		return &LiteralPattern{Token: n.Token, Value: copyExpression(n.Value)}
+	case *BindingPattern:
+		return &BindingPattern{Token: n.Token, Name: copyIdentifier(n.Name)}
+	case *ArrayPattern:
+		elements := make([]Pattern, len(n.Elements))
+		for i, el := range n.Elements {
+			elements[i] = copyPattern(el)
+		}
+		return &ArrayPattern{Token: n.Token, Elements: elements, Rest: copyIdentifier(n.Rest)}
+	case *HashPattern:
+		keys := make([]*LiteralPattern, len(n.Keys))
+		values := make([]Pattern, len(n.Values))
+		for i, key := range n.Keys {
+			keys[i], _ = Copy(key).(*LiteralPattern)
+		}
+		for i, value := range n.Values {
+			values[i] = copyPattern(value)
+		}
+		return &HashPattern{Token: n.Token, Keys: keys, Values: values}
+	case *IndexExpression:
+		return &IndexExpression{Token: n.Token, Left: copyExpression(n.Left), Index: copyExpression(n.Index)}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression)
+		for key, value := range n.Pairs {
+			pairs[copyExpression(key)] = copyExpression(value)
+		}
+		return &HashLiteral{Token: n.Token, Pairs: pairs}
+	}
+	return node
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	copied, _ := Copy(exp).(Expression)
+	return copied
+}
+
+func copyPattern(pattern Pattern) Pattern {
+	if pattern == nil {
+		return nil
+	}
+	copied, _ := Copy(pattern).(Pattern)
+	return copied
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return &Identifier{Token: ident.Token, Value: ident.Value}
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: copyStatements(block.Statements)}
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	copied := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		if stmt != nil {
+			copied[i], _ = Copy(stmt).(Statement)
+		}
+	}
+	return copied
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	copied := make([]Expression, len(exps))
+	for i, exp := range exps {
+		copied[i] = copyExpression(exp)
+	}
+	return copied
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	copied := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		copied[i] = copyIdentifier(ident)
+	}
+	return copied
+}
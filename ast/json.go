@@ -0,0 +1,535 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"magot/token"
+	"reflect"
+)
+
+// Nodes are encoded as JSON objects holding a "type" discriminator, the
+// node's token, the "line" and "column" where the node starts in the source,
+// and one member per child or value of the node.
+
+type jsonObject map[string]interface{}
+
+type jsonPair struct {
+	Key   interface{} `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type jsonSelectCase struct {
+	Token     token.Token `json:"token"`
+	Name      interface{} `json:"name"`
+	Operation interface{} `json:"operation"`
+	Body      interface{} `json:"body"`
+}
+
+type jsonMatchArm struct {
+	Token   token.Token `json:"token"`
+	Pattern interface{} `json:"pattern"`
+	Guard   interface{} `json:"guard"`
+	Value   interface{} `json:"value"`
+}
+
+// Encode returns the JSON encoding of node.
+func Encode(node Node) ([]byte, error) {
+	return json.Marshal(encode(node))
+}
+
+// EncodeIndent is like Encode but indents the output.
+func EncodeIndent(node Node, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(encode(node), prefix, indent)
+}
+
+func (p *Program) MarshalJSON() ([]byte, error) {
+	return Encode(p)
+}
+
+func (p *Program) UnmarshalJSON(data []byte) error {
+	program, err := DecodeProgram(data)
+	if err != nil {
+		return err
+	}
+	*p = *program
+	return nil
+}
+
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func encode(node Node) interface{} {
+	if isNil(node) {
+		return nil
+	}
+
+	start := StartToken(node)
+	obj := jsonObject{"line": start.Line, "column": start.Column}
+	switch n := node.(type) {
+	case *Program:
+		obj["statements"] = encodeStatements(n.Statements)
+	case *LetStatement:
+		obj["token"] = n.Token
+		obj["name"] = encode(n.Name)
+		obj["value"] = encode(n.Value)
+	case *ReturnStatement:
+		obj["token"] = n.Token
+		obj["returnValue"] = encode(n.ReturnValue)
+	case *YieldStatement:
+		obj["token"] = n.Token
+		obj["value"] = encode(n.Value)
+	case *ExpressionStatement:
+		obj["token"] = n.Token
+		obj["expression"] = encode(n.Expression)
+	case *BlockStatement:
+		obj["token"] = n.Token
+		obj["statements"] = encodeStatements(n.Statements)
+	case *Identifier:
+		obj["token"] = n.Token
+		obj["value"] = n.Value
+	case *IntegerLiteral:
+		obj["token"] = n.Token
+		obj["value"] = n.Value
+	case *BigIntegerLiteral:
+		obj["token"] = n.Token
+		obj["value"] = n.Value
+	case *StringLiteral:
+		obj["token"] = n.Token
+		obj["value"] = n.Value
+	case *Boolean:
+		obj["token"] = n.Token
+		obj["value"] = n.Value
+	case *PrefixExpression:
+		obj["token"] = n.Token
+		obj["operator"] = n.Operator
+		obj["right"] = encode(n.Right)
+	case *InfixExpression:
+		obj["token"] = n.Token
+		obj["left"] = encode(n.Left)
+		obj["operator"] = n.Operator
+		obj["right"] = encode(n.Right)
+	case *IfExpression:
+		obj["token"] = n.Token
+		obj["condition"] = encode(n.Condition)
+		obj["consequence"] = encode(n.Consequence)
+		obj["alternative"] = encode(n.Alternative)
+	case *FunctionLiteral:
+		obj["token"] = n.Token
+		obj["parameters"] = encodeIdentifiers(n.Parameters)
+		obj["body"] = encode(n.Body)
+	case *MacroLiteral:
+		obj["token"] = n.Token
+		obj["parameters"] = encodeIdentifiers(n.Parameters)
+		obj["body"] = encode(n.Body)
+	case *CallExpression:
+		obj["token"] = n.Token
+		obj["function"] = encode(n.Function)
+		obj["arguments"] = encodeExpressions(n.Arguments)
+	case *ArrayLiteral:
+		obj["token"] = n.Token
+		obj["elements"] = encodeExpressions(n.Elements)
+	case *InterpolatedString:
+		obj["token"] = n.Token
+		obj["parts"] = encodeExpressions(n.Parts)
+	case *IndexExpression:
+		obj["token"] = n.Token
+		obj["left"] = encode(n.Left)
+		obj["index"] = encode(n.Index)
+	case *HashLiteral:
+		pairs := []jsonPair{}
+		for _, key := range n.Keys() {
+			pairs = append(pairs, jsonPair{Key: encode(key), Value: encode(n.Pairs[key])})
+		}
+		obj["token"] = n.Token
+		obj["pairs"] = pairs
+	case *ForExpression:
+		obj["token"] = n.Token
+		obj["variable"] = encode(n.Variable)
+		obj["iterable"] = encode(n.Iterable)
+		obj["body"] = encode(n.Body)
+	case *SelectExpression:
+		cases := []jsonSelectCase{}
+		for _, c := range n.Cases {
+			cases = append(cases, jsonSelectCase{
+				Token:     c.Token,
+				Name:      encode(c.Name),
+				Operation: encode(c.Operation),
+				Body:      encode(c.Body),
+			})
+		}
+		obj["token"] = n.Token
+		obj["cases"] = cases
+		obj["default"] = encode(n.Default)
+	case *MatchExpression:
+		arms := []jsonMatchArm{}
+		for _, arm := range n.Arms {
+			arms = append(arms, jsonMatchArm{
+				Token:   arm.Token,
+				Pattern: encode(arm.Pattern),
+				Guard:   encode(arm.Guard),
+				Value:   encode(arm.Value),
+			})
+		}
+		obj["token"] = n.Token
+		obj["value"] = encode(n.Value)
+		obj["arms"] = arms
+	case *LiteralPattern:
+		obj["token"] = n.Token
+		obj["value"] = encode(n.Value)
+	case *BindingPattern:
+		obj["token"] = n.Token
+		obj["name"] = encode(n.Name)
+	case *ArrayPattern:
+		elements := []interface{}{}
+		for _, el := range n.Elements {
+			elements = append(elements, encode(el))
+		}
+		obj["token"] = n.Token
+		obj["elements"] = elements
+		obj["rest"] = encode(n.Rest)
+	case *HashPattern:
+		pairs := []jsonPair{}
+		for i, key := range n.Keys {
+			pairs = append(pairs, jsonPair{Key: encode(key), Value: encode(n.Values[i])})
+		}
+		obj["token"] = n.Token
+		obj["pairs"] = pairs
+	}
+	obj["type"] = reflect.TypeOf(node).Elem().Name()
+	return obj
+}
+
+func encodeStatements(stmts []Statement) []interface{} {
+	encoded := []interface{}{}
+	for _, stmt := range stmts {
+		encoded = append(encoded, encode(stmt))
+	}
+	return encoded
+}
+
+func encodeExpressions(exps []Expression) []interface{} {
+	encoded := []interface{}{}
+	for _, exp := range exps {
+		encoded = append(encoded, encode(exp))
+	}
+	return encoded
+}
+
+func encodeIdentifiers(idents []*Identifier) []interface{} {
+	encoded := []interface{}{}
+	for _, ident := range idents {
+		encoded = append(encoded, encode(ident))
+	}
+	return encoded
+}
+
+// Decode returns the node encoded in data by Encode.
+func Decode(data []byte) (Node, error) {
+	d := &decoder{}
+	node := d.node(data)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+// DecodeProgram is like Decode for data holding an encoded Program.
+func DecodeProgram(data []byte) (*Program, error) {
+	node, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("expected a Program, got %T", node)
+	}
+	return program, nil
+}
+
+// decoder keeps the first error met, the decoding methods returning zero
+// values once it is set.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (d *decoder) unmarshal(data json.RawMessage, v interface{}) {
+	if d.err != nil || len(data) == 0 {
+		return
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) node(data json.RawMessage) Node {
+	if d.err != nil || len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	d.unmarshal(data, &fields)
+	var typ string
+	d.unmarshal(fields["type"], &typ)
+	var tok token.Token
+	d.unmarshal(fields["token"], &tok)
+	if d.err != nil {
+		return nil
+	}
+
+	switch typ {
+	case "Program":
+		return &Program{Statements: d.statements(fields["statements"])}
+	case "LetStatement":
+		return &LetStatement{Token: tok, Name: d.identifier(fields["name"]), Value: d.expression(fields["value"])}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: tok, ReturnValue: d.expression(fields["returnValue"])}
+	case "YieldStatement":
+		return &YieldStatement{Token: tok, Value: d.expression(fields["value"])}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: tok, Expression: d.expression(fields["expression"])}
+	case "BlockStatement":
+		return &BlockStatement{Token: tok, Statements: d.statements(fields["statements"])}
+	case "Identifier":
+		ident := &Identifier{Token: tok}
+		d.unmarshal(fields["value"], &ident.Value)
+		return ident
+	case "IntegerLiteral":
+		integer := &IntegerLiteral{Token: tok}
+		d.unmarshal(fields["value"], &integer.Value)
+		return integer
+	case "BigIntegerLiteral":
+		integer := &BigIntegerLiteral{Token: tok}
+		d.unmarshal(fields["value"], &integer.Value)
+		return integer
+	case "StringLiteral":
+		str := &StringLiteral{Token: tok}
+		d.unmarshal(fields["value"], &str.Value)
+		return str
+	case "Boolean":
+		boolean := &Boolean{Token: tok}
+		d.unmarshal(fields["value"], &boolean.Value)
+		return boolean
+	case "PrefixExpression":
+		prefix := &PrefixExpression{Token: tok, Right: d.expression(fields["right"])}
+		d.unmarshal(fields["operator"], &prefix.Operator)
+		return prefix
+	case "InfixExpression":
+		infix := &InfixExpression{Token: tok, Left: d.expression(fields["left"]), Right: d.expression(fields["right"])}
+		d.unmarshal(fields["operator"], &infix.Operator)
+		return infix
+	case "IfExpression":
+		return &IfExpression{
+			Token:       tok,
+			Condition:   d.expression(fields["condition"]),
+			Consequence: d.block(fields["consequence"]),
+			Alternative: d.block(fields["alternative"]),
+		}
+	case "FunctionLiteral":
+		return &FunctionLiteral{Token: tok, Parameters: d.identifiers(fields["parameters"]), Body: d.block(fields["body"])}
+	case "MacroLiteral":
+		return &MacroLiteral{Token: tok, Parameters: d.identifiers(fields["parameters"]), Body: d.block(fields["body"])}
+	case "CallExpression":
+		return &CallExpression{Token: tok, Function: d.expression(fields["function"]), Arguments: d.expressions(fields["arguments"])}
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: tok, Elements: d.expressions(fields["elements"])}
+	case "InterpolatedString":
+		return &InterpolatedString{Token: tok, Parts: d.expressions(fields["parts"])}
+	case "IndexExpression":
+		return &IndexExpression{Token: tok, Left: d.expression(fields["left"]), Index: d.expression(fields["index"])}
+	case "HashLiteral":
+		var pairs []struct {
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		d.unmarshal(fields["pairs"], &pairs)
+		hash := &HashLiteral{Token: tok, Pairs: make(map[Expression]Expression)}
+		for _, pair := range pairs {
+			if key := d.expression(pair.Key); key != nil {
+				hash.Pairs[key] = d.expression(pair.Value)
+			}
+		}
+		return hash
+	case "ForExpression":
+		return &ForExpression{
+			Token:    tok,
+			Variable: d.identifier(fields["variable"]),
+			Iterable: d.expression(fields["iterable"]),
+			Body:     d.block(fields["body"]),
+		}
+	case "SelectExpression":
+		var cases []struct {
+			Token     token.Token     `json:"token"`
+			Name      json.RawMessage `json:"name"`
+			Operation json.RawMessage `json:"operation"`
+			Body      json.RawMessage `json:"body"`
+		}
+		d.unmarshal(fields["cases"], &cases)
+		sel := &SelectExpression{Token: tok, Default: d.block(fields["default"])}
+		for _, c := range cases {
+			sel.Cases = append(sel.Cases, &SelectCase{
+				Token:     c.Token,
+				Name:      d.identifier(c.Name),
+				Operation: d.call(c.Operation),
+				Body:      d.block(c.Body),
+			})
+		}
+		return sel
+	case "MatchExpression":
+		var arms []struct {
+			Token   token.Token     `json:"token"`
+			Pattern json.RawMessage `json:"pattern"`
+			Guard   json.RawMessage `json:"guard"`
+			Value   json.RawMessage `json:"value"`
+		}
+		d.unmarshal(fields["arms"], &arms)
+		match := &MatchExpression{Token: tok, Value: d.expression(fields["value"])}
+		for _, arm := range arms {
+			match.Arms = append(match.Arms, &MatchArm{
+				Token:   arm.Token,
+				Pattern: d.pattern(arm.Pattern),
+				Guard:   d.expression(arm.Guard),
+				Value:   d.expression(arm.Value),
+			})
+		}
+		return match
+	case "LiteralPattern":
+		return &LiteralPattern{Token: tok, Value: d.expression(fields["value"])}
+	case "BindingPattern":
+		return &BindingPattern{Token: tok, Name: d.identifier(fields["name"])}
+	case "ArrayPattern":
+		array := &ArrayPattern{Token: tok, Elements: []Pattern{}, Rest: d.identifier(fields["rest"])}
+		for _, item := range d.list(fields["elements"]) {
+			array.Elements = append(array.Elements, d.pattern(item))
+		}
+		return array
+	case "HashPattern":
+		var pairs []struct {
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		d.unmarshal(fields["pairs"], &pairs)
+		hash := &HashPattern{Token: tok}
+		for _, pair := range pairs {
+			key, _ := d.pattern(pair.Key).(*LiteralPattern)
+			if key == nil {
+				d.fail("expected a LiteralPattern as a hash pattern key")
+				return nil
+			}
+			hash.Keys = append(hash.Keys, key)
+			hash.Values = append(hash.Values, d.pattern(pair.Value))
+		}
+		return hash
+	}
+	d.fail("unknown node type %q", typ)
+	return nil
+}
+
+func (d *decoder) expression(data json.RawMessage) Expression {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		d.fail("expected an expression, got %T", node)
+	}
+	return exp
+}
+
+func (d *decoder) identifier(data json.RawMessage) *Identifier {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		d.fail("expected an Identifier, got %T", node)
+	}
+	return ident
+}
+
+func (d *decoder) block(data json.RawMessage) *BlockStatement {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail("expected a BlockStatement, got %T", node)
+	}
+	return block
+}
+
+func (d *decoder) call(data json.RawMessage) *CallExpression {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	call, ok := node.(*CallExpression)
+	if !ok {
+		d.fail("expected a CallExpression, got %T", node)
+	}
+	return call
+}
+
+func (d *decoder) pattern(data json.RawMessage) Pattern {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	pattern, ok := node.(Pattern)
+	if !ok {
+		d.fail("expected a pattern, got %T", node)
+	}
+	return pattern
+}
+
+func (d *decoder) list(data json.RawMessage) []json.RawMessage {
+	var list []json.RawMessage
+	d.unmarshal(data, &list)
+	return list
+}
+
+func (d *decoder) statements(data json.RawMessage) []Statement {
+	stmts := []Statement{}
+	for _, item := range d.list(data) {
+		node := d.node(item)
+		if node == nil {
+			continue
+		}
+		stmt, ok := node.(Statement)
+		if !ok {
+			d.fail("expected a statement, got %T", node)
+			return nil
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (d *decoder) expressions(data json.RawMessage) []Expression {
+	exps := []Expression{}
+	for _, item := range d.list(data) {
+		exps = append(exps, d.expression(item))
+	}
+	return exps
+}
+
+func (d *decoder) identifiers(data json.RawMessage) []*Identifier {
+	idents := []*Identifier{}
+	for _, item := range d.list(data) {
+		idents = append(idents, d.identifier(item))
+	}
+	return idents
+}
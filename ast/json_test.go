@@ -0,0 +1,142 @@
+package ast
+
+import (
+	"encoding/json"
+	"magot/token"
+	"math/big"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let", Line: 1, Column: 1},
+			Name:  ident("f"),
+			Value: &FunctionLiteral{
+				Token:      token.Token{Type: token.FUNCTION, Literal: "fn", Line: 1, Column: 9},
+				Parameters: []*Identifier{ident("x")},
+				Body: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: &IfExpression{
+						Condition: &Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+						Consequence: &BlockStatement{Statements: []Statement{
+							&ExpressionStatement{Expression: &IndexExpression{
+								Left:  &ArrayLiteral{Elements: []Expression{ident("x")}},
+								Index: integer(0),
+							}},
+						}},
+						Alternative: &BlockStatement{Statements: []Statement{
+							&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{
+								&StringLiteral{Token: token.Token{Type: token.STRING, Literal: "k"}, Value: "k"}: &PrefixExpression{Operator: "-", Right: ident("x")},
+							}}},
+						}},
+					}},
+				}},
+			},
+		},
+		&LetStatement{
+			Name: ident("m"),
+			Value: &MacroLiteral{
+				Parameters: []*Identifier{ident("a")},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("a")}}},
+			},
+		},
+		&ExpressionStatement{Expression: &SelectExpression{
+			Cases: []*SelectCase{
+				{
+					Name:      ident("v"),
+					Operation: &CallExpression{Function: ident("recv"), Arguments: []Expression{ident("c")}},
+					Body:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("v")}}},
+				},
+				{
+					Operation: &CallExpression{Function: ident("send"), Arguments: []Expression{ident("c"), integer(1)}},
+					Body:      &BlockStatement{},
+				},
+			},
+			Default: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: integer(0)}}},
+		}},
+		&ExpressionStatement{Expression: &MatchExpression{
+			Value: ident("v"),
+			Arms: []*MatchArm{
+				{
+					Pattern: &ArrayPattern{
+						Elements: []Pattern{&LiteralPattern{Value: integer(1)}, &BindingPattern{Name: ident("x")}},
+						Rest:     ident("xs"),
+					},
+					Guard: &InfixExpression{Left: ident("x"), Operator: ">", Right: integer(0)},
+					Value: ident("xs"),
+				},
+				{
+					Pattern: &HashPattern{
+						Keys:   []*LiteralPattern{{Value: integer(2)}},
+						Values: []Pattern{&BindingPattern{Name: ident("y")}},
+					},
+					Value: ident("y"),
+				},
+			},
+		}},
+		&ReturnStatement{ReturnValue: &CallExpression{
+			Function: ident("f"),
+			Arguments: []Expression{
+				&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+				&BigIntegerLiteral{Token: token.Token{Type: token.INT, Literal: "18446744073709551616"}, Value: new(big.Int).Lsh(big.NewInt(1), 64)},
+			},
+		}},
+	}}
+
+	encoded, err := Encode(program)
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err)
+	}
+	decoded, err := DecodeProgram(encoded)
+	if err != nil {
+		t.Fatalf("DecodeProgram failed: %s", err)
+	}
+	if decoded.String() != program.String() {
+		t.Errorf("wrong decoded program. expected=%q, got=%q", program.String(), decoded.String())
+	}
+	reencoded, err := Encode(decoded)
+	if err != nil {
+		t.Fatalf("Encode failed: %s", err)
+	}
+	if string(reencoded) != string(encoded) {
+		t.Errorf("round trip changed the encoding.\nexpected=%s\ngot=%s", encoded, reencoded)
+	}
+
+	var generic struct {
+		Type       string `json:"type"`
+		Statements []struct {
+			Type  string      `json:"type"`
+			Line  int         `json:"line"`
+			Token token.Token `json:"token"`
+		} `json:"statements"`
+	}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if generic.Type != "Program" || len(generic.Statements) != 5 {
+		t.Fatalf("wrong encoding: %s", encoded)
+	}
+	first := generic.Statements[0]
+	if first.Type != "LetStatement" || first.Line != 1 || first.Token.Literal != "let" {
+		t.Errorf("wrong encoding of the let statement: %+v", first)
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"type": "Nope"}`, `unknown node type "Nope"`},
+		{`{"type": "LetStatement", "name": {"type": "IntegerLiteral", "value": 1}}`, "expected an Identifier, got *ast.IntegerLiteral"},
+		{`{"type": "Program", "statements": [{"type": "Identifier", "value": "x"}]}`, "expected a statement, got *ast.Identifier"},
+		{`{"type": "ArrayPattern", "elements": [{"type": "Identifier", "value": "x"}]}`, "expected a pattern, got *ast.Identifier"},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
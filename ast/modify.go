@@ -0,0 +1,186 @@
+package ast
+
+import "fmt"
+
+// ModifierFunc returns the node that replaces the given one.
+type ModifierFunc func(Node) Node
+
+// Modify rewrites an AST bottom-up: the children of node are modified first
+// and the result of calling modifier on node itself is returned. Parent nodes
+// are updated in place. Identifiers that name bindings, such as let names and
+// function parameters, are not passed to modifier, nor are the patterns of
+// match arms. Modify panics when modifier replaces a node with one that
+// cannot take its place, such as a statement where an expression is expected.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		node.Statements = modifyStatements(node.Statements, modifier)
+	case *LetStatement:
+		if node.Value != nil {
+			node.Value = modifyExpression(node.Value, modifier)
+		}
+	case *ReturnStatement:
+		if node.ReturnValue != nil {
+			node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+		}
+	case *YieldStatement:
+		if node.Value != nil {
+			node.Value = modifyExpression(node.Value, modifier)
+		}
+	case *ExpressionStatement:
+		if node.Expression != nil {
+			node.Expression = modifyExpression(node.Expression, modifier)
+		}
+	case *BlockStatement:
+		node.Statements = modifyStatements(node.Statements, modifier)
+	case *PrefixExpression:
+		if node.Right != nil {
+			node.Right = modifyExpression(node.Right, modifier)
+		}
+	case *InfixExpression:
+		if node.Left != nil {
+			node.Left = modifyExpression(node.Left, modifier)
+		}
+		if node.Right != nil {
+			node.Right = modifyExpression(node.Right, modifier)
+		}
+	case *IfExpression:
+		if node.Condition != nil {
+			node.Condition = modifyExpression(node.Condition, modifier)
+		}
+		if node.Consequence != nil {
+			node.Consequence = modifyBlock(node.Consequence, modifier)
+		}
+		if node.Alternative != nil {
+			node.Alternative = modifyBlock(node.Alternative, modifier)
+		}
+	case *FunctionLiteral:
+		if node.Body != nil {
+			node.Body = modifyBlock(node.Body, modifier)
+		}
+	case *MacroLiteral:
+		if node.Body != nil {
+			node.Body = modifyBlock(node.Body, modifier)
+		}
+	case *CallExpression:
+		if node.Function != nil {
+			node.Function = modifyExpression(node.Function, modifier)
+		}
+		node.Arguments = modifyExpressions(node.Arguments, modifier)
+	case *ArrayLiteral:
+		node.Elements = modifyExpressions(node.Elements, modifier)
+	case *InterpolatedString:
+		node.Parts = modifyExpressions(node.Parts, modifier)
+	case *ForExpression:
+		if node.Iterable != nil {
+			node.Iterable = modifyExpression(node.Iterable, modifier)
+		}
+		if node.Body != nil {
+			node.Body = modifyBlock(node.Body, modifier)
+		}
+	case *SelectExpression:
+		for _, c := range node.Cases {
+			if c.Operation != nil {
+				c.Operation = modifyCall(c.Operation, modifier)
+			}
+			if c.Body != nil {
+				c.Body = modifyBlock(c.Body, modifier)
+			}
+		}
+		if node.Default != nil {
+			node.Default = modifyBlock(node.Default, modifier)
+		}
+	case *MatchExpression:
+		if node.Value != nil {
+			node.Value = modifyExpression(node.Value, modifier)
+		}
+		for _, arm := range node.Arms {
+			if arm.Guard != nil {
+				arm.Guard = modifyExpression(arm.Guard, modifier)
+			}
+			if arm.Value != nil {
+				arm.Value = modifyExpression(arm.Value, modifier)
+			}
+		}
+	case *IndexExpression:
+		if node.Left != nil {
+			node.Left = modifyExpression(node.Left, modifier)
+		}
+		if node.Index != nil {
+			node.Index = modifyExpression(node.Index, modifier)
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression)
+		for _, key := range node.Keys() {
+			newKey := modifyExpression(key, modifier)
+			newValue := node.Pairs[key]
+			if newValue != nil {
+				newValue = modifyExpression(newValue, modifier)
+			}
+			pairs[newKey] = newValue
+		}
+		node.Pairs = pairs
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	for i, stmt := range stmts {
+		if stmt != nil {
+			stmts[i] = modifyStatement(stmt, modifier)
+		}
+	}
+	return stmts
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) []Expression {
+	for i, exp := range exps {
+		if exp != nil {
+			exps[i] = modifyExpression(exp, modifier)
+		}
+	}
+	return exps
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	replacement := Modify(exp, modifier)
+	modified, ok := replacement.(Expression)
+	if !ok {
+		panic(misplaced(exp, replacement, "an expression"))
+	}
+	return modified
+}
+
+func modifyStatement(stmt Statement, modifier ModifierFunc) Statement {
+	replacement := Modify(stmt, modifier)
+	modified, ok := replacement.(Statement)
+	if !ok {
+		panic(misplaced(stmt, replacement, "a statement"))
+	}
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	replacement := Modify(block, modifier)
+	modified, ok := replacement.(*BlockStatement)
+	if !ok {
+		panic(misplaced(block, replacement, "a block"))
+	}
+	return modified
+}
+
+func modifyCall(call *CallExpression, modifier ModifierFunc) *CallExpression {
+	replacement := Modify(call, modifier)
+	modified, ok := replacement.(*CallExpression)
+	if !ok {
+		panic(misplaced(call, replacement, "a call"))
+	}
+	return modified
+}
+
+// misplaced describes a replacement of node that is not the kind of node
+// its parent holds.
+func misplaced(node, replacement Node, kind string) string {
+	return fmt.Sprintf("ast.Modify: %T replaced by %T, which is not %s", node, replacement, kind)
+}
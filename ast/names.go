@@ -0,0 +1,24 @@
+package ast
+
+import "fmt"
+
+// FunctionNames names the function literals of a program by their body. The
+// ones bound by a let statement are named after the binding, the other ones
+// after their position as in fn@3:9.
+func FunctionNames(program *Program) map[*BlockStatement]string {
+	names := make(map[*BlockStatement]string)
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *LetStatement:
+			if fn, ok := node.Value.(*FunctionLiteral); ok {
+				names[fn.Body] = node.Name.Value
+			}
+		case *FunctionLiteral:
+			if _, ok := names[node.Body]; !ok {
+				names[node.Body] = fmt.Sprintf("fn@%d:%d", node.Token.Line, node.Token.Column)
+			}
+		}
+		return true
+	})
+	return names
+}
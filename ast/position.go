@@ -0,0 +1,81 @@
+package ast
+
+import "magot/token"
+
+// StartToken returns the token a node starts with in the source, which for
+// infix, call and index expressions is not the node's own token.
+func StartToken(node Node) token.Token {
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return StartToken(n.Statements[0])
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			return StartToken(n.Expression)
+		}
+		return n.Token
+	case *InfixExpression:
+		if n.Left != nil {
+			return StartToken(n.Left)
+		}
+		return n.Token
+	case *CallExpression:
+		if n.Function != nil {
+			return StartToken(n.Function)
+		}
+		return n.Token
+	case *IndexExpression:
+		if n.Left != nil {
+			return StartToken(n.Left)
+		}
+		return n.Token
+	case *LetStatement:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *YieldStatement:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *Identifier:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *BigIntegerLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *Boolean:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *IfExpression:
+		return n.Token
+	case *FunctionLiteral:
+		return n.Token
+	case *MacroLiteral:
+		return n.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *InterpolatedString:
+		return n.Token
+	case *ForExpression:
+		return n.Token
+	case *SelectExpression:
+		return n.Token
+	case *MatchExpression:
+		return n.Token
+	case *LiteralPattern:
+		return n.Token
+	case *BindingPattern:
+		return n.Token
+	case *ArrayPattern:
+		return n.Token
+	case *HashPattern:
+		return n.Token
+	case *HashLiteral:
+		return n.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,230 @@
+package ast
+
+import "sort"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: it starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Walk(v, n.ReturnValue)
+		}
+	case *YieldStatement:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *Identifier, *IntegerLiteral, *BigIntegerLiteral, *StringLiteral, *Boolean:
+		// leaves
+	case *PrefixExpression:
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+	case *InfixExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+	case *IfExpression:
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Walk(v, param)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			Walk(v, param)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(v, n.Function)
+		}
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *InterpolatedString:
+		walkExpressions(v, n.Parts)
+	case *ForExpression:
+		if n.Variable != nil {
+			Walk(v, n.Variable)
+		}
+		if n.Iterable != nil {
+			Walk(v, n.Iterable)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *SelectExpression:
+		for _, c := range n.Cases {
+			if c.Name != nil {
+				Walk(v, c.Name)
+			}
+			if c.Operation != nil {
+				Walk(v, c.Operation)
+			}
+			if c.Body != nil {
+				Walk(v, c.Body)
+			}
+		}
+		if n.Default != nil {
+			Walk(v, n.Default)
+		}
+	case *MatchExpression:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		for _, arm := range n.Arms {
+			if arm.Pattern != nil {
+				Walk(v, arm.Pattern)
+			}
+			if arm.Guard != nil {
+				Walk(v, arm.Guard)
+			}
+			if arm.Value != nil {
+				Walk(v, arm.Value)
+			}
+		}
+	case *LiteralPattern:
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+	case *BindingPattern:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+	case *ArrayPattern:
+		for _, el := range n.Elements {
+			if el != nil {
+				Walk(v, el)
+			}
+		}
+		if n.Rest != nil {
+			Walk(v, n.Rest)
+		}
+	case *HashPattern:
+		for i, key := range n.Keys {
+			if key != nil {
+				Walk(v, key)
+			}
+			if n.Values[i] != nil {
+				Walk(v, n.Values[i])
+			}
+		}
+	case *IndexExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Index != nil {
+			Walk(v, n.Index)
+		}
+	case *HashLiteral:
+		for _, key := range n.Keys() {
+			Walk(v, key)
+			if value := n.Pairs[key]; value != nil {
+				Walk(v, value)
+			}
+		}
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		if exp != nil {
+			Walk(v, exp)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Keys returns the keys of a hash literal in source order, so that traversals
+// do not depend on map iteration.
+func (h *HashLiteral) Keys() []Expression {
+	keys := []Expression{}
+	for key := range h.Pairs {
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := StartToken(keys[i]), StartToken(keys[j])
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
@@ -0,0 +1,131 @@
+package ast
+
+import (
+	"magot/token"
+	"reflect"
+	"testing"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Value: value}
+}
+
+func TestInspect(t *testing.T) {
+	// let f = fn(x) { if (x) { [x][0] } else { {1: -x} } }; f(1 + 2);
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Name: ident("f"),
+			Value: &FunctionLiteral{
+				Parameters: []*Identifier{ident("x")},
+				Body: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: &IfExpression{
+						Condition: ident("x"),
+						Consequence: &BlockStatement{Statements: []Statement{
+							&ExpressionStatement{Expression: &IndexExpression{
+								Left:  &ArrayLiteral{Elements: []Expression{ident("x")}},
+								Index: integer(0),
+							}},
+						}},
+						Alternative: &BlockStatement{Statements: []Statement{
+							&ExpressionStatement{Expression: &HashLiteral{Pairs: map[Expression]Expression{
+								integer(1): &PrefixExpression{Operator: "-", Right: ident("x")},
+							}}},
+						}},
+					}},
+				}},
+			},
+		},
+		&ReturnStatement{ReturnValue: &CallExpression{
+			Function:  ident("f"),
+			Arguments: []Expression{&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)}},
+		}},
+	}}
+
+	expected := []string{
+		"*ast.Program", "*ast.LetStatement", "*ast.Identifier", "*ast.FunctionLiteral",
+		"*ast.Identifier", "*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IfExpression",
+		"*ast.Identifier", "*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.IndexExpression",
+		"*ast.ArrayLiteral", "*ast.Identifier", "*ast.IntegerLiteral", "*ast.BlockStatement",
+		"*ast.ExpressionStatement", "*ast.HashLiteral", "*ast.IntegerLiteral", "*ast.PrefixExpression",
+		"*ast.Identifier", "*ast.ReturnStatement", "*ast.CallExpression", "*ast.Identifier",
+		"*ast.InfixExpression", "*ast.IntegerLiteral", "*ast.IntegerLiteral",
+	}
+
+	visited := []string{}
+	depth, maxDepth := 0, 0
+	Inspect(program, func(node Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		visited = append(visited, reflect.TypeOf(node).String())
+		depth++
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("wrong visiting order.\nexpected=%v\ngot=%v", expected, visited)
+	}
+	if depth != 0 {
+		t.Errorf("unbalanced nil visits, depth=%d", depth)
+	}
+	if maxDepth != 11 {
+		t.Errorf("wrong maximal depth. expected=11, got=%d", maxDepth)
+	}
+}
+
+func TestInspectPruning(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &FunctionLiteral{
+			Parameters: []*Identifier{ident("x")},
+			Body: &BlockStatement{Statements: []Statement{
+				&ExpressionStatement{Expression: ident("x")},
+			}},
+		}},
+		&ExpressionStatement{Expression: ident("y")},
+	}}
+
+	identifiers := []string{}
+	Inspect(program, func(node Node) bool {
+		if _, ok := node.(*FunctionLiteral); ok {
+			return false
+		}
+		if ident, ok := node.(*Identifier); ok {
+			identifiers = append(identifiers, ident.Value)
+		}
+		return true
+	})
+	if !reflect.DeepEqual(identifiers, []string{"y"}) {
+		t.Errorf("function literal was not pruned, got=%v", identifiers)
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	// let f = fn() { fn(x) { x } }; let g = f;
+	inner := &FunctionLiteral{
+		Token:      token.Token{Type: token.FUNCTION, Literal: "fn", Line: 1, Column: 16},
+		Parameters: []*Identifier{ident("x")},
+		Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("x")}}},
+	}
+	outer := &FunctionLiteral{
+		Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: inner}}},
+	}
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("f"), Value: outer},
+		&LetStatement{Name: ident("g"), Value: ident("f")},
+	}}
+
+	names := FunctionNames(program)
+
+	expected := map[*BlockStatement]string{outer.Body: "f", inner.Body: "fn@1:16"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong names. expected=%v, got=%v", expected, names)
+	}
+}
@@ -0,0 +1,227 @@
+// Package coverage records which statements and branches of Magot programs
+// run, the branches being the ways of if, match and select expressions and
+// of match guards, and reports it as percentages, LCOV tracefiles or
+// annotated HTML sources.
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/object"
+	"sync"
+)
+
+// Coverage counts the runs of the statements and branches of the programs
+// added to it. It is an evaluator.Tracer.
+type Coverage struct {
+	mu         sync.Mutex // held by the notifications of the functions spawn runs
+	files      []*file
+	statements map[ast.Statement]*statement
+	branches   map[ast.Expression]*branch
+}
+
+type file struct {
+	name       string
+	statements []*statement
+	branches   []*branch
+}
+
+type statement struct {
+	line  int
+	count int
+}
+
+// branch counts the runs of each way of a branching expression: the
+// consequence and the alternative of an if expression, the alternative being
+// counted even when it is missing, the arms of a match expression, a match
+// guard holding and not, or the cases of a select expression.
+type branch struct {
+	node   ast.Expression
+	line   int
+	counts []int
+}
+
+func New() *Coverage {
+	return &Coverage{
+		statements: make(map[ast.Statement]*statement),
+		branches:   make(map[ast.Expression]*branch),
+	}
+}
+
+// Add registers the statements and branching expressions of the program read
+// from the named file. Code in quote calls is left out, as it is data until a
+// macro expands it.
+func (c *Coverage) Add(name string, program *ast.Program) {
+	f := &file{name: name}
+	c.files = append(c.files, f)
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			if node.Function != nil && node.Function.TokenLiteral() == "quote" {
+				return false
+			}
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.YieldStatement, *ast.ExpressionStatement:
+			s := &statement{line: ast.StartToken(node).Line}
+			c.statements[node.(ast.Statement)] = s
+			f.statements = append(f.statements, s)
+		case *ast.IfExpression:
+			c.addBranch(f, node, 2)
+		case *ast.MatchExpression:
+			c.addBranch(f, node, len(node.Arms))
+			for _, arm := range node.Arms {
+				if arm.Guard != nil {
+					c.addBranch(f, arm.Guard, 2)
+				}
+			}
+		case *ast.SelectExpression:
+			ways := len(node.Cases)
+			if node.Default != nil {
+				ways++
+			}
+			c.addBranch(f, node, ways)
+		}
+		return true
+	})
+}
+
+func (c *Coverage) addBranch(f *file, node ast.Expression, ways int) {
+	b := &branch{node: node, line: ast.StartToken(node).Line, counts: make([]int, ways)}
+	c.branches[node] = b
+	f.branches = append(f.branches, b)
+}
+
+func (c *Coverage) Statement(stmt ast.Statement, env *object.Environment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.statements[stmt]; ok {
+		s.count++
+	}
+}
+
+func (c *Coverage) Branch(node ast.Expression, way int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.branches[node]; ok && way < len(b.counts) {
+		b.counts[way]++
+	}
+}
+
+// Summary is the coverage of a file.
+type Summary struct {
+	File                string
+	Statements, Covered int
+	Branches, Taken     int
+}
+
+func (s Summary) StatementPercent() float64 {
+	return percent(s.Covered, s.Statements)
+}
+
+func (s Summary) BranchPercent() float64 {
+	return percent(s.Taken, s.Branches)
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("coverage: %.1f%% of statements, %.1f%% of branches", s.StatementPercent(), s.BranchPercent())
+}
+
+// percent returns 100 when there is nothing to cover.
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// Summaries returns the coverage of the files in the order they were added.
+func (c *Coverage) Summaries() []Summary {
+	summaries := []Summary{}
+	for _, f := range c.files {
+		summaries = append(summaries, f.summary())
+	}
+	return summaries
+}
+
+// Summary returns the coverage of the named file, summed over the times it was
+// added.
+func (c *Coverage) Summary(name string) Summary {
+	summary := Summary{File: name}
+	for _, f := range c.files {
+		if f.name == name {
+			s := f.summary()
+			summary.Statements += s.Statements
+			summary.Covered += s.Covered
+			summary.Branches += s.Branches
+			summary.Taken += s.Taken
+		}
+	}
+	return summary
+}
+
+func (f *file) summary() Summary {
+	summary := Summary{File: f.name, Statements: len(f.statements)}
+	for _, s := range f.statements {
+		if s.count > 0 {
+			summary.Covered++
+		}
+	}
+	for _, b := range f.branches {
+		summary.Branches += len(b.counts)
+		for _, count := range b.counts {
+			if count > 0 {
+				summary.Taken++
+			}
+		}
+	}
+	return summary
+}
+
+// lines returns the run counts of the statements starting on each line, in
+// line order.
+func (f *file) lines() ([]int, map[int][]int) {
+	counts := make(map[int][]int)
+	numbers := []int{}
+	for _, s := range f.statements {
+		if _, ok := counts[s.line]; !ok {
+			numbers = append(numbers, s.line)
+		}
+		counts[s.line] = append(counts[s.line], s.count)
+	}
+	return numbers, counts
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile. A line is reported as
+// run as many times as the least run statement starting on it, so that the
+// lines holding a function that is never called are not reported as run.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	for _, f := range c.files {
+		summary := f.summary()
+		fmt.Fprintf(w, "TN:\nSF:%s\n", f.name)
+		for i, b := range f.branches {
+			for j, count := range b.counts {
+				fmt.Fprintf(w, "BRDA:%d,%d,%d,%d\n", b.line, i, j, count)
+			}
+		}
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", summary.Branches, summary.Taken)
+
+		numbers, counts := f.lines()
+		hit := 0
+		for _, line := range numbers {
+			min := counts[line][0]
+			for _, count := range counts[line] {
+				if count < min {
+					min = count
+				}
+			}
+			if min > 0 {
+				hit++
+			}
+			fmt.Fprintf(w, "DA:%d,%d\n", line, min)
+		}
+		if _, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(numbers), hit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package coverage
+
+import (
+	"bytes"
+	"magot/evaluator"
+	"magot/lexer"
+	"magot/object"
+	"magot/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const source = `let f = fn(x) {
+  if (x > 2) { "big" } else { "small" }
+};
+let unused = fn() { 1 };
+f(3);
+if (false) { 1 };
+let q = fn() { quote(if (true) { 1 }) };
+`
+
+func TestCoverage(t *testing.T) {
+	cov := run(t, "prog.mg", source)
+
+	summaries := cov.Summaries()
+	if len(summaries) != 1 {
+		t.Fatalf("wrong number of summaries. got=%d", len(summaries))
+	}
+	expected := Summary{File: "prog.mg", Statements: 11, Covered: 7, Branches: 4, Taken: 2}
+	if summaries[0] != expected {
+		t.Errorf("wrong summary. expected=%+v, got=%+v", expected, summaries[0])
+	}
+	if summary := cov.Summary("prog.mg"); summary != expected {
+		t.Errorf("wrong summary. expected=%+v, got=%+v", expected, summary)
+	}
+	if s := summaries[0].String(); s != "coverage: 63.6% of statements, 50.0% of branches" {
+		t.Errorf("wrong summary string. got=%q", s)
+	}
+	if s := (Summary{}).String(); s != "coverage: 100.0% of statements, 100.0% of branches" {
+		t.Errorf("wrong empty summary string. got=%q", s)
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	cov := run(t, "prog.mg", source)
+
+	var out bytes.Buffer
+	if err := cov.WriteLCOV(&out); err != nil {
+		t.Fatalf("WriteLCOV failed: %s", err)
+	}
+
+	expected := `TN:
+SF:prog.mg
+BRDA:2,0,0,1
+BRDA:2,0,1,0
+BRDA:6,1,0,0
+BRDA:6,1,1,1
+BRF:4
+BRH:2
+DA:1,1
+DA:2,0
+DA:4,0
+DA:5,1
+DA:6,0
+DA:7,0
+LF:6
+LH:2
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("wrong LCOV.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prog.mg")
+	if err := os.WriteFile(file, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cov := run(t, file, source)
+
+	var out bytes.Buffer
+	if err := cov.WriteHTML(&out); err != nil {
+		t.Fatalf("WriteHTML failed: %s", err)
+	}
+
+	for _, expected := range []string{
+		`<span class="line covered"><span class="number">1</span>let f = fn(x) {</span>`,
+		`<span class="line partial" title="if: consequence 1, alternative 0"><span class="number">2</span>  if (x &gt; 2) { &#34;big&#34; } else { &#34;small&#34; }</span>`,
+		`<span class="line "><span class="number">3</span>};</span>`,
+		`<span class="line partial"><span class="number">4</span>let unused = fn() { 1 };</span>`,
+		`<p>coverage: 63.6% of statements, 50.0% of branches</p>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("HTML report does not contain %q", expected)
+		}
+	}
+}
+
+func TestMatchAndSelectBranches(t *testing.T) {
+	cov := run(t, "prog.mg", `let size = fn(x) {
+  match (x) {
+    n if n > 9 => "big",
+    0 => "zero",
+    _ => "small",
+  }
+};
+size(3);
+size(5);
+let c = channel(1);
+send(c, 1);
+select {
+  recv(c) { 1 }
+  default { 2 }
+};
+`)
+
+	var out bytes.Buffer
+	if err := cov.WriteLCOV(&out); err != nil {
+		t.Fatalf("WriteLCOV failed: %s", err)
+	}
+	for _, expected := range []string{
+		"BRDA:2,0,0,0\nBRDA:2,0,1,0\nBRDA:2,0,2,2\n",
+		"BRDA:3,1,0,0\nBRDA:3,1,1,2\n",
+		"BRDA:12,2,0,1\nBRDA:12,2,1,0\n",
+		"BRF:7\nBRH:3\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("LCOV does not contain %q, got=%q", expected, out.String())
+		}
+	}
+
+	titles := []string{}
+	for _, b := range cov.files[0].branches {
+		titles = append(titles, b.title())
+	}
+	expected := "match: arms 0, 0, 2; guard: holds 0, fails 2; select: cases 1, default 0"
+	if strings.Join(titles, "; ") != expected {
+		t.Errorf("wrong titles. expected=%q, got=%q", expected, strings.Join(titles, "; "))
+	}
+}
+
+func run(t *testing.T, name, source string) *Coverage {
+	t.Helper()
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	cov := New()
+	cov.Add(name, program)
+	env := object.NewEnvironment()
+	evaluator.SetContext(env, &evaluator.Context{Tracer: cov})
+	evaluator.Eval(program, env)
+	return cov
+}
+
+var _ evaluator.Tracer = (*Coverage)(nil)
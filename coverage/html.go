@@ -0,0 +1,114 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"magot/ast"
+	"os"
+	"strings"
+)
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Magot coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { font-family: monospace; }
+.line { display: block; white-space: pre; }
+.number { color: #888; display: inline-block; text-align: right; width: 4em; margin-right: 1em; }
+.covered { background: #c8f0c8; }
+.partial { background: #f0f0a0; }
+.uncovered { background: #f8c8c8; }
+</style>
+</head>
+<body>
+{{range .}}
+<h2>{{.Summary.File}}</h2>
+<p>{{.Summary}}</p>
+<pre>{{range .Lines}}<span class="line {{.Class}}"{{with .Title}} title="{{.}}"{{end}}><span class="number">{{.Number}}</span>{{.Text}}</span>{{end}}</pre>
+{{end}}
+</body>
+</html>
+`))
+
+type htmlFile struct {
+	Summary Summary
+	Lines   []htmlLine
+}
+
+type htmlLine struct {
+	Number int
+	Text   string
+	Class  string
+	Title  string
+}
+
+// WriteHTML writes the sources of the files as an HTML page, the lines on
+// which statements start being highlighted according to whether they all,
+// some or none of them ran. The branch counts show on hover.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	files := []htmlFile{}
+	for _, f := range c.files {
+		source, err := os.ReadFile(f.name)
+		if err != nil {
+			return err
+		}
+		_, counts := f.lines()
+		titles := make(map[int][]string)
+		for _, b := range f.branches {
+			titles[b.line] = append(titles[b.line], b.title())
+		}
+
+		report := htmlFile{Summary: f.summary()}
+		for i, text := range strings.Split(string(source), "\n") {
+			line := htmlLine{Number: i + 1, Text: text, Title: strings.Join(titles[i+1], "; ")}
+			line.Class = lineClass(counts[i+1])
+			report.Lines = append(report.Lines, line)
+		}
+		files = append(files, report)
+	}
+	return htmlReport.Execute(w, files)
+}
+
+// title describes the counts of a branch.
+func (b *branch) title() string {
+	counts := make([]string, len(b.counts))
+	for i, count := range b.counts {
+		counts[i] = fmt.Sprint(count)
+	}
+	switch node := b.node.(type) {
+	case *ast.IfExpression:
+		return fmt.Sprintf("if: consequence %d, alternative %d", b.counts[0], b.counts[1])
+	case *ast.MatchExpression:
+		return "match: arms " + strings.Join(counts, ", ")
+	case *ast.SelectExpression:
+		ways := counts[:len(node.Cases)]
+		if node.Default != nil {
+			ways = append(ways, "default "+counts[len(node.Cases)])
+		}
+		return "select: cases " + strings.Join(ways, ", ")
+	}
+	return fmt.Sprintf("guard: holds %d, fails %d", b.counts[0], b.counts[1])
+}
+
+func lineClass(counts []int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	ran := 0
+	for _, count := range counts {
+		if count > 0 {
+			ran++
+		}
+	}
+	switch ran {
+	case 0:
+		return "uncovered"
+	case len(counts):
+		return "covered"
+	}
+	return "partial"
+}
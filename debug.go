@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"magot/ast"
+	"magot/debugger"
+	"magot/evaluator"
+	"magot/object"
+	"net"
+	"os"
+)
+
+func debugCommand(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	listen := flags.String("listen", "", "serve the Debug Adapter Protocol to one client on the TCP address, such as localhost:4711, instead of reading commands from the terminal")
+	grant := sandboxFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: magot debug [flags] file.mg\n")
+		fmt.Fprintf(os.Stderr, "       magot debug -listen addr\n\n")
+		fmt.Fprintf(os.Stderr, "Runs a Magot program under the debugger, stopping before its first statement.\n")
+		fmt.Fprintf(os.Stderr, "Type help at the (magot) prompt for the list of commands.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	context, err := grant()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *listen != "" {
+		if flags.NArg() != 0 {
+			flags.Usage()
+			return 2
+		}
+		return serveDAP(*listen, context)
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file := flags.Arg(0)
+	source, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	program, err := loadProgram(file, context)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	console := debugger.NewConsole(os.Stdin, os.Stdout, file, string(source))
+	d := debugger.New(program, console)
+	d.Context = context
+	evaluated := d.Run(true)
+	if evaluated == nil {
+		return 1
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", file, errObj.Line, errObj.Column, errObj.Inspect())
+		return 1
+	}
+	return 0
+}
+
+// serveDAP serves the first client connecting to addr. The protocol is not
+// spoken on the standard streams, which the program writes to.
+func serveDAP(addr string, context *evaluator.Context) int {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer listener.Close()
+	fmt.Fprintf(os.Stderr, "listening on %s\n", listener.Addr())
+
+	conn, err := listener.Accept()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	load := func(file string) (*ast.Program, error) {
+		return loadProgram(file, context)
+	}
+	if err := debugger.ServeDAP(conn, load, context); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,185 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"magot/object"
+	"strconv"
+	"strings"
+)
+
+const CONSOLE_PROMPT = "(magot) "
+
+const consoleHelp = `break LINE [if EXPR]  set a breakpoint, stopping when EXPR holds (b)
+clear LINE            remove a breakpoint
+breakpoints           list the breakpoints
+continue              run until a breakpoint (c)
+next                  step over calls (n)
+step                  step into calls (s)
+out                   run until the current function returns (o)
+print EXPR            evaluate EXPR in the selected frame (p)
+locals                list the variables of the selected frame
+backtrace             list the frames, innermost first (bt)
+frame N               select a frame for print and locals (f)
+list                  show the source around the current line (l)
+quit                  abandon the program (q)
+An empty line repeats the last command.
+`
+
+// Console is a Client reading commands from an input, typically a terminal.
+type Console struct {
+	in     *bufio.Scanner
+	out    io.Writer
+	file   string
+	source []string
+	last   string
+	frame  int // the selected frame, 0 for the innermost one
+}
+
+// NewConsole returns a console for the program read from file, whose source
+// is shown as the evaluation stops.
+func NewConsole(in io.Reader, out io.Writer, file, source string) *Console {
+	return &Console{
+		in:     bufio.NewScanner(in),
+		out:    out,
+		file:   file,
+		source: strings.Split(source, "\n"),
+	}
+}
+
+func (c *Console) Stopped(d *Debugger, reason Reason) Action {
+	c.frame = 0
+	top := d.Frames()[0]
+	fmt.Fprintf(c.out, "stopped (%s) in %s at %s:%d:%d\n", reason, top.Name, c.file, top.Line, top.Column)
+	c.printLines(top.Line, top.Line)
+
+	for {
+		io.WriteString(c.out, CONSOLE_PROMPT)
+		if !c.in.Scan() {
+			io.WriteString(c.out, "\n")
+			return TERMINATE
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+		if action, resume := c.run(d, line); resume {
+			return action
+		}
+	}
+}
+
+// run runs a command, reporting whether it resumes the evaluation.
+func (c *Console) run(d *Debugger, line string) (Action, bool) {
+	command, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		command, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	frames := d.Frames()
+
+	switch command {
+	case "":
+	case "continue", "c":
+		return CONTINUE, true
+	case "next", "n":
+		return STEP_OVER, true
+	case "step", "s":
+		return STEP_IN, true
+	case "out", "o":
+		return STEP_OUT, true
+	case "quit", "q":
+		return TERMINATE, true
+	case "break", "b":
+		c.setBreakpoint(d, arg)
+	case "clear":
+		line, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(c.out, "usage: clear LINE\n")
+			break
+		}
+		d.ClearBreakpoint(line)
+	case "breakpoints":
+		for _, bp := range d.Breakpoints() {
+			fmt.Fprintf(c.out, "%s:%d", c.file, bp.Line)
+			if bp.Condition != "" {
+				fmt.Fprintf(c.out, " if %s", bp.Condition)
+			}
+			fmt.Fprintln(c.out)
+		}
+	case "print", "p":
+		result := d.Evaluate(arg, c.frame)
+		if result == nil {
+			fmt.Fprintln(c.out, "no value")
+		} else {
+			fmt.Fprintln(c.out, result.Inspect())
+		}
+	case "locals":
+		env := frames[c.frame].Env
+		for _, name := range env.LocalNames() {
+			value, _ := env.Get(name)
+			fmt.Fprintf(c.out, "%s = %s\n", name, inspect(value))
+		}
+	case "backtrace", "bt":
+		for i, f := range frames {
+			marker := " "
+			if i == c.frame {
+				marker = "*"
+			}
+			fmt.Fprintf(c.out, "%s %d %s at %s:%d:%d\n", marker, i, f.Name, c.file, f.Line, f.Column)
+		}
+	case "frame", "f":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n >= len(frames) {
+			fmt.Fprintf(c.out, "usage: frame N, N being between 0 and %d\n", len(frames)-1)
+			break
+		}
+		c.frame = n
+		fmt.Fprintf(c.out, "%d %s at %s:%d:%d\n", n, frames[n].Name, c.file, frames[n].Line, frames[n].Column)
+	case "list", "l":
+		line := frames[c.frame].Line
+		c.printLines(line-5, line+5)
+	case "help", "h":
+		io.WriteString(c.out, consoleHelp)
+	default:
+		fmt.Fprintf(c.out, "unknown command %q, type help for the list of commands\n", command)
+	}
+	return CONTINUE, false
+}
+
+func (c *Console) setBreakpoint(d *Debugger, arg string) {
+	lineArg, condition := arg, ""
+	if i := strings.Index(arg, " if "); i >= 0 {
+		lineArg, condition = strings.TrimSpace(arg[:i]), strings.TrimSpace(arg[i+4:])
+	}
+	line, err := strconv.Atoi(lineArg)
+	if err != nil {
+		fmt.Fprintf(c.out, "usage: break LINE [if EXPR]\n")
+		return
+	}
+	if _, err := d.SetBreakpoint(line, condition); err != nil {
+		fmt.Fprintf(c.out, "invalid condition: %s\n", err)
+		return
+	}
+	fmt.Fprintf(c.out, "breakpoint set at %s:%d\n", c.file, line)
+}
+
+// printLines prints the source lines from first to last, numbered.
+func (c *Console) printLines(first, last int) {
+	for n := first; n <= last; n++ {
+		if n >= 1 && n <= len(c.source) {
+			fmt.Fprintf(c.out, "%4d | %s\n", n, c.source[n-1])
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "no value"
+	}
+	if str, ok := obj.(*object.String); ok {
+		return strconv.Quote(str.Value)
+	}
+	return obj.Inspect()
+}
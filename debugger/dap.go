@@ -0,0 +1,447 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/evaluator"
+	"magot/object"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The Debug Adapter Protocol, which editors speak to debuggers, is described
+// at https://microsoft.github.io/debug-adapter-protocol/. Its messages are
+// JSON objects each preceded by a Content-Length header.
+
+type dapMessage struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+// The only thread is the one evaluating the program.
+const dapThread = 1
+
+type dapSource struct {
+	Path string `json:"path"`
+}
+
+// Loader parses a program file and expands its macros.
+type Loader func(file string) (*ast.Program, error)
+
+// dapSession serves one client. Requests are handled in the order they come,
+// while the program runs in its own goroutine, which waits for an action on
+// resume when it stops.
+type dapSession struct {
+	in      *bufio.Reader
+	out     io.Writer
+	load    Loader
+	context *evaluator.Context
+
+	mu      sync.Mutex // guards seq, the output and stopped
+	seq     int
+	stopped bool
+
+	file        string
+	stopOnEntry bool
+	debugger    *Debugger
+	breakpoints []dapBreakpointRequest // set before launch
+	resume      chan Action
+	terminating bool
+
+	// The variables of a stop, referred to by their index plus 1.
+	variables []interface{}
+}
+
+type dapBreakpointRequest struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// ServeDAP speaks the Debug Adapter Protocol on conn until the client
+// disconnects. The program to debug is given by the "program" argument of
+// the launch request, and is loaded with load and run with the context given.
+func ServeDAP(conn io.ReadWriter, load Loader, context *evaluator.Context) error {
+	s := &dapSession{
+		in:      bufio.NewReader(conn),
+		out:     conn,
+		load:    load,
+		context: context,
+		resume:  make(chan Action),
+	}
+	for {
+		request, err := s.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if done := s.handle(request); done {
+			return nil
+		}
+	}
+}
+
+func (s *dapSession) read() (*dapMessage, error) {
+	headers, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %s", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	message := &dapMessage{}
+	if err := json.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (s *dapSession) send(message *dapMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	message.Seq = s.seq
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *dapSession) respond(request *dapMessage, body interface{}) {
+	s.send(&dapMessage{Type: "response", RequestSeq: request.Seq, Command: request.Command, Success: true, Body: body})
+}
+
+func (s *dapSession) fail(request *dapMessage, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	s.send(&dapMessage{Type: "response", RequestSeq: request.Seq, Command: request.Command, Message: message})
+}
+
+func (s *dapSession) event(event string, body interface{}) {
+	s.send(&dapMessage{Type: "event", Event: event, Body: body})
+}
+
+func (s *dapSession) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// handle handles a request, reporting whether the session is over.
+func (s *dapSession) handle(request *dapMessage) bool {
+	switch request.Command {
+	case "initialize":
+		s.respond(request, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		s.launch(request)
+	case "setBreakpoints":
+		s.setBreakpoints(request)
+	case "configurationDone":
+		s.respond(request, nil)
+		if s.debugger != nil {
+			go s.run()
+		}
+	case "threads":
+		s.respond(request, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThread, "name": "main"}},
+		})
+	case "stackTrace":
+		s.stackTrace(request)
+	case "scopes":
+		s.scopes(request)
+	case "variables":
+		s.listVariables(request)
+	case "evaluate":
+		s.evaluate(request)
+	case "continue":
+		s.respond(request, map[string]bool{"allThreadsContinued": true})
+		s.resumeWith(CONTINUE)
+	case "next":
+		s.respond(request, nil)
+		s.resumeWith(STEP_OVER)
+	case "stepIn":
+		s.respond(request, nil)
+		s.resumeWith(STEP_IN)
+	case "stepOut":
+		s.respond(request, nil)
+		s.resumeWith(STEP_OUT)
+	case "pause":
+		s.respond(request, nil)
+		if s.debugger != nil {
+			s.debugger.Pause()
+		}
+	case "terminate", "disconnect":
+		s.terminate()
+		s.respond(request, nil)
+		return request.Command == "disconnect"
+	default:
+		s.fail(request, "unsupported request %s", request.Command)
+	}
+	return false
+}
+
+func (s *dapSession) launch(request *dapMessage) {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(request.Arguments, &args); err != nil || args.Program == "" {
+		s.fail(request, "launch requires a program")
+		return
+	}
+	program, err := s.load(args.Program)
+	if err != nil {
+		s.fail(request, "%s", err)
+		return
+	}
+	s.file, s.stopOnEntry = args.Program, args.StopOnEntry
+	s.debugger = New(program, s)
+	s.debugger.Context = s.context
+	for _, bp := range s.breakpoints {
+		s.debugger.SetBreakpoint(bp.Line, bp.Condition)
+	}
+	s.respond(request, nil)
+}
+
+func (s *dapSession) setBreakpoints(request *dapMessage) {
+	var args struct {
+		Breakpoints []dapBreakpointRequest `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(request.Arguments, &args); err != nil {
+		s.fail(request, "%s", err)
+		return
+	}
+	s.breakpoints = args.Breakpoints
+
+	breakpoints := []map[string]interface{}{}
+	if s.debugger != nil {
+		s.debugger.ClearBreakpoints()
+	}
+	for _, bp := range args.Breakpoints {
+		verified, message := true, ""
+		if s.debugger != nil {
+			if _, err := s.debugger.SetBreakpoint(bp.Line, bp.Condition); err != nil {
+				verified, message = false, err.Error()
+			}
+		}
+		breakpoints = append(breakpoints, map[string]interface{}{"verified": verified, "line": bp.Line, "message": message})
+	}
+	s.respond(request, map[string]interface{}{"breakpoints": breakpoints})
+}
+
+// run evaluates the program, reporting its end to the client.
+func (s *dapSession) run() {
+	result := s.debugger.Run(s.stopOnEntry)
+	exitCode := 0
+	if err, ok := result.(*object.Error); ok {
+		exitCode = 1
+		s.event("output", map[string]string{
+			"category": "stderr",
+			"output":   fmt.Sprintf("%s:%d:%d: %s\n", s.file, err.Line, err.Column, err.Inspect()),
+		})
+	}
+	s.event("exited", map[string]int{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// Stopped tells the client that the evaluation stopped and waits for it to
+// resume it.
+func (s *dapSession) Stopped(d *Debugger, reason Reason) Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+		return TERMINATE
+	}
+	s.stopped = true
+	s.variables = nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{
+		"reason":            string(reason),
+		"threadId":          dapThread,
+		"allThreadsStopped": true,
+	})
+	return <-s.resume
+}
+
+func (s *dapSession) resumeWith(action Action) {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.stopped = false
+	s.mu.Unlock()
+	if stopped {
+		s.resume <- action
+	}
+}
+
+// terminate abandons the evaluation, at once if it is stopped and at its
+// next statement otherwise.
+func (s *dapSession) terminate() {
+	s.mu.Lock()
+	s.terminating = true
+	s.mu.Unlock()
+	if s.debugger == nil {
+		return
+	}
+	s.debugger.Pause()
+	s.resumeWith(TERMINATE)
+}
+
+func (s *dapSession) stackTrace(request *dapMessage) {
+	if !s.isStopped() {
+		s.fail(request, "the program is running")
+		return
+	}
+	frames := []map[string]interface{}{}
+	for i, f := range s.debugger.Frames() {
+		frames = append(frames, map[string]interface{}{
+			"id":     i + 1,
+			"name":   f.Name,
+			"line":   f.Line,
+			"column": f.Column,
+			"source": dapSource{Path: s.file},
+		})
+	}
+	s.respond(request, map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)})
+}
+
+// frame returns the index of the frame identified in the arguments of a
+// request, frames being identified by their index plus 1.
+func (s *dapSession) frame(request *dapMessage) (int, bool) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	json.Unmarshal(request.Arguments, &args)
+	if !s.isStopped() || args.FrameID < 1 || args.FrameID > len(s.debugger.Frames()) {
+		return 0, false
+	}
+	return args.FrameID - 1, true
+}
+
+// scopes lists the environments of a frame, from its own to the global one.
+func (s *dapSession) scopes(request *dapMessage) {
+	index, ok := s.frame(request)
+	if !ok {
+		s.fail(request, "no such frame")
+		return
+	}
+	scopes := []map[string]interface{}{}
+	for env := s.debugger.Frames()[index].Env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case len(scopes) == 0:
+			name = "Locals"
+		}
+		scopes = append(scopes, map[string]interface{}{
+			"name":               name,
+			"variablesReference": s.reference(env),
+			"expensive":          false,
+		})
+	}
+	s.respond(request, map[string]interface{}{"scopes": scopes})
+}
+
+// reference returns the reference to an environment or a value holding
+// variables, 0 for the other values.
+func (s *dapSession) reference(v interface{}) int {
+	switch v.(type) {
+	case *object.Environment, *object.Array, *object.Hash:
+		s.variables = append(s.variables, v)
+		return len(s.variables)
+	}
+	return 0
+}
+
+func (s *dapSession) variable(name string, value object.Object) map[string]interface{} {
+	return map[string]interface{}{
+		"name":               name,
+		"value":              inspect(value),
+		"type":               string(value.Type()),
+		"variablesReference": s.reference(value),
+	}
+}
+
+func (s *dapSession) listVariables(request *dapMessage) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	json.Unmarshal(request.Arguments, &args)
+	if !s.isStopped() || args.VariablesReference < 1 || args.VariablesReference > len(s.variables) {
+		s.fail(request, "no such variables")
+		return
+	}
+
+	variables := []map[string]interface{}{}
+	switch v := s.variables[args.VariablesReference-1].(type) {
+	case *object.Environment:
+		for _, name := range v.LocalNames() {
+			value, _ := v.Get(name)
+			variables = append(variables, s.variable(name, value))
+		}
+	case *object.Array:
+		for i, element := range v.Elements {
+			variables = append(variables, s.variable(strconv.Itoa(i), element))
+		}
+	case *object.Hash:
+		pairs := []object.HashPair{}
+		for _, pair := range v.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool { return inspect(pairs[i].Key) < inspect(pairs[j].Key) })
+		for _, pair := range pairs {
+			variables = append(variables, s.variable(inspect(pair.Key), pair.Value))
+		}
+	}
+	s.respond(request, map[string]interface{}{"variables": variables})
+}
+
+func (s *dapSession) evaluate(request *dapMessage) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	json.Unmarshal(request.Arguments, &args)
+	index, ok := s.frame(request)
+	if !ok {
+		s.fail(request, "no such frame")
+		return
+	}
+	result := s.debugger.Evaluate(args.Expression, index)
+	if err, ok := result.(*object.Error); ok {
+		s.fail(request, "%s", strings.TrimPrefix(err.Inspect(), "ERROR: "))
+		return
+	}
+	body := map[string]interface{}{"result": "", "variablesReference": 0}
+	if result != nil {
+		body["result"] = inspect(result)
+		body["type"] = string(result.Type())
+		body["variablesReference"] = s.reference(result)
+	}
+	s.respond(request, body)
+}
@@ -0,0 +1,402 @@
+// Package debugger stops the evaluation of Magot programs at breakpoints and
+// steps through them, letting a client such as a console or an editor
+// inspect the call stack and the variables in the meantime.
+package debugger
+
+import (
+	"magot/ast"
+	"magot/evaluator"
+	"magot/lexer"
+	"magot/object"
+	"magot/parser"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Reason tells why the evaluation stopped.
+type Reason string
+
+const (
+	ENTRY      Reason = "entry"
+	BREAKPOINT Reason = "breakpoint"
+	STEP       Reason = "step"
+	PAUSE      Reason = "pause"
+)
+
+// Action tells how to resume a stopped evaluation.
+type Action int
+
+const (
+	CONTINUE  Action = iota // run until a breakpoint
+	STEP_IN                 // stop at the next statement
+	STEP_OVER               // stop at the next statement of the frame or of its callers
+	STEP_OUT                // stop at the next statement of a caller
+	TERMINATE               // abandon the evaluation
+)
+
+// Client drives a stopped evaluation.
+type Client interface {
+	// Stopped is called in the evaluating goroutine when the evaluation
+	// stops, and returns how to resume it. The frames of the debugger can
+	// be inspected until it returns.
+	Stopped(d *Debugger, reason Reason) Action
+}
+
+// Frame is a function call being evaluated, or the top-level statements of
+// the program.
+type Frame struct {
+	Name string
+	// Line and Column locate the statement being evaluated, or the call
+	// being made once a function is called from the frame.
+	Line, Column int
+	Env          *object.Environment
+}
+
+// Breakpoint stops the evaluation before the statements starting on a line,
+// when its condition, if any, holds.
+type Breakpoint struct {
+	Line      int
+	Condition string
+	condition *ast.Program
+}
+
+// Debugger is an evaluator.CallTracer keeping the call stack of each
+// goroutine of the evaluation and handing the one that stops to its client.
+type Debugger struct {
+	// Context is the context of the evaluation, nil for the defaults of
+	// evaluator.SetContext. Its tracer is replaced by the debugger.
+	Context *evaluator.Context
+
+	program *ast.Program
+	names   map[*ast.BlockStatement]string
+	client  Client
+
+	// The breakpoints can be changed and a pause requested by another
+	// goroutine while the program runs.
+	mu          sync.Mutex
+	breakpoints map[int]*Breakpoint
+	pause       bool
+
+	// The functions run by spawn and the generators notify the debugger
+	// concurrently, one at a time, all of them waiting while the evaluation
+	// is stopped. Each goroutine has frames of its own.
+	trace      sync.Mutex
+	goroutines map[uint64]*goroutine
+	frames     []*Frame   // of the goroutine stopped, innermost last
+	stepping   *goroutine // the goroutine the action was chosen in
+	action     Action
+	depth      int // the number of frames when the action was chosen
+	entry      bool
+	terminated atomic.Bool
+	evaluating atomic.Bool
+}
+
+// goroutine is a goroutine of the evaluation, along with its call stack.
+type goroutine struct {
+	name   string
+	frames []*Frame // innermost last
+}
+
+func New(program *ast.Program, client Client) *Debugger {
+	return &Debugger{
+		program:     program,
+		names:       ast.FunctionNames(program),
+		client:      client,
+		breakpoints: make(map[int]*Breakpoint),
+		goroutines:  make(map[uint64]*goroutine),
+	}
+}
+
+// terminated unwinds the evaluation when the client terminates it, in each
+// of its goroutines in turn.
+type terminated struct{}
+
+// Run evaluates the program in a new environment with the context of d, whose
+// tracer is d, stopping before the first statement if stopOnEntry is set. It
+// returns nil when the client terminates the evaluation.
+func (d *Debugger) Run(stopOnEntry bool) (result object.Object) {
+	context := evaluator.Context{}
+	if d.Context != nil {
+		context = *d.Context
+	}
+	context.Tracer = d
+	env := object.NewEnvironment()
+	evaluator.SetContext(env, &context)
+	main := &goroutine{name: "main", frames: []*Frame{{Name: "main", Env: env}}}
+	id := goroutineID()
+	d.trace.Lock()
+	d.goroutines[id] = main
+	d.frames, d.stepping = main.frames, main
+	d.trace.Unlock()
+	defer func() {
+		d.trace.Lock()
+		defer d.trace.Unlock()
+		delete(d.goroutines, id)
+	}()
+	d.action, d.entry = CONTINUE, stopOnEntry
+	if stopOnEntry {
+		d.action = STEP_IN
+	}
+	d.terminated.Store(false)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(terminated); !ok {
+				panic(r)
+			}
+			result = nil
+		}
+	}()
+	result = evaluator.Eval(d.program, env)
+	if d.terminated.Load() {
+		// the evaluation ended with the error of a goroutine terminated
+		return nil
+	}
+	return result
+}
+
+// Goroutine evaluates in a goroutine started by spawn or a generator, whose
+// frames are kept apart from the ones of the others. The goroutine ends with
+// an error when the client terminates the evaluation.
+func (d *Debugger) Goroutine(name string, evaluate func() object.Object) (result object.Object) {
+	id := goroutineID()
+	d.trace.Lock()
+	d.goroutines[id] = &goroutine{name: name}
+	d.trace.Unlock()
+	defer func() {
+		d.trace.Lock()
+		defer d.trace.Unlock()
+		delete(d.goroutines, id)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(terminated); !ok {
+				panic(r)
+			}
+			result = &object.Error{Message: "evaluation terminated"}
+		}
+	}()
+	return evaluate()
+}
+
+// goroutineID returns the number of the calling goroutine, which the runtime
+// only tells in the first line of its stack trace, "goroutine 7 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	trace := string(buf[:runtime.Stack(buf[:], false)])
+	id, _ := strconv.ParseUint(strings.Fields(trace)[1], 10, 64)
+	return id
+}
+
+// current returns the calling goroutine, which is one started before the
+// debugger was set as the tracer when it is unknown.
+func (d *Debugger) current() *goroutine {
+	id := goroutineID()
+	g, ok := d.goroutines[id]
+	if !ok {
+		g = &goroutine{name: "?"}
+		d.goroutines[id] = g
+	}
+	return g
+}
+
+// SetBreakpoint sets a breakpoint on a line, replacing the one already there.
+// The condition is an expression evaluated in the environment of the
+// statement, the breakpoint being unconditional when it is empty.
+func (d *Debugger) SetBreakpoint(line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{Line: line, Condition: condition}
+	if strings.TrimSpace(condition) != "" {
+		program, err := parse(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.condition = program
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = bp
+	return bp, nil
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = make(map[int]*Breakpoint)
+}
+
+// Breakpoints returns the breakpoints sorted by line.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bps := []*Breakpoint{}
+	for _, bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	sort.Slice(bps, func(i, j int) bool { return bps[i].Line < bps[j].Line })
+	return bps
+}
+
+// Pause stops the evaluation before its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// Frames returns the frames of the stopped evaluation, innermost first.
+func (d *Debugger) Frames() []*Frame {
+	frames := make([]*Frame, len(d.frames))
+	for i, f := range d.frames {
+		frames[len(frames)-1-i] = f
+	}
+	return frames
+}
+
+// Evaluate evaluates an expression in the environment of a frame, numbered
+// from 0 for the innermost one, while the evaluation is stopped. Failures
+// are returned as errors.
+func (d *Debugger) Evaluate(expression string, frame int) object.Object {
+	if frame < 0 || frame >= len(d.frames) {
+		return &object.Error{Message: "no such frame"}
+	}
+	program, err := parse(expression)
+	if err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	return d.evaluate(program, d.frames[len(d.frames)-1-frame].Env)
+}
+
+// evaluate evaluates code for the debugger itself, which is not traced.
+func (d *Debugger) evaluate(program *ast.Program, env *object.Environment) object.Object {
+	d.evaluating.Store(true)
+	defer d.evaluating.Store(false)
+	return evaluator.Eval(program, env)
+}
+
+func parse(source string) (*ast.Program, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		return nil, p.ParseErrors()[0]
+	}
+	return program, nil
+}
+
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	if d.evaluating.Load() {
+		return
+	}
+	d.trace.Lock()
+	defer d.trace.Unlock()
+	if d.terminated.Load() {
+		panic(terminated{})
+	}
+	g := d.current()
+	if len(g.frames) == 0 {
+		// the goroutine evaluates a body, not a call it was notified of
+		g.frames = append(g.frames, &Frame{Name: g.name})
+	}
+	top := g.frames[len(g.frames)-1]
+	start := ast.StartToken(stmt)
+	top.Line, top.Column, top.Env = start.Line, start.Column, env
+
+	reason, stop := d.shouldStop(g, top)
+	if !stop {
+		return
+	}
+	d.frames = g.frames
+	d.action = d.client.Stopped(d, reason)
+	d.stepping, d.depth = g, len(g.frames)
+	if d.action == TERMINATE {
+		d.terminated.Store(true)
+		panic(terminated{})
+	}
+}
+
+// shouldStop tells whether the evaluation stops at the top frame of g,
+// stepping over and out of the frames of the goroutine stepped through
+// only.
+func (d *Debugger) shouldStop(g *goroutine, top *Frame) (Reason, bool) {
+	d.mu.Lock()
+	pause, bp := d.pause, d.breakpoints[top.Line]
+	d.pause = false
+	d.mu.Unlock()
+
+	if pause {
+		return PAUSE, true
+	}
+	stepped := false
+	switch d.action {
+	case STEP_IN:
+		stepped = true
+	case STEP_OVER:
+		stepped = g == d.stepping && len(g.frames) <= d.depth
+	case STEP_OUT:
+		stepped = g == d.stepping && len(g.frames) < d.depth
+	}
+	if stepped && d.entry {
+		d.entry = false
+		return ENTRY, true
+	}
+	if stepped {
+		return STEP, true
+	}
+	if bp != nil && d.holds(bp, top.Env) {
+		return BREAKPOINT, true
+	}
+	return "", false
+}
+
+// holds reports whether the condition of a breakpoint holds, conditions that
+// fail to evaluate holding so that the user sees the problem.
+func (d *Debugger) holds(bp *Breakpoint, env *object.Environment) bool {
+	if bp.condition == nil {
+		return true
+	}
+	result := d.evaluate(bp.condition, env)
+	return result != object.FALSE && result != object.NULL
+}
+
+func (d *Debugger) Branch(node ast.Expression, way int) {}
+
+func (d *Debugger) Call(call *ast.CallExpression, fn object.Object) {
+	function, ok := fn.(*object.Function)
+	if d.evaluating.Load() || !ok {
+		return
+	}
+	d.trace.Lock()
+	defer d.trace.Unlock()
+	g := d.current()
+	if len(g.frames) > 0 {
+		caller := g.frames[len(g.frames)-1]
+		start := ast.StartToken(call)
+		caller.Line, caller.Column = start.Line, start.Column
+	}
+
+	name, ok := d.names[function.Body]
+	if !ok {
+		name = "?"
+	}
+	g.frames = append(g.frames, &Frame{Name: name, Env: function.Env})
+}
+
+func (d *Debugger) Return(call *ast.CallExpression, fn object.Object) {
+	if _, ok := fn.(*object.Function); d.evaluating.Load() || !ok {
+		return
+	}
+	d.trace.Lock()
+	defer d.trace.Unlock()
+	if g := d.current(); len(g.frames) > 0 {
+		g.frames = g.frames[:len(g.frames)-1]
+	}
+}
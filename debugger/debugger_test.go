@@ -0,0 +1,473 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/lexer"
+	"magot/object"
+	"magot/parser"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testProgram = `let sum = fn(xs) {
+  if (len(xs) == 0) {
+    return 0;
+  }
+  let head = first(xs);
+  head + sum(rest(xs))
+};
+let total = sum([1, 2, 3]);
+total;`
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		t.Fatalf("parse errors: %v", p.ParseErrors())
+	}
+	return program
+}
+
+// stop is what a scripted client saw when the evaluation stopped.
+type stop struct {
+	reason Reason
+	frames string // name:line of each frame, innermost first
+}
+
+// scriptedClient resumes the evaluation with its actions in turn, and
+// terminates it once they run out.
+type scriptedClient struct {
+	actions []Action
+	stops   []stop
+	inspect func(d *Debugger)
+}
+
+func (c *scriptedClient) Stopped(d *Debugger, reason Reason) Action {
+	frames := []string{}
+	for _, f := range d.Frames() {
+		frames = append(frames, fmt.Sprintf("%s:%d", f.Name, f.Line))
+	}
+	c.stops = append(c.stops, stop{reason, strings.Join(frames, " ")})
+	if c.inspect != nil {
+		c.inspect(d)
+	}
+	if len(c.actions) == 0 {
+		return TERMINATE
+	}
+	action := c.actions[0]
+	c.actions = c.actions[1:]
+	return action
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints map[int]string
+		actions     []Action
+		expected    []stop
+	}{
+		{
+			"entry and step over",
+			nil,
+			[]Action{STEP_OVER, STEP_OVER, STEP_OVER},
+			[]stop{
+				{ENTRY, "main:1"},
+				{STEP, "main:8"},
+				{STEP, "main:9"},
+			},
+		},
+		{
+			"step in",
+			nil,
+			[]Action{STEP_OVER, STEP_IN, STEP_IN, STEP_IN},
+			[]stop{
+				{ENTRY, "main:1"},
+				{STEP, "main:8"},
+				{STEP, "sum:2 main:8"},
+				{STEP, "sum:5 main:8"},
+				{STEP, "sum:6 main:8"},
+			},
+		},
+		{
+			"breakpoint",
+			map[int]string{5: ""},
+			[]Action{CONTINUE, CONTINUE, CONTINUE, CONTINUE},
+			[]stop{
+				{ENTRY, "main:1"},
+				{BREAKPOINT, "sum:5 main:8"},
+				{BREAKPOINT, "sum:5 sum:6 main:8"},
+				{BREAKPOINT, "sum:5 sum:6 sum:6 main:8"},
+			},
+		},
+		{
+			"conditional breakpoint",
+			map[int]string{5: "len(xs) == 1"},
+			[]Action{CONTINUE, CONTINUE},
+			[]stop{
+				{ENTRY, "main:1"},
+				{BREAKPOINT, "sum:5 sum:6 sum:6 main:8"},
+			},
+		},
+		{
+			"step out",
+			map[int]string{3: ""},
+			[]Action{CONTINUE, STEP_OUT},
+			[]stop{
+				{ENTRY, "main:1"},
+				{BREAKPOINT, "sum:3 sum:6 sum:6 sum:6 main:8"},
+				{STEP, "main:9"},
+			},
+		},
+		{
+			"step over a breakpoint",
+			map[int]string{5: ""},
+			[]Action{STEP_OVER, STEP_OVER},
+			[]stop{
+				{ENTRY, "main:1"},
+				{STEP, "main:8"},
+				{BREAKPOINT, "sum:5 main:8"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		client := &scriptedClient{actions: tt.actions}
+		d := New(parseProgram(t, testProgram), client)
+		for line, condition := range tt.breakpoints {
+			if _, err := d.SetBreakpoint(line, condition); err != nil {
+				t.Fatalf("%s: SetBreakpoint: %s", tt.name, err)
+			}
+		}
+		d.Run(true)
+
+		if len(client.stops) != len(tt.expected) {
+			t.Errorf("%s: wrong stops. expected=%v, got=%v", tt.name, tt.expected, client.stops)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if client.stops[i] != expected {
+				t.Errorf("%s: wrong stop %d. expected=%v, got=%v", tt.name, i, expected, client.stops[i])
+			}
+		}
+	}
+}
+
+func TestRunResult(t *testing.T) {
+	d := New(parseProgram(t, testProgram), &scriptedClient{actions: []Action{CONTINUE}})
+	result := d.Run(true)
+	integer, ok := result.(*object.Integer)
+	if !ok || integer.Value != 6 {
+		t.Errorf("wrong result. expected=6, got=%v", result)
+	}
+
+	d = New(parseProgram(t, testProgram), &scriptedClient{})
+	if result := d.Run(true); result != nil {
+		t.Errorf("expected no result from a terminated evaluation, got=%v", result)
+	}
+}
+
+func TestGoroutines(t *testing.T) {
+	program := `let double = fn(x) {
+  x * 2
+};
+let count = fn(n) {
+  for (i in range(n)) {
+    yield i;
+  }
+};
+let twice = fn() { collect(count(2)) };
+let results = await [spawn double(1), spawn double(2)];
+twice();`
+	tests := []struct {
+		name     string
+		line     int
+		actions  []Action
+		expected []stop
+	}{
+		{
+			"spawn",
+			2,
+			[]Action{CONTINUE},
+			[]stop{{BREAKPOINT, "double:2"}, {BREAKPOINT, "double:2"}},
+		},
+		{
+			"generator",
+			6,
+			[]Action{STEP_OVER},
+			[]stop{{BREAKPOINT, "generator:6"}, {STEP, "generator:6"}},
+		},
+	}
+
+	for _, tt := range tests {
+		client := &scriptedClient{actions: tt.actions}
+		d := New(parseProgram(t, program), client)
+		d.SetBreakpoint(tt.line, "")
+		if result := d.Run(false); result != nil {
+			t.Errorf("%s: expected no result from a terminated evaluation, got=%v", tt.name, result)
+		}
+
+		if len(client.stops) != len(tt.expected) {
+			t.Errorf("%s: wrong stops. expected=%v, got=%v", tt.name, tt.expected, client.stops)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if client.stops[i] != expected {
+				t.Errorf("%s: wrong stop %d. expected=%v, got=%v", tt.name, i, expected, client.stops[i])
+			}
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		frame      int
+		expected   string
+	}{
+		{"head", 0, "2"},
+		{"len(xs) * 10", 0, "20"},
+		{"xs", 1, "[1, 2, 3]"},
+		{"head", 1, "1"},
+		{"total", 2, "ERROR: identifier not found: total"},
+		{"sum([4, 5])", 2, "9"},
+		{"xs", 3, "ERROR: no such frame"},
+		{"len(", 0, "ERROR: 1:5: no prefix parse function for EOF found"},
+	}
+
+	results := []string{}
+	client := &scriptedClient{
+		actions: []Action{CONTINUE, CONTINUE},
+		inspect: func(d *Debugger) {
+			if len(d.Frames()) == 1 {
+				return
+			}
+			for _, tt := range tests {
+				results = append(results, d.Evaluate(tt.expression, tt.frame).Inspect())
+			}
+		},
+	}
+	d := New(parseProgram(t, testProgram), client)
+	d.SetBreakpoint(6, "len(xs) == 2")
+	d.Run(true)
+
+	if len(results) != len(tests) {
+		t.Fatalf("wrong number of results. expected=%d, got=%d", len(tests), len(results))
+	}
+	for i, tt := range tests {
+		if results[i] != tt.expected {
+			t.Errorf("wrong result for %q in frame %d. expected=%q, got=%q", tt.expression, tt.frame, tt.expected, results[i])
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New(parseProgram(t, testProgram), &scriptedClient{})
+	d.SetBreakpoint(5, "")
+	d.SetBreakpoint(2, "len(xs) == 0")
+	d.SetBreakpoint(5, "head == 2")
+	if _, err := d.SetBreakpoint(3, "len(xs) =="); err == nil {
+		t.Errorf("expected an error for an invalid condition")
+	}
+
+	bps := d.Breakpoints()
+	if len(bps) != 2 {
+		t.Fatalf("wrong number of breakpoints. expected=2, got=%d", len(bps))
+	}
+	if bps[0].Line != 2 || bps[0].Condition != "len(xs) == 0" || bps[1].Line != 5 || bps[1].Condition != "head == 2" {
+		t.Errorf("wrong breakpoints. got=%+v %+v", bps[0], bps[1])
+	}
+	d.ClearBreakpoint(2)
+	if len(d.Breakpoints()) != 1 {
+		t.Errorf("ClearBreakpoint did not remove the breakpoint")
+	}
+	d.ClearBreakpoints()
+	if len(d.Breakpoints()) != 0 {
+		t.Errorf("ClearBreakpoints did not remove the breakpoints")
+	}
+}
+
+func TestConsole(t *testing.T) {
+	input := strings.Join([]string{
+		"b 5 if len(xs) == 1",
+		"breakpoints",
+		"c",
+		"bt",
+		"locals",
+		"f 1",
+		"p xs",
+		"p len(xs) +",
+		"frobnicate",
+		"q",
+	}, "\n")
+	var out strings.Builder
+	console := NewConsole(strings.NewReader(input), &out, "sum.mg", testProgram)
+	if result := New(parseProgram(t, testProgram), console).Run(true); result != nil {
+		t.Errorf("expected the console to terminate the evaluation, got=%v", result)
+	}
+
+	expected := `stopped (entry) in main at sum.mg:1:1
+   1 | let sum = fn(xs) {
+(magot) breakpoint set at sum.mg:5
+(magot) sum.mg:5 if len(xs) == 1
+(magot) stopped (breakpoint) in sum at sum.mg:5:3
+   5 |   let head = first(xs);
+(magot) * 0 sum at sum.mg:5:3
+  1 sum at sum.mg:6:10
+  2 sum at sum.mg:6:10
+  3 main at sum.mg:8:13
+(magot) xs = [3]
+(magot) 1 sum at sum.mg:6:10
+(magot) [2, 3]
+(magot) ERROR: 1:10: no prefix parse function for EOF found
+(magot) unknown command "frobnicate", type help for the list of commands
+(magot) `
+	if out.String() != expected {
+		t.Errorf("wrong console output. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+// dapClient speaks the Debug Adapter Protocol to a session, for tests.
+type dapClient struct {
+	t    *testing.T
+	conn net.Conn
+	in   *bufio.Reader
+	seq  int
+}
+
+func (c *dapClient) request(command string, arguments interface{}) {
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": arguments})
+	fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// receive returns the next message, decoded with its body as a map.
+func (c *dapClient) receive() map[string]interface{} {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var length int
+	if _, err := fmt.Fscanf(c.in, "Content-Length: %d\r\n\r\n", &length); err != nil {
+		c.t.Fatalf("reading header: %s", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.in, data); err != nil {
+		c.t.Fatalf("reading message: %s", err)
+	}
+	message := map[string]interface{}{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		c.t.Fatalf("decoding message: %s", err)
+	}
+	return message
+}
+
+// expect receives the next message and checks that it is the response to
+// command or the event named so, returning its body.
+func (c *dapClient) expect(kind, name string) map[string]interface{} {
+	c.t.Helper()
+	message := c.receive()
+	key := "command"
+	if kind == "event" {
+		key = "event"
+	}
+	if message["type"] != kind || message[key] != name {
+		c.t.Fatalf("expected %s %s, got=%v", kind, name, message)
+	}
+	if kind == "response" && message["success"] != true {
+		c.t.Fatalf("%s failed: %v", name, message["message"])
+	}
+	body, _ := message["body"].(map[string]interface{})
+	return body
+}
+
+func TestDAP(t *testing.T) {
+	server, conn := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- ServeDAP(server, func(file string) (*ast.Program, error) {
+			if file != "sum.mg" {
+				return nil, fmt.Errorf("open %s: no such file", file)
+			}
+			return parseProgram(t, testProgram), nil
+		}, nil)
+	}()
+	c := &dapClient{t: t, conn: conn, in: bufio.NewReader(conn)}
+
+	c.request("initialize", map[string]string{"adapterID": "magot"})
+	if body := c.expect("response", "initialize"); body["supportsConditionalBreakpoints"] != true {
+		t.Errorf("expected conditional breakpoints to be supported, got=%v", body)
+	}
+	c.expect("event", "initialized")
+
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "sum.mg"},
+		"breakpoints": []map[string]interface{}{{"line": 5, "condition": "len(xs) == 2"}},
+	})
+	c.expect("response", "setBreakpoints")
+	c.request("launch", map[string]interface{}{"program": "missing.mg"})
+	if message := c.receive(); message["success"] != false {
+		t.Errorf("expected launching a missing program to fail, got=%v", message)
+	}
+	c.request("launch", map[string]interface{}{"program": "sum.mg"})
+	c.expect("response", "launch")
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone")
+
+	if body := c.expect("event", "stopped"); body["reason"] != "breakpoint" {
+		t.Errorf("wrong stop reason. got=%v", body["reason"])
+	}
+	c.request("stackTrace", map[string]int{"threadId": 1})
+	frames := c.expect("response", "stackTrace")["stackFrames"].([]interface{})
+	if len(frames) != 3 {
+		t.Fatalf("wrong number of frames. expected=3, got=%d", len(frames))
+	}
+	top := frames[0].(map[string]interface{})
+	if top["name"] != "sum" || top["line"] != 5.0 || top["id"] != 1.0 {
+		t.Errorf("wrong top frame. got=%v", top)
+	}
+
+	c.request("scopes", map[string]int{"frameId": 1})
+	scopes := c.expect("response", "scopes")["scopes"].([]interface{})
+	locals := scopes[0].(map[string]interface{})
+	if len(scopes) != 2 || locals["name"] != "Locals" || scopes[1].(map[string]interface{})["name"] != "Globals" {
+		t.Fatalf("wrong scopes. got=%v", scopes)
+	}
+	c.request("variables", map[string]interface{}{"variablesReference": locals["variablesReference"]})
+	variables := c.expect("response", "variables")["variables"].([]interface{})
+	xs := variables[0].(map[string]interface{})
+	if len(variables) != 1 || xs["name"] != "xs" || xs["value"] != "[2, 3]" {
+		t.Fatalf("wrong variables. got=%v", variables)
+	}
+	c.request("variables", map[string]interface{}{"variablesReference": xs["variablesReference"]})
+	elements := c.expect("response", "variables")["variables"].([]interface{})
+	if len(elements) != 2 || elements[1].(map[string]interface{})["value"] != "3" {
+		t.Errorf("wrong elements. got=%v", elements)
+	}
+
+	c.request("evaluate", map[string]interface{}{"expression": "first(xs) * 10", "frameId": 1})
+	if body := c.expect("response", "evaluate"); body["result"] != "20" {
+		t.Errorf("wrong evaluation. expected=20, got=%v", body["result"])
+	}
+
+	c.request("next", map[string]int{"threadId": 1})
+	c.expect("response", "next")
+	if body := c.expect("event", "stopped"); body["reason"] != "step" {
+		t.Errorf("wrong stop reason. got=%v", body["reason"])
+	}
+	c.request("continue", map[string]int{"threadId": 1})
+	c.expect("response", "continue")
+	if body := c.expect("event", "exited"); body["exitCode"] != 0.0 {
+		t.Errorf("wrong exit code. got=%v", body["exitCode"])
+	}
+	c.expect("event", "terminated")
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect")
+	if err := <-done; err != nil {
+		t.Errorf("ServeDAP: %s", err)
+	}
+}
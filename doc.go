@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"magot/doc"
+	"magot/lexer"
+	"magot/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func docCommand(args []string) int {
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	html := flags.Bool("html", false, "write an HTML page instead of Markdown")
+	builtins := flags.Bool("builtins", false, "document the builtin functions, after the files if any are given")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: magot doc [flags] [file.mg or directory...]\n\n")
+		fmt.Fprintf(os.Stderr, "Prints the documentation of the top-level functions of Magot files, which\n")
+		fmt.Fprintf(os.Stderr, "is the // comment lines right above the let statements defining them.\n")
+		fmt.Fprintf(os.Stderr, "Directories are searched for *.mg files other than tests, and the current\n")
+		fmt.Fprintf(os.Stderr, "directory is when neither files nor -builtins are given.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 && !*builtins {
+		paths = []string{"."}
+	}
+	files, err := docFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	docs := []*doc.File{}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		p := parser.New(lexer.New(string(source)))
+		program := p.ParseProgram()
+		if len(p.ParseErrors()) != 0 {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", file, p.ParseErrors()[0])
+			return 1
+		}
+		docs = append(docs, doc.Read(file, program))
+	}
+	if *builtins {
+		docs = append(docs, doc.Builtins())
+	}
+
+	if *html {
+		err = doc.WriteHTML(os.Stdout, docs)
+	} else {
+		err = doc.WriteMarkdown(os.Stdout, docs)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// docFiles returns the files given and the *.mg files but the tests found in
+// the directories given.
+func docFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(file, ".mg") && !strings.HasSuffix(file, "_test.mg") {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,118 @@
+// Package doc extracts the documentation of the top-level functions of Magot
+// programs, which is the comment lines above the let statements defining
+// them, and renders it as Markdown or HTML.
+package doc
+
+import (
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/evaluator"
+	"sort"
+	"strings"
+)
+
+// BUILTINS names the file documenting the builtin functions.
+const BUILTINS = "builtins"
+
+// Function is a top-level function or macro of a file.
+type Function struct {
+	Name   string
+	Params []string
+	Doc    string
+	Macro  bool
+	Line   int
+}
+
+// Signature returns the name and the parameters of the function, the way it
+// is called.
+func (f Function) Signature() string {
+	return f.Name + "(" + strings.Join(f.Params, ", ") + ")"
+}
+
+// File lists the functions of a file in the order they are defined.
+type File struct {
+	Name      string
+	Functions []Function
+}
+
+// Read returns the functions and macros bound by the top-level let statements
+// of program, which must not have had its macros expanded.
+func Read(name string, program *ast.Program) *File {
+	file := &File{Name: name, Functions: []Function{}}
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		fn := Function{Name: let.Name.Value, Doc: let.Token.Doc, Line: let.Token.Line}
+		var params []*ast.Identifier
+		switch value := let.Value.(type) {
+		case *ast.FunctionLiteral:
+			params = value.Parameters
+		case *ast.MacroLiteral:
+			params, fn.Macro = value.Parameters, true
+		default:
+			continue
+		}
+		fn.Params = []string{}
+		for _, param := range params {
+			fn.Params = append(fn.Params, param.Value)
+		}
+		file.Functions = append(file.Functions, fn)
+	}
+	return file
+}
+
+// Builtins returns the builtin functions of the evaluator, sorted by name,
+// followed by the functions of its modules. The names and the parameters of
+// the functions are the ones of their usage, such as fs["read_file"](path).
+func Builtins() *File {
+	names := evaluator.BuiltinNames()
+	for _, module := range evaluator.ModuleNames() {
+		hash, _ := evaluator.LookupModule(module)
+		keys := []string{}
+		for _, pair := range hash.Pairs {
+			keys = append(keys, module+"."+pair.Key.Inspect())
+		}
+		sort.Strings(keys)
+		names = append(names, keys...)
+	}
+
+	file := &File{Name: BUILTINS, Functions: []Function{}}
+	for _, name := range names {
+		builtin, _ := evaluator.LookupBuiltin(name)
+		open := strings.Index(builtin.Usage, "(")
+		params := []string{}
+		if args := strings.TrimSuffix(builtin.Usage[open+1:], ")"); args != "" {
+			params = strings.Split(args, ", ")
+		}
+		file.Functions = append(file.Functions, Function{Name: builtin.Usage[:open], Params: params, Doc: builtin.Doc})
+	}
+	return file
+}
+
+// WriteMarkdown writes the documentation of the files, each of them as a
+// section with a subsection per function.
+func WriteMarkdown(w io.Writer, files []*File) error {
+	var out strings.Builder
+	for i, file := range files {
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "# %s\n", file.Name)
+		for _, fn := range file.Functions {
+			fmt.Fprintf(&out, "\n## %s\n\n", fn.Name)
+			kind := ""
+			if fn.Macro {
+				kind = "macro "
+			}
+			fmt.Fprintf(&out, "```\n%s%s\n```\n", kind, fn.Signature())
+			if fn.Doc != "" {
+				fmt.Fprintf(&out, "\n%s\n", fn.Doc)
+			}
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
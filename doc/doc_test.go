@@ -0,0 +1,94 @@
+package doc
+
+import (
+	"magot/lexer"
+	"magot/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `// Helpers for lists.
+
+// map applies f to each element of xs.
+//
+//   map([1, 2], fn(x) { x * 2 })
+let map = fn(xs, f) { xs };
+let answer = 42;
+let undocumented = fn() { 1 };
+// unless evaluates body unless cond holds.
+let unless = macro(cond, body) { quote(1) };`
+
+func readTestFile(t *testing.T) *File {
+	p := parser.New(lexer.New(testSource))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		t.Fatalf("parse errors: %v", p.ParseErrors())
+	}
+	return Read("lists.mg", program)
+}
+
+func TestRead(t *testing.T) {
+	expected := []Function{
+		{Name: "map", Params: []string{"xs", "f"}, Doc: "map applies f to each element of xs.\n\n  map([1, 2], fn(x) { x * 2 })", Line: 6},
+		{Name: "undocumented", Params: []string{}, Line: 8},
+		{Name: "unless", Params: []string{"cond", "body"}, Doc: "unless evaluates body unless cond holds.", Macro: true, Line: 10},
+	}
+	file := readTestFile(t)
+	if file.Name != "lists.mg" || !reflect.DeepEqual(file.Functions, expected) {
+		t.Errorf("wrong file. expected=%+v, got=%+v", expected, file.Functions)
+	}
+	if signature := file.Functions[0].Signature(); signature != "map(xs, f)" {
+		t.Errorf("wrong signature. expected=%q, got=%q", "map(xs, f)", signature)
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	file := Builtins()
+	signatures := map[string]string{}
+	for _, fn := range file.Functions {
+		signatures[fn.Name] = fn.Signature()
+		if fn.Doc == "" {
+			t.Errorf("builtin %s has no doc", fn.Name)
+		}
+	}
+	for name, expected := range map[string]string{"len": "len(value)", "push": "push(array, value)", "assert": "assert(condition, message?)"} {
+		if signatures[name] != expected {
+			t.Errorf("wrong signature of %s. expected=%q, got=%q", name, expected, signatures[name])
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var out strings.Builder
+	if err := WriteMarkdown(&out, []*File{readTestFile(t)}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# lists.mg\n" +
+		"\n## map\n\n```\nmap(xs, f)\n```\n\nmap applies f to each element of xs.\n\n  map([1, 2], fn(x) { x * 2 })\n" +
+		"\n## undocumented\n\n```\nundocumented()\n```\n" +
+		"\n## unless\n\n```\nmacro unless(cond, body)\n```\n\nunless evaluates body unless cond holds.\n"
+	if out.String() != expected {
+		t.Errorf("wrong Markdown. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var out strings.Builder
+	if err := WriteHTML(&out, []*File{readTestFile(t)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<h1>lists.mg</h1>`,
+		`<li><a href="#f0-map">map</a></li>`,
+		`<h2 id="f0-map">map</h2>`,
+		`<pre class="signature">map(xs, f)</pre>`,
+		`<p>map applies f to each element of xs.</p>`,
+		`<pre>  map([1, 2], fn(x) { x * 2 })</pre>`,
+		`<pre class="signature"><span class="kind">macro </span>unless(cond, body)</pre>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("HTML does not contain %q:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package doc
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlPage = template.Must(template.New("page").Funcs(template.FuncMap{"blocks": blocks}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Magot documentation</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: auto; }
+pre { font-family: monospace; background: #f4f4f4; padding: 0.5em; }
+.signature { font-weight: bold; }
+.kind { color: #888; }
+</style>
+</head>
+<body>
+{{range $i, $file := .}}
+<h1>{{.Name}}</h1>
+<ul>
+{{range .Functions}}<li><a href="#f{{$i}}-{{.Name}}">{{.Name}}</a></li>
+{{end}}</ul>
+{{range .Functions}}
+<h2 id="f{{$i}}-{{.Name}}">{{.Name}}</h2>
+<pre class="signature">{{if .Macro}}<span class="kind">macro </span>{{end}}{{.Signature}}</pre>
+{{range blocks .Doc}}{{if .Code}}<pre>{{.Text}}</pre>{{else}}<p>{{.Text}}</p>{{end}}
+{{end}}{{end}}{{end}}
+</body>
+</html>
+`))
+
+// block is a paragraph of a doc, or a block of code when all its lines are
+// indented.
+type block struct {
+	Text string
+	Code bool
+}
+
+// blocks splits a doc into its blocks, which blank lines separate.
+func blocks(doc string) []block {
+	result := []block{}
+	for _, text := range strings.Split(doc, "\n\n") {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		code := true
+		for _, line := range strings.Split(text, "\n") {
+			if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+				code = false
+			}
+		}
+		result = append(result, block{Text: text, Code: code})
+	}
+	return result
+}
+
+// WriteHTML writes the documentation of the files as an HTML page, each file
+// starting with the list of its functions.
+func WriteHTML(w io.Writer, files []*File) error {
+	return htmlPage.Execute(w, files)
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"fmt"
+	"magot/object"
+	"strings"
+)
+
+// The assertion builtins call functions, which refers back to the builtins
+// table, so they are added to it once it is initialized.
+func init() {
+	builtins["assert"] = &object.Builtin{
+		Name:  "assert",
+		Usage: "assert(condition, message?)",
+		Doc:   "Fails with message unless condition is truthy.",
+		Fn:    assert,
+	}
+	builtins["assert_eq"] = &object.Builtin{
+		Name:  "assert_eq",
+		Usage: "assert_eq(actual, expected)",
+		Doc:   "Fails unless the values are equal, arrays and hashes being compared element\nby element.",
+		Fn:    assertEqual,
+	}
+	builtins["assert_error"] = &object.Builtin{
+		Name:  "assert_error",
+		Usage: "assert_error(fn, substring?)",
+		Doc:   "Calls fn without arguments and fails unless it returns an error, whose\nmessage must contain substring if given.",
+		Fn:    assertError,
+	}
+}
+
+// assert(condition, message?) fails when condition is not truthy.
+func assert(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments, got=%d, want=1 or 2", len(args))
+	}
+	if isTruthy(args[0]) {
+		return NULL
+	}
+	if len(args) == 2 {
+		message, ok := args[1].(*object.String)
+		if !ok {
+			return newError("argument to 'assert' must be STRING, got %s", args[1].Type())
+		}
+		return newError("assertion failed: %s", message.Value)
+	}
+	return newError("assertion failed")
+}
+
+// assert_eq(actual, expected) fails when the values differ, arrays and hashes
+// being compared element by element.
+func assertEqual(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments, got=%d, want=2", len(args))
+	}
+	if !objectsEqual(args[0], args[1]) {
+		return newError("assertion failed: expected %s, got %s", describe(args[1]), describe(args[0]))
+	}
+	return NULL
+}
+
+// assert_error(fn, substring?) calls fn without arguments and fails unless it
+// returns an error, whose message must then contain substring if given.
+func assertError(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments, got=%d, want=1 or 2", len(args))
+	}
+	if fn, ok := args[0].(*object.Function); ok && len(fn.Parameters) != 0 {
+		return newError("argument to 'assert_error' must take no arguments, got %d parameters", len(fn.Parameters))
+	}
+	substring := ""
+	if len(args) == 2 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return newError("argument to 'assert_error' must be STRING, got %s", args[1].Type())
+		}
+		substring = str.Value
+	}
+
+	result := applyFunction(env, args[0], nil)
+	err, ok := result.(*object.Error)
+	switch {
+	case !ok:
+		return newError("assertion failed: expected an error, got %s", describe(result))
+	case !strings.Contains(err.Message, substring):
+		return newError("assertion failed: expected an error containing %q, got %q", substring, err.Message)
+	}
+	return NULL
+}
+
+func objectsEqual(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.BigInt:
+		b, ok := b.(*object.BigInt)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *object.Time:
+		b, ok := b.(*object.Time)
+		return ok && a.Value.Equal(b.Value)
+	case *object.Duration:
+		b, ok := b.(*object.Duration)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !objectsEqual(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !objectsEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// describe returns the representation of a value in assertion messages,
+// strings being quoted to tell them apart from other values.
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "nothing"
+	}
+	if str, ok := obj.(*object.String); ok {
+		return fmt.Sprintf("%q", str.Value)
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"magot/object"
+	"math"
+	"math/big"
+)
+
+// isInteger reports whether obj is an INTEGER or a BIGINT.
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return true
+	}
+	return false
+}
+
+// bigValue returns the value of an INTEGER or a BIGINT, which must not be
+// modified.
+func bigValue(obj object.Object) *big.Int {
+	if i, ok := obj.(*object.Integer); ok {
+		return big.NewInt(i.Value)
+	}
+	return obj.(*object.BigInt).Value
+}
+
+// newBigInteger returns value as an Integer when it is in its range, and as a
+// BigInt otherwise.
+func newBigInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+// evalIntegerArithmetic computes the arithmetic operations on Integers,
+// falling back to big integers when the result overflows.
+func evalIntegerArithmetic(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+	switch operator {
+	case "+":
+		if sum := leftVal + rightVal; (sum > leftVal) == (rightVal > 0) {
+			return &object.Integer{Value: sum}
+		}
+	case "-":
+		if difference := leftVal - rightVal; (difference < leftVal) == (rightVal > 0) {
+			return &object.Integer{Value: difference}
+		}
+	case "*":
+		product := leftVal * rightVal
+		if leftVal == 0 || (product/leftVal == rightVal && !(leftVal == -1 && rightVal == math.MinInt64)) {
+			return &object.Integer{Value: product}
+		}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal != math.MinInt64 || rightVal != -1 {
+			return &object.Integer{Value: leftVal / rightVal}
+		}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	}
+	return evalBigIntInfixExpression(operator, left, right)
+}
+
+// evalBigIntInfixExpression evaluates the operations on integers one of which
+// at least is a BigInt. Divisions truncate toward zero like the ones of
+// Integers.
+func evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, rightVal := bigValue(left), bigValue(right)
+	switch operator {
+	case "+":
+		return newBigInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return newBigInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return newBigInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/", "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		if operator == "/" {
+			return newBigInteger(new(big.Int).Quo(leftVal, rightVal))
+		}
+		return newBigInteger(new(big.Int).Rem(leftVal, rightVal))
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
@@ -2,12 +2,17 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
 	"magot/object"
+	"sort"
+	"strings"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Usage: "len(value)",
+		Doc:   "Returns the number of bytes of a string or the number of elements of an array.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments, got=%d, want=1", len(args))
 			}
@@ -22,7 +27,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Usage: "first(array)",
+		Doc:   "Returns the first element of an array, or null when it is empty.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments, got=%d, want=1", len(args))
 			}
@@ -37,7 +44,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Usage: "last(array)",
+		Doc:   "Returns the last element of an array, or null when it is empty.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments, got=%d, want=1", len(args))
 			}
@@ -52,7 +61,9 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Usage: "rest(array)",
+		Doc:   "Returns a new array holding all the elements of an array but the first one,\nor null when it is empty.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments, got=%d, want=1", len(args))
 			}
@@ -70,12 +81,14 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments, got=%d, want=1", len(args))
+		Usage: "push(array, value)",
+		Doc:   "Returns a new array holding the elements of an array followed by value.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments, got=%d, want=2", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'rest' must be ARRAY, got %s", args[0].Type())
+				return newError("argument to 'push' must be ARRAY, got %s", args[0].Type())
 			}
 			array := args[0].(*object.Array)
 			length := len(array.Elements)
@@ -86,11 +99,146 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Usage: "puts(values...)",
+		Doc:   "Prints each value on its own line and returns null.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			e := evaluationOf(env)
+			e.streamLock.Lock()
+			defer e.streamLock.Unlock()
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(e.Stdout, arg.Inspect())
 			}
 			return NULL
 		},
 	},
+	"print": &object.Builtin{
+		Usage: "print(values...)",
+		Doc:   "Prints the values on one line, separated by spaces, and returns null.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			e := evaluationOf(env)
+			printLine(e, e.Stdout, args)
+			return NULL
+		},
+	},
+	"eprint": &object.Builtin{
+		Usage: "eprint(values...)",
+		Doc:   "Prints the values on one line of the standard error, separated by spaces,\nand returns null.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			e := evaluationOf(env)
+			printLine(e, e.Stderr, args)
+			return NULL
+		},
+	},
+	"read_line": &object.Builtin{
+		Usage: "read_line()",
+		Doc:   "Returns the next line of the standard input without its line terminator, or\nnull at the end of the input.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments, got=%d, want=0", len(args))
+			}
+			e := evaluationOf(env)
+			e.streamLock.Lock()
+			defer e.streamLock.Unlock()
+			return readLine(e)
+		},
+	},
+	"input": &object.Builtin{
+		Usage: "input(prompt?)",
+		Doc:   "Prints prompt, without a newline, and returns the next line of the standard\ninput like read_line does.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments, got=%d, want=0 or 1", len(args))
+			}
+			var prompt string
+			if len(args) == 1 {
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to 'input' must be STRING, got %s", args[0].Type())
+				}
+				prompt = str.Value
+			}
+			e := evaluationOf(env)
+			e.streamLock.Lock()
+			defer e.streamLock.Unlock()
+			io.WriteString(e.Stdout, prompt)
+			return readLine(e)
+		},
+	},
+	"help": &object.Builtin{
+		Usage: "help(fn)",
+		Doc:   "Returns the usage and the documentation of a function, which are the\ncomment lines above the let statement defining it.",
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments, got=%d, want=1", len(args))
+			}
+			usage, doc := "", ""
+			switch fn := args[0].(type) {
+			case *object.Builtin:
+				usage, doc = fn.Usage, fn.Doc
+			case *object.Function:
+				params := []string{}
+				for _, param := range fn.Parameters {
+					params = append(params, param.Value)
+				}
+				usage, doc = "fn("+strings.Join(params, ", ")+")", fn.Doc
+			default:
+				return newError("argument to 'help' must be FUNCTION or BUILTIN, got %s", args[0].Type())
+			}
+			if doc == "" {
+				return &object.String{Value: usage}
+			}
+			return &object.String{Value: usage + "\n\n" + doc}
+		},
+	},
+}
+
+func init() {
+	for name, builtin := range builtins {
+		builtin.Name = name
+	}
+}
+
+// printLine writes the values inspected to w, a stream of the context of e,
+// separated by spaces and followed by a newline.
+func printLine(e *evaluation, w io.Writer, values []object.Object) {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = value.Inspect()
+	}
+	e.streamLock.Lock()
+	defer e.streamLock.Unlock()
+	fmt.Fprintln(w, strings.Join(strs, " "))
+}
+
+// readLine reads a line of the standard input of the context of e, with the
+// stream lock of e held.
+func readLine(e *evaluation) object.Object {
+	line, err := e.lines.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return NULL
+	}
+	if err != nil && err != io.EOF {
+		return newError("%s", err)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return &object.String{Value: strings.TrimSuffix(line, "\r")}
+}
+
+// LookupBuiltin returns the builtin function called name, the functions of
+// the modules being called module.key.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	return moduleFunction(name)
+}
+
+// BuiltinNames returns the names of the builtin functions, sorted.
+func BuiltinNames() []string {
+	names := []string{}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,347 @@
+package evaluator
+
+import (
+	"magot/ast"
+	"magot/object"
+	"reflect"
+	"sync"
+	"time"
+)
+
+func init() {
+	for _, builtin := range []*object.Builtin{
+		{
+			Name:  "channel",
+			Usage: "channel(capacity?)",
+			Doc:   "Returns a channel buffering up to capacity values, 0 by default and 65536 at\nmost, for the functions run by spawn to send values to each other.",
+			Fn:    channelNew,
+		},
+		{
+			Name:  "send",
+			Usage: "send(ch, value)",
+			Doc:   "Sends a value on a channel, waiting until its buffer has room for it or a\nreceiver takes it, and returns null. Sending on a closed channel is an\nerror, as is waiting while all the goroutines of the program wait.",
+			Fn:    channelSend,
+		},
+		{
+			Name:  "recv",
+			Usage: "recv(ch)",
+			Doc:   "Returns the next value sent on a channel, waiting until there is one, or\nnull once the channel is closed and its buffer is empty. Waiting while all the\ngoroutines of the program wait is an error.",
+			Fn:    channelRecv,
+		},
+		{
+			Name:  "close",
+			Usage: "close(ch)",
+			Doc:   "Closes a channel, telling its receivers that no more values will be sent,\nand returns null.",
+			Fn:    channelClose,
+		},
+	} {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+// evalSpawnExpression applies a function in a new goroutine and returns the
+// future of its result. The operand of spawn is either a call, whose function
+// and arguments are evaluated beforehand, or a function taking no arguments.
+func evalSpawnExpression(node ast.Expression, env *object.Environment) object.Object {
+	var function object.Object
+	var args []object.Object
+	call, ok := node.(*ast.CallExpression)
+	if ok && call.Function.TokenLiteral() != "quote" {
+		function = Eval(call.Function, env)
+		if isError(function) {
+			return function
+		}
+		args = evalExpressions(call.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+	} else {
+		call = nil
+		function = Eval(node, env)
+		if isError(function) {
+			return function
+		}
+	}
+
+	switch fn := function.(type) {
+	case *object.Function:
+		if len(args) < len(fn.Parameters) {
+			return newError("wrong number of arguments, got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+	case *object.Builtin:
+	default:
+		return newError("not a function: %s", function.Type())
+	}
+
+	future := object.NewFuture()
+	scheduler := &evaluationOf(env).scheduler
+	scheduler.started(false)
+	go func() {
+		defer scheduler.ended(false)
+		future.Resolve(orNull(traceGoroutine(env, "spawn", func() object.Object {
+			return callFunction(call, function, args, env)
+		})))
+	}()
+	return future
+}
+
+// evalAwaitExpression waits for a future, or for each future of an array, and
+// returns its value, the first error of an array's failing it.
+func evalAwaitExpression(value object.Object, env *object.Environment) object.Object {
+	switch value := value.(type) {
+	case *object.Future:
+		return awaitFuture(value, env)
+	case *object.Array:
+		results := make([]object.Object, len(value.Elements))
+		for i, element := range value.Elements {
+			future, ok := element.(*object.Future)
+			if !ok {
+				return newError("cannot await %s", element.Type())
+			}
+			results[i] = awaitFuture(future, env)
+			if isError(results[i]) {
+				return results[i]
+			}
+		}
+		return &object.Array{Elements: results}
+	}
+	return newError("cannot await %s", value.Type())
+}
+
+// awaitFuture waits for a future and returns its value, or the error of a
+// deadlock.
+func awaitFuture(future *object.Future, env *object.Environment) object.Object {
+	done := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(future.Done())}
+	if _, _, _, err := selectChannels(env, []reflect.SelectCase{done}); err != nil {
+		return err
+	}
+	return future.Await()
+}
+
+// evalSelectExpression waits until one of the cases of a select expression
+// can proceed, choosing one at random when several can, or takes its default
+// case when it has one and none can, and evaluates the block of the case.
+func evalSelectExpression(node *ast.SelectExpression, env *object.Environment) object.Object {
+	cases := make([]reflect.SelectCase, 0, len(node.Cases)+1)
+	for _, c := range node.Cases {
+		args := evalExpressions(c.Operation.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		name := c.Operation.Function.TokenLiteral()
+		want := 1
+		if name == "send" {
+			want = 2
+		}
+		ch, err := channelArgument(name, args, want)
+		if err != nil {
+			return err
+		}
+		selectCase := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Values)}
+		if name == "send" {
+			selectCase.Dir, selectCase.Send = reflect.SelectSend, reflect.ValueOf(orNull(args[1]))
+		}
+		cases = append(cases, selectCase)
+	}
+	if node.Default != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	chosen, value, ok, err := selectChannels(env, cases)
+	if err != nil {
+		return err
+	}
+	if tracer := evaluationOf(env).Tracer; tracer != nil {
+		tracer.Branch(node, chosen)
+	}
+	if chosen == len(node.Cases) {
+		return Eval(node.Default, env)
+	}
+	c := node.Cases[chosen]
+	if c.Name != nil {
+		var received object.Object = NULL
+		if ok {
+			received = value.Interface().(object.Object)
+		}
+		env.Set(c.Name.Value, received)
+	}
+	return Eval(c.Body, env)
+}
+
+// selectChannels is reflect.Select failing rather than panicking when a case
+// sends on a closed channel, and failing when the goroutines of the
+// evaluation in env all wait, as none could then proceed.
+func selectChannels(env *object.Environment, cases []reflect.SelectCase) (chosen int, value reflect.Value, ok bool, err *object.Error) {
+	defer func() {
+		if recover() != nil {
+			err = newError("send on closed channel")
+		}
+	}()
+	if cases[len(cases)-1].Dir == reflect.SelectDefault {
+		chosen, value, ok = reflect.Select(cases)
+		return chosen, value, ok, nil
+	}
+	return evaluationOf(env).scheduler.wait(cases)
+}
+
+// channelArgument checks the arguments of the channel builtin called name,
+// the first of which is a channel, and returns it.
+func channelArgument(name string, args []object.Object, want int) (*object.Channel, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments, got=%d, want=%d", len(args), want)
+	}
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return nil, newError("argument to '%s' must be CHANNEL, got %s", name, args[0].Type())
+	}
+	return ch, nil
+}
+
+// MAX_CHANNEL_CAPACITY bounds the buffers of channels, which are allocated
+// upfront.
+const MAX_CHANNEL_CAPACITY = 1 << 16
+
+func channelNew(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments, got=%d, want=0 or 1", len(args))
+	}
+	var capacity int64
+	if len(args) == 1 {
+		n, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to 'channel' must be INTEGER, got %s", args[0].Type())
+		}
+		if n.Value < 0 {
+			return newError("argument to 'channel' must not be negative, got %d", n.Value)
+		}
+		if n.Value > MAX_CHANNEL_CAPACITY {
+			return newError("argument to 'channel' must be at most %d, got %d", MAX_CHANNEL_CAPACITY, n.Value)
+		}
+		capacity = n.Value
+	}
+	return &object.Channel{Values: make(chan object.Object, capacity)}
+}
+
+func channelSend(env *object.Environment, args ...object.Object) object.Object {
+	ch, err := channelArgument("send", args, 2)
+	if err != nil {
+		return err
+	}
+	send := reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.Values), Send: reflect.ValueOf(orNull(args[1]))}
+	if _, _, _, err := selectChannels(env, []reflect.SelectCase{send}); err != nil {
+		return err
+	}
+	return NULL
+}
+
+func channelRecv(env *object.Environment, args ...object.Object) object.Object {
+	ch, err := channelArgument("recv", args, 1)
+	if err != nil {
+		return err
+	}
+	recv := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Values)}
+	_, value, ok, err := selectChannels(env, []reflect.SelectCase{recv})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return NULL
+	}
+	return value.Interface().(object.Object)
+}
+
+func channelClose(env *object.Environment, args ...object.Object) (result object.Object) {
+	ch, err := channelArgument("close", args, 1)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if recover() != nil {
+			result = newError("close of closed channel")
+		}
+	}()
+	close(ch.Values)
+	return NULL
+}
+
+// DEADLOCK_CHECK is the interval at which the goroutines waiting on channels
+// or futures check whether all the goroutines of their evaluation wait. They
+// fail once that lasted two checks in a row, the goroutines woken meanwhile
+// having had the time to tell they were.
+const DEADLOCK_CHECK = 50 * time.Millisecond
+
+// scheduler counts the goroutines of an evaluation that evaluate a program or
+// a function spawn runs, along with the ones of them waiting on channels or
+// futures, to tell the deadlocks the Go runtime would make fatal. The
+// goroutine of a generator runs in place of the one waiting for its next
+// value, and is not counted. The goroutines spawn starts are only told to be
+// deadlocked while a program is being evaluated, as the REPL may make them
+// proceed once it has evaluated its input.
+type scheduler struct {
+	mu       sync.Mutex
+	programs int
+	spawned  int
+	waiting  int
+	woken    int // the number of waits that ended, telling progress
+}
+
+// started counts a goroutine starting to evaluate a program or, when program
+// is false, a function run by spawn.
+func (s *scheduler) started(program bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if program {
+		s.programs++
+	} else {
+		s.spawned++
+	}
+}
+
+// ended counts a goroutine counted by started ending.
+func (s *scheduler) ended(program bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if program {
+		s.programs--
+	} else {
+		s.spawned--
+	}
+}
+
+// wait is reflect.Select, failing when the goroutines of the evaluation all
+// wait.
+func (s *scheduler) wait(cases []reflect.SelectCase) (int, reflect.Value, bool, *object.Error) {
+	ready := append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	if chosen, value, ok := reflect.Select(ready); chosen < len(cases) {
+		return chosen, value, ok, nil
+	}
+
+	ticker := time.NewTicker(DEADLOCK_CHECK)
+	defer ticker.Stop()
+	cases = append(cases[:len(cases):len(cases)], reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ticker.C)})
+	s.mu.Lock()
+	s.waiting++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.waiting--
+		s.woken++
+	}()
+
+	stalled, woken := false, 0
+	for {
+		chosen, value, ok := reflect.Select(cases)
+		if chosen < len(cases)-1 {
+			return chosen, value, ok, nil
+		}
+		s.mu.Lock()
+		deadlocked := s.programs > 0 && s.waiting == s.programs+s.spawned
+		if deadlocked && stalled && s.woken == woken {
+			s.mu.Unlock()
+			return 0, reflect.Value{}, false, newError("all goroutines are asleep - deadlock")
+		}
+		stalled, woken = deadlocked, s.woken
+		s.mu.Unlock()
+	}
+}
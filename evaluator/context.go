@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"bufio"
+	"io"
+	"magot/object"
+	"magot/sandbox"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Context holds the resources of the host that an evaluation uses: the
+// standard streams, the directories the fs module may access, the source of
+// random numbers of the math module, the clock telling the time and the
+// tracer notified of the progress of the evaluation.
+type Context struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+	Files  *sandbox.Sandbox
+	Random *rand.Rand
+	Now    func() time.Time
+	Tracer Tracer // nil disabling tracing
+}
+
+// NewContext returns a context on the standard streams and the clock of the
+// process, which grants no access to files, whose random numbers are seeded
+// with the current time and which traces nothing.
+func NewContext() *Context {
+	return &Context{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Stdin:  os.Stdin,
+		Files:  sandbox.New(),
+		Random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		Now:    time.Now,
+	}
+}
+
+// evaluation is a context set on an environment, along with the state the
+// evaluations in it share.
+type evaluation struct {
+	Context
+	lines           *bufio.Reader // Stdin, buffered for read_line
+	callTracer      CallTracer
+	goroutineTracer GoroutineTracer
+	scheduler       scheduler
+
+	// streamLock serializes the uses of the standard streams by the
+	// functions spawn runs concurrently, and randomLock the ones of Random.
+	streamLock sync.Mutex
+	randomLock sync.Mutex
+}
+
+// process is the evaluation of the environments without a context, which
+// is the one of NewContext.
+var process = newEvaluation(NewContext())
+
+// SetContext sets the context of the evaluations in env, a global
+// environment, and in the environments enclosing it, the fields of c left
+// nil falling back to the ones of NewContext. Stdin is read through a buffer
+// unless it is a *bufio.Reader, which hosts reading the lines following the
+// ones a program reads must then share with it.
+func SetContext(env *object.Environment, c *Context) {
+	ctx := NewContext()
+	if c != nil {
+		if c.Stdout != nil {
+			ctx.Stdout = c.Stdout
+		}
+		if c.Stderr != nil {
+			ctx.Stderr = c.Stderr
+		}
+		if c.Stdin != nil {
+			ctx.Stdin = c.Stdin
+		}
+		if c.Files != nil {
+			ctx.Files = c.Files
+		}
+		if c.Random != nil {
+			ctx.Random = c.Random
+		}
+		if c.Now != nil {
+			ctx.Now = c.Now
+		}
+		ctx.Tracer = c.Tracer
+	}
+	env.SetContext(newEvaluation(ctx))
+}
+
+func newEvaluation(ctx *Context) *evaluation {
+	e := &evaluation{Context: *ctx}
+	if lines, ok := ctx.Stdin.(*bufio.Reader); ok {
+		e.lines = lines
+	} else {
+		e.lines = bufio.NewReader(ctx.Stdin)
+	}
+	e.callTracer, _ = ctx.Tracer.(CallTracer)
+	e.goroutineTracer, _ = ctx.Tracer.(GoroutineTracer)
+	return e
+}
+
+// evaluationOf returns the evaluation of env, the process' when no context
+// is set on it.
+func evaluationOf(env *object.Environment) *evaluation {
+	if e, ok := env.Context().(*evaluation); ok {
+		return e
+	}
+	return process
+}
@@ -4,15 +4,33 @@ import (
 	"fmt"
 	"magot/ast"
 	"magot/object"
+	"math"
+	"math/big"
+	"strings"
 )
 
 var (
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
-	NULL  = &object.Null{}
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+	NULL  = object.NULL
 )
 
+// Eval evaluates node in env. The errors it returns are located at the
+// innermost node whose evaluation failed, infix expressions failing at their
+// operator.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && err.Line == 0 {
+		at := ast.StartToken(node)
+		if infix, ok := node.(*ast.InfixExpression); ok {
+			at = infix.Token
+		}
+		err.Line, err.Column = at.Line, at.Column
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -20,21 +38,33 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: node.Value}
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
+		if node.Operator == "spawn" {
+			return evalSpawnExpression(node.Right, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok {
+			if _, literal := node.Value.(*ast.FunctionLiteral); literal {
+				fn.Doc = node.Token.Doc
+			}
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
@@ -58,7 +88,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.YieldStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return yield(val, env)
 	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments to quote, got=%d, want=1", len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -67,7 +109,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return callFunction(node, function, args, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -80,6 +122,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{Parameters: params, Body: body, Env: env}
+	case *ast.MacroLiteral:
+		return newError("macro definitions are only allowed at the top level")
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -116,6 +166,24 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 	return &object.Hash{Pairs: pairs}
 }
 
+// evalInterpolatedString concatenates the parts of an interpolated string,
+// the values other than strings as they are inspected.
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+	for _, part := range node.Parts {
+		value := orNull(Eval(part, env))
+		if isError(value) {
+			return value
+		}
+		if str, ok := value.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(value.Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -150,14 +218,33 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// callFunction applies fn to the arguments of call in env, notifying the call
+// tracer of its context, call being nil for the functions spawn applies to no
+// arguments.
+func callFunction(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	callTracer := evaluationOf(env).callTracer
+	if callTracer == nil || call == nil {
+		return applyFunction(env, fn, args)
+	}
+	callTracer.Call(call, fn)
+	result := applyFunction(env, fn, args)
+	callTracer.Return(call, fn)
+	return result
+}
+
+// applyFunction applies fn to args, env being the environment of the call
+// that builtins evaluate in.
+func applyFunction(env *object.Environment, fn object.Object, args []object.Object) object.Object {
 	switch function := fn.(type) {
 	case *object.Function:
 		extendedEnv := extendedFunctionEnv(function, args)
+		if isGenerator(function.Body) {
+			return newGenerator(function.Body, extendedEnv)
+		}
 		evaluated := Eval(function.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return function.Fn(args...)
+		return function.Fn(env, args...)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -179,6 +266,15 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// orNull returns obj, or NULL for the nothing that the functions with an
+// empty body return.
+func orNull(obj object.Object) object.Object {
+	if obj == nil {
+		return NULL
+	}
+	return obj
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -199,6 +295,9 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
+	if module, ok := modules[node.Value]; ok {
+		return module
+	}
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -207,6 +306,13 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	if isError(condition) {
 		return condition
 	}
+	if tracer := evaluationOf(env).Tracer; tracer != nil {
+		way := 1
+		if isTruthy(condition) {
+			way = 0
+		}
+		tracer.Branch(ie, way)
+	}
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
@@ -236,21 +342,30 @@ func nativeBoolToBooleanObject(value bool) object.Object {
 	return FALSE
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func evalPrefixExpression(operator string, right object.Object, env *object.Environment) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "await":
+		return evalAwaitExpression(right, env)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	if isTemporal(left) || isTemporal(right) {
+		if result := evalTimeInfixExpression(operator, left, right); result != nil {
+			return result
+		}
+	}
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -268,14 +383,8 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/", "%":
+		return evalIntegerArithmetic(operator, left, right)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -312,17 +421,26 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value != math.MinInt64 {
+			return &object.Integer{Value: -right.Value}
+		}
+	case *object.BigInt:
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return newBigInteger(new(big.Int).Neg(bigValue(right)))
 }
 
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
+	tracer := evaluationOf(env).Tracer
 
 	for _, statement := range block.Statements {
+		if tracer != nil {
+			tracer.Statement(statement, env)
+		}
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -336,8 +454,15 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
+	e := evaluationOf(env)
+	tracer := e.Tracer
+	e.scheduler.started(true)
+	defer e.scheduler.ended(true)
 
 	for _, statement := range program.Statements {
+		if tracer != nil {
+			tracer.Statement(statement, env)
+		}
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
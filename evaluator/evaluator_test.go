@@ -1,10 +1,21 @@
 package evaluator
 
 import (
+	"fmt"
+	"magot/ast"
 	"magot/lexer"
 	"magot/object"
 	"magot/parser"
+	"magot/sandbox"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	_ "time/tzdata"
 )
 
 func TestReturnStatements(t *testing.T) {
@@ -220,6 +231,33 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"5 + true", 1, 3},
+		{"let a = 1;\n  -true", 2, 3},
+		{"let f = fn() {\n  foobar\n};\nf()", 2, 3},
+		{"[1, 2][len(1)]", 1, 8},
+		{"if (1 > 0) { 1 + 2 * true }", 1, 20},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Line != tt.line || errObj.Column != tt.column {
+			t.Errorf("wrong position for %q. expected=%d:%d, got=%d:%d",
+				tt.input, tt.line, tt.column, errObj.Line, errObj.Column)
+		}
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -229,6 +267,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("123")`, 3},
 		{`len(1)`, "argument to 'len' not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments, got=2, want=1"},
+		{`len(push([1], 2))`, 2},
+		{`push([1])`, "wrong number of arguments, got=1, want=2"},
+		{`push(1, 2)`, "argument to 'push' must be ARRAY, got INTEGER"},
 	}
 
 	for _, tt := range tests {
@@ -249,6 +290,51 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the error message, empty when the assertion holds
+	}{
+		{`assert(true)`, ""},
+		{`assert(1)`, ""},
+		{`assert(false)`, "assertion failed"},
+		{`assert(1 > 2, "too small")`, "assertion failed: too small"},
+		{`assert(false, 1)`, "argument to 'assert' must be STRING, got INTEGER"},
+		{`assert()`, "wrong number of arguments, got=0, want=1 or 2"},
+		{`assert_eq(1 + 1, 2)`, ""},
+		{`assert_eq("a", "a")`, ""},
+		{`assert_eq([1, [2, "x"]], [1, [2, "x"]])`, ""},
+		{`assert_eq({"a": [1]}, {"a": [1]})`, ""},
+		{`assert_eq(if (false) { 1 }, if (false) { 2 })`, ""},
+		{`assert_eq(1, 2)`, "assertion failed: expected 2, got 1"},
+		{`assert_eq("1", 1)`, `assertion failed: expected 1, got "1"`},
+		{`assert_eq([1, 2], [1])`, "assertion failed: expected [1], got [1, 2]"},
+		{`assert_eq({"a": 1}, {"a": 2})`, "assertion failed: expected {a: 2}, got {a: 1}"},
+		{`assert_error(fn() { 1 + true })`, ""},
+		{`assert_error(fn() { 1 + true }, "mismatch")`, ""},
+		{`assert_error(fn() { len(1) }, "len")`, ""},
+		{`assert_error(fn() { 1 })`, "assertion failed: expected an error, got 1"},
+		{`assert_error(fn() { -true }, "mismatch")`, `assertion failed: expected an error containing "mismatch", got "unknown operator: -BOOLEAN"`},
+		{`assert_error(fn(x) { x })`, "argument to 'assert_error' must take no arguments, got 1 parameters"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if tt.expected == "" {
+			testNullObject(t, evaluated)
+			continue
+		}
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
 func TestArrayIndexExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -389,6 +475,58 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let name = "world"; "hello ${name}!"`, "hello world!"},
+		{`let xs = [1, 2]; "${len(xs) + 1} ${xs} ${true}"`, "3 [1, 2] true"},
+		{`let f = fn(x) { "<${x}>" }; "${f("${f(1)}")}"`, "<<1>>"},
+		{`"${ {"k": "}"}["k"] }"`, "}"},
+		{`"$${name} $${"`, "${name} ${"},
+		{`let n = 5; "$$${n} $${n} $$$${n} $$"`, "$5 ${n} $${n} $$"},
+		{`"${fn() { }()}"`, "null"},
+		{`"${missing}"`, errorMessage("identifier not found: missing")},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`format("%d + %d", 1, 2)`, "1 + 2"},
+		{`format("[%5d|%-5d|%05d]", 42, 42, 42)`, "[   42|42   |00042]"},
+		{`format("[%6s|%-6s|%.2s]", "abc", "abc", "abc")`, "[   abc|abc   |ab]"},
+		{`format("%x %x", 255, "hi")`, "ff 6869"},
+		{`format("%v %v %v", [1, "a"], "s", {"k": 1})`, "[1, a] s {k: 1}"},
+		{`format("100%%")`, "100%"},
+		{`format("%v", fn() { }())`, "null"},
+		{`format("%d", fn() { }())`, errorMessage("verb %d cannot format NULL")},
+		{`format("%d", "1")`, errorMessage("verb %d cannot format STRING")},
+		{`format("%s", 1)`, errorMessage("verb %s cannot format INTEGER")},
+		{`format("%q", 1)`, errorMessage("unknown verb %q")},
+		{`format("%d %d", 1)`, errorMessage("missing argument for verb %d")},
+		{`format("%d", 1, 2)`, errorMessage(`too many arguments for format "%d", got=2, want=1`)},
+		{`format("50%")`, errorMessage(`format "50%" ends with an incomplete verb %`)},
+		{`format("%5%")`, errorMessage("verb %5% takes no flags, width or precision")},
+		{`format(1)`, errorMessage("argument to 'format' must be STRING, got INTEGER")},
+		{`format()`, errorMessage("wrong number of arguments, got=0, want at least 1")},
+		{`format("%1000001d", 1)`, errorMessage("width and precision of verb %1000001 must be at most 1000000")},
+		{`format("%.99999999999999999999s", "a")`, errorMessage("width and precision of verb %.99999999999999999999 must be at most 1000000")},
+		{`printf("%d", "1")`, errorMessage("verb %d cannot format STRING")},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func testEval(input string) object.Object {
 	lex := lexer.New(input)
 	parse := parser.New(lex)
@@ -397,6 +535,14 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+// testEvalWith evaluates input in a global environment with the context c.
+func testEvalWith(c *Context, input string) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	SetContext(env, c)
+	return Eval(program, env)
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	t.Helper()
 	result, ok := obj.(*object.Integer)
@@ -433,3 +579,660 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	}
 	return true
 }
+
+// errorMessage is the message of the error testObject expects, a string
+// standing for a String.
+type errorMessage string
+
+// inspected is a value of a type without a helper of its own that testObject
+// expects, compared by its type and what Inspect returns.
+type inspected struct {
+	Type    object.ObjectType
+	Inspect string
+}
+
+// testObject tests obj against an int for an Integer, a bool, a string for a
+// String, nil for null, a []interface{} for the elements of an Array, an
+// errorMessage or an inspected value.
+func testObject(t *testing.T, obj object.Object, expected interface{}) bool {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		return testIntegerObject(t, obj, int64(expected))
+	case bool:
+		return testBooleanObject(t, obj, expected)
+	case nil:
+		return testNullObject(t, obj)
+	case string:
+		return testStringObject(t, obj, expected)
+	case []interface{}:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			t.Errorf("object is not Array. got=%T(%+v)", obj, obj)
+			return false
+		}
+		if len(array.Elements) != len(expected) {
+			t.Errorf("array has wrong num of elements. got=%d, want=%d", len(array.Elements), len(expected))
+			return false
+		}
+		for i, element := range expected {
+			if !testObject(t, array.Elements[i], element) {
+				return false
+			}
+		}
+		return true
+	case errorMessage:
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error. got=%T(%+v)", obj, obj)
+			return false
+		}
+		if errObj.Message != string(expected) {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			return false
+		}
+		return true
+	case inspected:
+		if obj == nil || obj.Type() != expected.Type || obj.Inspect() != expected.Inspect {
+			t.Errorf("object is not %s %s. got=%T(%+v)", expected.Type, expected.Inspect, obj, obj)
+			return false
+		}
+		return true
+	}
+	t.Fatalf("unexpected expected value %T(%+v)", expected, expected)
+	return false
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	t.Helper()
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T(%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestBigIntegers(t *testing.T) {
+	big := func(value string) inspected { return inspected{object.BIGINT_OBJ, value} }
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", big("9223372036854775808")},
+		{"0 - 9223372036854775807 - 2", big("-9223372036854775809")},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)", big("15511210043330985984000000")},
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"(9223372036854775807 + 1) / 2", 4611686018427387904},
+		{"(9223372036854775807 * 3) % 10", 1},
+		{"(0 - 9223372036854775807 * 3) % 10", -1},
+		{"let m = 0 - 9223372036854775807 - 1; [-m, m / -1, m % -1]", []interface{}{big("9223372036854775808"), big("9223372036854775808"), 0}},
+		{"-(9223372036854775807 + 1)", -9223372036854775808},
+		{"9223372036854775807 * 2 > 9223372036854775807", true},
+		{"9223372036854775807 * 2 == 9223372036854775807 + 9223372036854775807", true},
+		{"let h = {9223372036854775807 * 2: 1}; h[9223372036854775807 + 9223372036854775807]", 1},
+		{"(9223372036854775807 * 2) + true", errorMessage("type mismatch: BIGINT + BOOLEAN")},
+		{"7 % 3", 1},
+		{"-7 / 2", -3},
+		{"1 / 0", errorMessage("division by zero")},
+		{"(9223372036854775807 * 2) % 0", errorMessage("division by zero")},
+		{"18446744073709551614", big("18446744073709551614")},
+		{"-9223372036854775808", -9223372036854775808},
+		{"100000000000000000000 / 10000000000", 10000000000},
+		{"18446744073709551614 == 9223372036854775807 * 2", true},
+		{`format("%d|%x", 9223372036854775807 * 2, 9223372036854775807 + 1)`, "18446744073709551614|8000000000000000"},
+		{`json_stringify([9223372036854775807 * 2])`, "[18446744073709551614]"},
+		{`json_parse("[18446744073709551614, 1]")`, []interface{}{big("18446744073709551614"), 1}},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`math["abs"](-5)`, 5},
+		{`math["abs"](0 - 9223372036854775807 - 1)`, inspected{object.BIGINT_OBJ, "9223372036854775808"}},
+		{`math["abs"]("5")`, errorMessage("argument to 'abs' must be INTEGER, got STRING")},
+		{`[math["min"](3, 1, 2), math["max"](3, 1, 2)]`, []interface{}{1, 3}},
+		{`math["max"]([4, 9223372036854775807 * 2, 5])`, inspected{object.BIGINT_OBJ, "18446744073709551614"}},
+		{`math["min"]([])`, errorMessage("argument to 'min' must not be empty")},
+		{`math["min"](1, "2")`, errorMessage("argument to 'min' must be INTEGER, got STRING")},
+		{`math["pow"](2, 10)`, 1024},
+		{`math["pow"](2, 100)`, inspected{object.BIGINT_OBJ, "1267650600228229401496703205376"}},
+		{`math["pow"](2, -1)`, errorMessage("negative exponent: -1")},
+		{`[math["pow"](1, 99999999999), math["pow"](-1, 99999999999), math["pow"](0, 99999999999)]`, []interface{}{1, -1, 0}},
+		{`math["pow"](2, 99999999999)`, errorMessage("exponent too large: 99999999999, results have at most 1048576 bits")},
+		{`math["pow"](1024, 200000)`, errorMessage("exponent too large: 200000, results have at most 1048576 bits")},
+		{`[math["sqrt"](16), math["sqrt"](17), math["sqrt"](math["pow"](10, 40))]`, []interface{}{4, 4, inspected{object.BIGINT_OBJ, "100000000000000000000"}}},
+		{`math["sqrt"](-4)`, errorMessage("square root of a negative integer: -4")},
+		{`[math["floor"](7, 2), math["floor"](-7, 2), math["floor"](7, -2), math["floor"](-6, 2)]`, []interface{}{3, -4, -4, -3}},
+		{`[math["ceil"](7, 2), math["ceil"](-7, 2), math["ceil"](-7, -2), math["ceil"](6, 2)]`, []interface{}{4, -3, 4, 3}},
+		{`math["floor"](1, 0)`, errorMessage("division by zero")},
+		{`[math["gcd"](12, 18), math["gcd"](-12, 18), math["gcd"](0, 0)]`, []interface{}{6, 6, 0}},
+		{`math["gcd"](12)`, errorMessage("wrong number of arguments, got=1, want=2")},
+		{`math["random"](0)`, errorMessage("argument to 'random' must be positive, got 0")},
+		{`let r = math["random"](10); if (r < 0) { false } else { r < 10 }`, true},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+
+	sequence := func() string {
+		return testEvalWith(nil, `math["seed"](7); [math["random"](1000000), math["random"](1000000), math["random"](1000000)]`).Inspect()
+	}
+	if first, second := sequence(), sequence(); first != second {
+		t.Errorf("expected the same random integers for the same seed, got=%s and %s", first, second)
+	}
+}
+
+func TestTime(t *testing.T) {
+	clock := time.Date(2024, time.March, 9, 23, 30, 0, 0, time.UTC)
+	context := &Context{Now: func() time.Time { return clock }}
+
+	at := func(value string) inspected { return inspected{object.TIME_OBJ, value} }
+	duration := func(value string) inspected { return inspected{object.DURATION_OBJ, value} }
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`now()`, at("2024-03-09T23:30:00Z")},
+		{`now() + duration("1h30m")`, at("2024-03-10T01:00:00Z")},
+		{`duration("90m") + now() - duration("2h")`, at("2024-03-09T23:00:00Z")},
+		{`parse_time("2024-03-10T08:00:00+01:00") - now()`, duration("7h30m0s")},
+		{`[now() < parse_time("2024-03-10T00:00:00Z"), now() > now(), now() == from_unix(unix(now()))]`, []interface{}{true, false, true}},
+		{`let t = now(); [t == t, t != now()]`, []interface{}{true, false}},
+		{`parse_time("10/03/2024 08:15", "02/01/2006 15:04")`, at("2024-03-10T08:15:00Z")},
+		{`parse_time("2024-03-10 08:15", "2006-01-02 15:04", "Europe/Paris")`, at("2024-03-10T08:15:00+01:00")},
+		{`parse_time("2024-07-10 08:15", "2006-01-02 15:04", "Europe/Paris") - parse_time("2024-07-10T08:15:00Z")`, duration("-2h0m0s")},
+		{`parse_time("tomorrow")`, errorMessage(`parsing time "tomorrow" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`)},
+		{`parse_time("2024-03-10", "2006-01-02", "Mars/Olympus")`, errorMessage("unknown time zone Mars/Olympus")},
+		{`format_time(now(), "Mon Jan 2 15:04")`, "Sat Mar 9 23:30"},
+		{`format_time(in_zone(now(), "Asia/Tokyo"))`, "2024-03-10T08:30:00+09:00"},
+		{`in_zone(now(), "Asia/Tokyo") == now()`, true},
+		{`let f = time_fields(in_zone(now(), "America/New_York")); [f["year"], f["month"], f["day"], f["hour"], f["weekday"], f["zone"]]`, []interface{}{2024, 3, 9, 18, 6, "EST"}},
+		{`[unix(now()), from_unix(0)]`, []interface{}{1710027000, at("1970-01-01T00:00:00Z")}},
+		{`[duration("1h") * 3, 2 * duration("1m"), duration("1h") / 4, duration("1h") / duration("20m")]`, []interface{}{duration("3h0m0s"), duration("2m0s"), duration("15m0s"), 3}},
+		{`[duration("1h") - duration("90m"), duration("100s") % duration("1m"), duration("1s") > duration("999ms")]`, []interface{}{duration("-30m0s"), duration("40s"), true}},
+		{`duration("1h") / 0`, errorMessage("division by zero")},
+		{`duration("2562047h") * 2`, errorMessage("duration out of range: 2562047h0m0s * 2")},
+		{`duration("soon")`, errorMessage(`time: invalid duration "soon"`)},
+		{`now() + 1`, errorMessage("type mismatch: TIME + INTEGER")},
+		{`now() * now()`, errorMessage("unknown operator: TIME * TIME")},
+		{`now() == 1`, false},
+		{`unix(1)`, errorMessage("argument to 'unix' must be TIME, got INTEGER")},
+		{`json_stringify([now(), duration("1s")])`, `["2024-03-09T23:30:00Z","1s"]`},
+		{`assert_eq(now(), in_zone(now(), "Asia/Tokyo"))`, nil},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEvalWith(context, tt.input), tt.expected)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let square = fn(n) { n * n }; await [spawn square(2), spawn square(3), spawn fn() { 4 }]`, []interface{}{4, 9, 4}},
+		{`let x = 1; let f = spawn fn() { x + 1 }; await f`, 2},
+		{`let n = 2; let f = spawn fn() { let n = n + 1; n }; await f + n`, 5},
+		{`let f = spawn len("abc"); await f; f`, inspected{object.FUTURE_OBJ, "future(3)"}},
+		{`let c = channel(); spawn fn() { send(c, 1); send(c, 2); close(c) }; [recv(c), recv(c), recv(c)]`, []interface{}{1, 2, nil}},
+		{`let c = channel(2); send(c, "a"); c`, inspected{object.CHANNEL_OBJ, "channel(1/2)"}},
+		{`let results = channel(3);
+		let worker = fn(n) { send(results, n * 10) };
+		await [spawn worker(1), spawn worker(2), spawn worker(3)];
+		recv(results) + recv(results) + recv(results)`, 60},
+		{`let c = channel(); select { recv(c) { "received" } default { "empty" } }`, "empty"},
+		{`let c = channel(1); select { send(c, 5) { recv(c) } }`, 5},
+		{`let c = channel(1); send(c, 7); select { let v = recv(c) { v * 2 } }; v`, 7},
+		{`let c = channel(); close(c); select { let v = recv(c) { v } }`, nil},
+		{`let c = channel(); let d = channel(); spawn send(d, "d"); select { recv(c) { "c" } let v = recv(d) { v } }`, "d"},
+		{`let c = channel(); close(c); send(c, 1)`, errorMessage("send on closed channel")},
+		{`let c = channel(); close(c); select { send(c, 1) { 1 } }`, errorMessage("send on closed channel")},
+		{`let c = channel(); close(c); close(c)`, errorMessage("close of closed channel")},
+		{`await spawn fn() { 1 + true }`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`[await spawn fn() { }, await spawn fn() { fn() { }() }]`, []interface{}{nil, nil}},
+		{`let c = channel(2); send(c, fn() { }()); select { send(c, fn() { }()) { [recv(c), recv(c)] } }`, []interface{}{nil, nil}},
+		{`await [spawn fn() { 1 }, 2]`, errorMessage("cannot await INTEGER")},
+		{`await 1`, errorMessage("cannot await INTEGER")},
+		{`spawn 1`, errorMessage("not a function: INTEGER")},
+		{`spawn fn(x) { x }`, errorMessage("wrong number of arguments, got=0, want=1")},
+		{`spawn f()`, errorMessage("identifier not found: f")},
+		{`select { recv(1) { 1 } }`, errorMessage("argument to 'recv' must be CHANNEL, got INTEGER")},
+		{`channel(-1)`, errorMessage("argument to 'channel' must not be negative, got -1")},
+		{`channel(9223372036854775807)`, errorMessage("argument to 'channel' must be at most 65536, got 9223372036854775807")},
+		{`let c = channel(); send(c, 1)`, errorMessage("all goroutines are asleep - deadlock")},
+		{`let c = channel(); select { recv(c) { 1 } send(c, 2) { 2 } }`, errorMessage("all goroutines are asleep - deadlock")},
+		{`let c = channel(); await spawn fn() { recv(c) }`, errorMessage("all goroutines are asleep - deadlock")},
+		{`let c = channel(); let g = fn() { yield recv(c) }; collect(g())`, errorMessage("all goroutines are asleep - deadlock")},
+		{`let c = channel(); spawn fn() { for (i in range(2000000)) { }; send(c, 1) }; recv(c)`, 1},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let count = fn(n) { for (i in range(n)) { yield i; } }; collect(count(3))`, []interface{}{0, 1, 2}},
+		{`let g = fn() { yield 1; yield 2; }; let it = g(); [it, next(it), next(it), next(it), next(it)]`, []interface{}{inspected{object.ITERATOR_OBJ, "iterator"}, 1, 2, nil, nil}},
+		{`let g = fn() { yield 1; return 5; yield 2; }; collect(g())`, []interface{}{1}},
+		{`let g = fn() { puts("ran"); yield 1 }; g(); 2`, 2},
+		{`let naturals = fn() { for (i in range(1, 9223372036854775807)) { yield i } };
+		collect(take(map(filter(naturals(), fn(n) { n % 3 == 0 }), fn(n) { n * n }), 4))`, []interface{}{9, 36, 81, 144}},
+		{`let pairs = fn(xs) { let make = fn(x) { [x, x] }; for (x in xs) { yield make(x) } }; collect(pairs([1, 2]))`, []interface{}{[]interface{}{1, 1}, []interface{}{2, 2}}},
+		{`let g = fn() { yield 1; yield 1 + true; yield 3; }; collect(g())`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`let g = fn() { yield 1; yield 1 + true; }; let it = g(); [next(it), next(it), next(it)]`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; } sum`, 6},
+		{`let f = fn() { for (x in range(10)) { if (x == 3) { return x * 10 } } }; f()`, 30},
+		{`for (x in 5) { x }`, errorMessage("cannot iterate over INTEGER")},
+		{`for (x in [1, 2]) { x + true }`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`yield 1`, errorMessage("yield outside of a generator function")},
+		{`let g = fn() { yield fn() { }() }; collect(g())`, []interface{}{nil}},
+		{`[collect(map([1, 2], fn(x) { })), filter([1, 2], fn(x) { })]`, []interface{}{[]interface{}{nil, nil}, []interface{}{}}},
+		{`let f = fn() { for (x in [fn() { }()]) { return x } }; f()`, nil},
+		{`[map([1, 2], fn(x) { x * 2 }), filter([1, 2, 3], fn(x) { x != 2 }), take([1, 2, 3], 2), collect(range(2, 4))]`, []interface{}{[]interface{}{2, 4}, []interface{}{1, 3}, []interface{}{1, 2}, []interface{}{2, 3}}},
+		{`let it = iter([1, 2]); [map(it, fn(x) { x }), collect(it)]`, []interface{}{inspected{object.ITERATOR_OBJ, "iterator"}, []interface{}{1, 2}}},
+		{`map([1], len)`, errorMessage("argument to 'len' not supported, got INTEGER")},
+		{`map(1, len)`, errorMessage("argument to 'map' must be ARRAY or ITERATOR, got INTEGER")},
+		{`filter([1], fn(a, b) { a })`, errorMessage("argument to 'filter' must take 1 argument, got 2 parameters")},
+		{`take([1], -1)`, errorMessage("argument to 'take' must not be negative, got -1")},
+		{`next([1])`, errorMessage("argument to 'next' must be ITERATOR, got ARRAY")},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestGeneratorsStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	testEval(`let g = fn() { for (i in range(100)) { yield i; } }; for (i in range(50)) { next(g()) }`)
+	for tries := 0; runtime.NumGoroutine() > before && tries < 100; tries++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if running := runtime.NumGoroutine(); running > before {
+		t.Errorf("the generators left behind are still running, got=%d goroutines, want=%d", running, before)
+	}
+}
+
+func TestLines(t *testing.T) {
+	context := &Context{Stdin: strings.NewReader("a\nbc\n\nd")}
+	testObject(t, testEvalWith(context, `collect(map(lines(), len))`), []interface{}{1, 2, 0, 1})
+}
+
+func TestMatchExpression(t *testing.T) {
+	rule := `let rule = fn(event) {
+		match (event) {
+			{"type": "user", "name": n} if len(n) > 3 => "long " + n,
+			{"type": "user", "name": n} => n,
+			{"type": "batch", "items": [first, ...rest]} => [first, rest],
+			[] => "empty",
+			[_, ...xs] => len(xs),
+			-1 => "minus one",
+			0 => "zero",
+			true => "yes",
+			"hi" => "greeting",
+			_ => "other",
+		}
+	};
+	`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{rule + `rule({"type": "user", "name": "bob"})`, "bob"},
+		{rule + `rule({"type": "user", "name": "alice", "id": 1})`, "long alice"},
+		{rule + `rule({"type": "user"})`, "other"},
+		{rule + `rule({"type": "batch", "items": [1, 2, 3]})`, []interface{}{1, []interface{}{2, 3}}},
+		{rule + `rule({"type": "batch", "items": []})`, "other"},
+		{rule + `rule([])`, "empty"},
+		{rule + `rule([1, 2, 3])`, 2},
+		{rule + `[rule(-1), rule(0), rule(true), rule(false), rule("hi"), rule("ho")]`, []interface{}{"minus one", "zero", "yes", "other", "greeting", "other"}},
+		{`match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }`, 6},
+		{`match ([1, 2]) { [a] => a, [a, b, c] => a, [a, b] => b }`, 2},
+		{`match ([1, 2]) { [a, ...b] if a > 1 => 0, [a, ...b] => b }`, []interface{}{2}},
+		{`match ({1: "one", true: "yes"}) { {1: a, true: b} => a + b }`, "oneyes"},
+		{`let x = 5; match (x + 1) { n => n * 2 }`, 12},
+		{`match (1) { 1 => 2 } + 1`, 3},
+		{`match(regex("a+"), "caat")`, true},
+		{`let f = fn(x) { match (x) { 1 => if (true) { return 10 }, _ => 20 }; 30 }; [f(1), f(2)]`, []interface{}{10, 30}},
+		{`let g = fn(xs) { for (x in xs) { match (x) { [k, v] => if (true) { yield k + v } } } }; collect(g([[1, 2], [3, 4]]))`, []interface{}{3, 7}},
+		{`let n = 1; [match (2) { n if false => 0, _ => n }, n]`, []interface{}{1, 1}},
+		{`let n = 1; match (2) { n => n }; n`, 1},
+		{`match (9223372036854775807 + 1) { 9223372036854775807 => 1, 9223372036854775808 => 2 }`, 2},
+		{`match (-9223372036854775808) { -9223372036854775808 => 1 }`, 1},
+		{`match (5) { 1 => 2, "5" => 3 }`, errorMessage("no pattern matches 5")},
+		{`match ([1]) { }`, errorMessage("no pattern matches [1]")},
+		{`match (1 + true) { _ => 1 }`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`match (1) { x if x + true => 1 }`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+		{`match (1) { x => x + true }`, errorMessage("type mismatch: INTEGER + BOOLEAN")},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestContext(t *testing.T) {
+	var stdout, stderr strings.Builder
+	context := &Context{Stdout: &stdout, Stderr: &stderr, Stdin: strings.NewReader("Ada\r\nLovelace\nlast")}
+
+	input := `puts("hello", 1);
+print("a", [1, 2], true);
+eprint("oops:", 42);
+printf("%d-%s;", 7, "x");
+let first = input("name? ");
+let lines = [first, read_line(), read_line(), read_line()];
+print(lines);
+input(1)`
+	evaluated := testEvalWith(context, input)
+	if evaluated.Inspect() != "ERROR: argument to 'input' must be STRING, got INTEGER" {
+		t.Errorf("wrong result. got=%q", evaluated.Inspect())
+	}
+	expected := "hello\n1\na [1, 2] true\n7-x;name? [Ada, Lovelace, last, null]\n"
+	if stdout.String() != expected {
+		t.Errorf("wrong stdout. expected=%q, got=%q", expected, stdout.String())
+	}
+	if stderr.String() != "oops: 42\n" {
+		t.Errorf("wrong stderr. expected=%q, got=%q", "oops: 42\n", stderr.String())
+	}
+	if evaluated := testEval(`read_line(1)`); evaluated.Inspect() != "ERROR: wrong number of arguments, got=1, want=0" {
+		t.Errorf("wrong result. got=%q", evaluated.Inspect())
+	}
+
+	env := object.NewEnvironment()
+	if evaluationOf(object.NewEnclosedEnvironment(env)) != process {
+		t.Errorf("expected the process context without a context set")
+	}
+	SetContext(env, nil)
+	e := evaluationOf(object.NewEnclosedEnvironment(env))
+	if e == process || e.Stdout != os.Stdout || e.Stdin != os.Stdin || e.Files == nil {
+		t.Errorf("expected SetContext(env, nil) to set the defaults, got=%+v", e.Context)
+	}
+}
+
+func TestConcurrentContexts(t *testing.T) {
+	input := `let f = fn(n) { puts(n); read_line() };
+let futures = map(range(20), fn(n) { spawn f(n) });
+let lines = await collect(futures);
+math["seed"](len(lines));
+[lines[0], math["random"](1000000)]`
+	var outputs [2]strings.Builder
+	var results [2]object.Object
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stdin := strings.Repeat(fmt.Sprintf("%d\n", i), 20)
+			results[i] = testEvalWith(&Context{Stdout: &outputs[i], Stdin: strings.NewReader(stdin)}, input)
+		}(i)
+	}
+	wg.Wait()
+
+	random := testEvalWith(nil, `math["seed"](20); math["random"](1000000)`)
+	for i, output := range outputs {
+		testObject(t, results[i], []interface{}{strconv.Itoa(i), int(random.(*object.Integer).Value)})
+		if lines := strings.Count(output.String(), "\n"); lines != 20 {
+			t.Errorf("wrong number of lines printed in context %d. expected=20, got=%d", i, lines)
+		}
+	}
+}
+
+// countingTracer counts the statements and the branches it is notified of.
+type countingTracer struct {
+	statements, branches int
+}
+
+func (c *countingTracer) Statement(stmt ast.Statement, env *object.Environment) { c.statements++ }
+func (c *countingTracer) Branch(node ast.Expression, way int)                   { c.branches++ }
+
+func TestConcurrentTracers(t *testing.T) {
+	count := func(n int) countingTracer {
+		tracer := &countingTracer{}
+		testEvalWith(&Context{Tracer: tracer}, fmt.Sprintf("let f = fn(n) { if (n > 0) { f(n - 1) } }; f(%d)", n))
+		return *tracer
+	}
+	expected := [2]countingTracer{count(100), count(1000)}
+
+	var counts [2]countingTracer
+	var wg sync.WaitGroup
+	for i, n := range []int{100, 1000} {
+		wg.Add(1)
+		go func(i, n int) {
+			defer wg.Done()
+			counts[i] = count(n)
+		}(i, n)
+	}
+	wg.Wait()
+	if counts != expected {
+		t.Errorf("wrong counts of concurrent evaluations. expected=%+v, got=%+v", expected, counts)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"help(len)", "len(value)\n\nReturns the number of bytes of a string or the number of elements of an array."},
+		{"// Doubles x.\n//\n// Twice.\nlet double = fn(x) { x * 2 }; help(double)", "fn(x)\n\nDoubles x.\n\nTwice."},
+		{"let f = fn(a, b) { a }; help(f)", "fn(a, b)"},
+		{"// Doubles x.\nlet double = fn(x) { x * 2 };\n// Not the doc of double.\nlet twice = double; help(twice)", "fn(x)\n\nDoubles x."},
+		{"help(fn() { 1 })", "fn()"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("wrong help for %q. expected=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval("help(1)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "argument to 'help' must be FUNCTION or BUILTIN, got INTEGER" {
+		t.Errorf("wrong result for help(1). got=%v", evaluated)
+	}
+	for _, name := range BuiltinNames() {
+		if builtin, _ := LookupBuiltin(name); builtin.Usage == "" || builtin.Doc == "" {
+			t.Errorf("builtin %s is not documented", name)
+		}
+	}
+}
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`42`, 42},
+		{` -7 `, -7},
+		{`"a\nb"`, "a\nb"},
+		{`"[1]"`, "[1]"},
+		{`true`, true},
+		{`null`, nil},
+		{`[1, "two", [false]]`, []interface{}{1, "two", []interface{}{false}}},
+		{`{"a": {"b": []}}`, inspected{object.HASH_OBJ, "{a: {b: []}}"}},
+		{`1.5`, errorMessage("invalid JSON: 1.5 is not an integer")},
+		{`[1, 2`, errorMessage("invalid JSON: unexpected end of input")},
+		{`{"a" 1}`, errorMessage("invalid JSON at offset 6: invalid character '1' after object key")},
+		{`1 2`, errorMessage("invalid JSON at offset 1: unexpected data after the value")},
+		{``, errorMessage("invalid JSON: unexpected end of input")},
+	}
+
+	for _, tt := range tests {
+		testObject(t, jsonParse(nil, &object.String{Value: tt.input}), tt.expected)
+	}
+	testObject(t, jsonParse(nil, &object.Integer{Value: 1}), errorMessage("argument to 'json_parse' must be STRING, got INTEGER"))
+}
+
+func TestJSONStringify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the JSON, or the error message
+	}{
+		{`json_stringify(1)`, `1`},
+		{`json_stringify("a<b")`, `"a<b"`},
+		{`json_stringify([1, true, if (false) { 1 }, "x"])`, `[1,true,null,"x"]`},
+		{`json_stringify({"b": 1, "a": [], 3: {}, true: 2})`, `{"3":{},"a":[],"b":1,"true":2}`},
+		{`json_stringify({"a": [1, 2], "b": {}}, 2)`, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}"},
+		{`json_stringify([1], "--")`, "[\n--1\n]"},
+		{`json_stringify(json_parse(json_stringify({"k": [1, {"n": if (false) { 1 }}]})))`, `{"k":[1,{"n":null}]}`},
+		{`json_stringify(fn() { }())`, `null`},
+		{`json_stringify([fn() { }()])`, `[null]`},
+		{`json_stringify([fn(x) { x }])`, "cannot stringify: FUNCTION values are not serializable"},
+		{`json_stringify(len)`, "cannot stringify: BUILTIN values are not serializable"},
+		{`json_stringify({1: 1, "1": 2})`, `cannot stringify: duplicate key "1"`},
+		{`json_stringify(1, -1)`, "indent of 'json_stringify' must not be negative, got -1"},
+		{`json_stringify(1, 1000000000000)`, "indent of 'json_stringify' must be at most 10, got 1000000000000"},
+		{`json_stringify(1, "12345678901")`, "indent of 'json_stringify' must be at most 10 characters long, got 11"},
+		{`json_stringify(1, [])`, "indent of 'json_stringify' must be INTEGER or STRING, got ARRAY"},
+		{`json_stringify()`, "wrong number of arguments, got=0, want=1 or 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch result := evaluated.(type) {
+		case *object.String:
+			if result.Value != tt.expected {
+				t.Errorf("wrong JSON for %s. expected=%q, got=%q", tt.input, tt.expected, result.Value)
+			}
+		case *object.Error:
+			if result.Message != tt.expected {
+				t.Errorf("wrong error for %s. expected=%q, got=%q", tt.input, tt.expected, result.Message)
+			}
+		default:
+			t.Errorf("unexpected result for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+
+	cycle := &object.Array{}
+	cycle.Elements = []object.Object{&object.Integer{Value: 1}, cycle}
+	if result := jsonStringify(nil, cycle); result.Inspect() != "ERROR: cannot stringify: ARRAY contains itself" {
+		t.Errorf("wrong result for a cycle. got=%q", result.Inspect())
+	}
+	shared := &object.Array{Elements: []object.Object{}}
+	if result := jsonStringify(nil, &object.Array{Elements: []object.Object{shared, shared}}); result.Inspect() != "[[],[]]" {
+		t.Errorf("wrong result for a shared array. got=%q", result.Inspect())
+	}
+}
+
+func TestFSModule(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("one\r\ntwo\n\nfour"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(filepath.Join(outside, "pwned"), filepath.Join(dir, "out", "link")); err != nil {
+		t.Fatal(err)
+	}
+	s := sandbox.New()
+	s.Allow(sandbox.READ, dir)
+	s.Allow(sandbox.WRITE, filepath.Join(dir, "out"))
+	context := &Context{Files: s}
+
+	path := func(name string) string { return `"` + filepath.Join(dir, name) + `"` }
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`fs["read_file"](` + path("in.txt") + `)`, "one\r\ntwo\n\nfour"},
+		{`fs["read_lines"](` + path("in.txt") + `)`, []interface{}{"one", "two", "", "four"}},
+		{`fs["each_line"](` + path("in.txt") + `, len)`, nil},
+		{`fs["each_line"](` + path("in.txt") + `, fn(l) { if (len(l) == 0) { assert(false, "empty") } })`, errorMessage("assertion failed: empty")},
+		{`fs["each_line"](` + path("in.txt") + `, fn(a, b) { a })`, errorMessage("argument to 'each_line' must take 1 argument, got 2 parameters")},
+		{`fs["list_dir"](` + path("") + `)`, []interface{}{"in.txt", "out"}},
+		{`[fs["exists"](` + path("in.txt") + `), fs["exists"](` + path("nope") + `)]`, []interface{}{true, false}},
+		{`let s = fs["stat"](` + path("out") + `); [s["name"], s["dir"]]`, []interface{}{"out", true}},
+		{`fs["stat"](` + path("in.txt") + `)["size"]`, 14},
+		{`fs["write_file"](` + path("out/x.txt") + `, "hello"); fs["read_file"](` + path("out/x.txt") + `)`, "hello"},
+		{`fs["read_file"](` + path("nope") + `)`, errorMessage("open " + filepath.Join(dir, "nope") + ": no such file or directory")},
+		{`fs["write_file"](` + path("x.txt") + `, "hello")`, errorMessage("write access to " + filepath.Join(dir, "x.txt") + " denied, it is outside the directories granted for it")},
+		{`fs["write_file"](` + path("out/link") + `, "escaped")`, errorMessage("write access to " + filepath.Join(dir, "out", "link") + " denied, it is outside the directories granted for it")},
+		{`fs["read_file"]("/")`, errorMessage("read access to / denied, it is outside the directories granted for it")},
+		{`fs["read_file"](1)`, errorMessage("argument to 'read_file' must be STRING, got INTEGER")},
+		{`fs["write_file"](` + path("out/x.txt") + `)`, errorMessage("wrong number of arguments, got=1, want=2")},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEvalWith(context, tt.input), tt.expected)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "pwned")); err == nil {
+		t.Errorf("file written through a link to outside the sandbox")
+	}
+
+	if evaluated := testEval(`fs["read_file"](` + path("in.txt") + `)`); !isError(evaluated) {
+		t.Errorf("expected no access without a sandbox, got=%q", evaluated.Inspect())
+	}
+}
+
+func TestRegexBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`regex("a+b")`, inspected{object.REGEX_OBJ, `regex("a+b")`}},
+		{`regex(regex("x"))`, inspected{object.REGEX_OBJ, `regex("x")`}},
+		{`regex("(")`, errorMessage("invalid pattern: missing closing ): `(`")},
+		{`regex(1)`, errorMessage("argument to 'regex' must be STRING, got INTEGER")},
+		{`match("\d+", "abc 42")`, true},
+		{`match(regex("^\d+$"), "abc 42")`, false},
+		{`match(1, "a")`, errorMessage("argument to 'match' must be REGEX or STRING, got INTEGER")},
+		{`match("a", 1)`, errorMessage("argument to 'match' must be STRING, got INTEGER")},
+		{`match("a")`, errorMessage("wrong number of arguments, got=1, want=2")},
+		{`find_all("\d+", "1 22 333")`, []interface{}{"1", "22", "333"}},
+		{`find_all("\d+", "1 22 333", 2)`, []interface{}{"1", "22"}},
+		{`find_all("x", "abc")`, []interface{}{}},
+		{`find_all("x", "abc", "1")`, errorMessage("argument to 'find_all' must be INTEGER, got STRING")},
+		{`let c = captures("(?P<key>\w+)=(?P<value>\w*)(;)?", "a: level=warn"); [c["key"], c["value"], c[0], c[3]]`, []interface{}{"level", "warn", "level=warn", nil}},
+		{`captures("(\d+)", "none")`, nil},
+		{`replace("\d", "a1b22", "#")`, "a#b##"},
+		{`replace("(\w+)@(\w+)", "me@home", "$2 at $1")`, "home at me"},
+		{`replace("\d+", "a1b22", fn(m) { m + m })`, "a11b2222"},
+		{`replace("\d+", "a1b22", len)`, errorMessage("replacement function must return STRING, got INTEGER")},
+		{`replace("a", "abc", fn(m) { })`, errorMessage("replacement function must return STRING, got NULL")},
+		{`replace("\d+", "a1b22", fn(m) { assert(len(m) == 2); m })`, errorMessage("assertion failed")},
+		{`replace("\d+", "a1b22", fn(a, b) { a })`, errorMessage("argument to 'replace' must take 1 argument, got 2 parameters")},
+		{`replace("\d+", "a1b22", 1)`, errorMessage("argument to 'replace' must be STRING or FUNCTION, got INTEGER")},
+		{`split(",\s*", "a, b,c")`, []interface{}{"a", "b", "c"}},
+		{`split(",", "a,b,c", 2)`, []interface{}{"a", "b,c"}},
+		{`split(",", "")`, []interface{}{""}},
+	}
+
+	for _, tt := range tests {
+		testObject(t, testEval(tt.input), tt.expected)
+	}
+
+	first, _ := compilePattern("a|b")
+	second, _ := compilePattern("a|b")
+	if first != second {
+		t.Errorf("expected the compiled pattern to be cached")
+	}
+}
@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"magot/object"
+	"strings"
+)
+
+func init() {
+	for _, builtin := range []*object.Builtin{
+		{
+			Name:  "format",
+			Usage: "format(fmt, args...)",
+			Doc:   "Returns fmt with its verbs replaced by the arguments in turn: %d formats an\ninteger, %s a string, %x an integer or a string in hexadecimal and %v any\nvalue. A verb may have a width and a precision as in %-8.3s, the - flag\npadding on the right and the 0 flag padding numbers with zeros. %% stands\nfor a percent sign.",
+			Fn:    format,
+		},
+		{
+			Name:  "printf",
+			Usage: "printf(fmt, args...)",
+			Doc:   "Prints fmt formatted like format does, without adding a newline, and\nreturns null.",
+			Fn:    printf,
+		},
+	} {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+// FORMAT_FLAGS are the flags a verb may have, before its width.
+const FORMAT_FLAGS = "-0+ "
+
+// MAX_FORMAT_WIDTH bounds widths and precisions, at the largest the fmt
+// package formats.
+const MAX_FORMAT_WIDTH = 1000000
+
+func format(env *object.Environment, args ...object.Object) object.Object {
+	str, err := formatArguments("format", args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: str}
+}
+
+func printf(env *object.Environment, args ...object.Object) object.Object {
+	str, err := formatArguments("printf", args)
+	if err != nil {
+		return err
+	}
+	e := evaluationOf(env)
+	e.streamLock.Lock()
+	defer e.streamLock.Unlock()
+	io.WriteString(e.Stdout, str)
+	return NULL
+}
+
+// formatArguments formats the arguments of the builtin called name, a format
+// string followed by the values of its verbs.
+func formatArguments(name string, args []object.Object) (string, *object.Error) {
+	if len(args) < 1 {
+		return "", newError("wrong number of arguments, got=%d, want at least 1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to '%s' must be STRING, got %s", name, args[0].Type())
+	}
+	return formatString(str.Value, args[1:])
+}
+
+// formatString checks each verb of fmt against its value and lets the fmt
+// package format it.
+func formatString(format string, values []object.Object) (string, *object.Error) {
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte(FORMAT_FLAGS, format[i]) >= 0 {
+			i++
+		}
+		var ok bool
+		i, ok = skipWidth(format, i)
+		if ok && i < len(format) && format[i] == '.' {
+			i, ok = skipWidth(format, i+1)
+		}
+		if !ok {
+			return "", newError("width and precision of verb %s must be at most %d", format[start:i], MAX_FORMAT_WIDTH)
+		}
+		if i == len(format) {
+			return "", newError("format %q ends with an incomplete verb %s", format, format[start:])
+		}
+		spec := format[start : i+1]
+		if format[i] == '%' {
+			if spec != "%%" {
+				return "", newError("verb %s takes no flags, width or precision", spec)
+			}
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(values) {
+			return "", newError("missing argument for verb %s", spec)
+		}
+		value, err := formatValue(spec, orNull(values[next]))
+		if err != nil {
+			return "", err
+		}
+		next++
+		out.WriteString(value)
+	}
+	if next < len(values) {
+		return "", newError("too many arguments for format %q, got=%d, want=%d", format, len(values), next)
+	}
+	return out.String(), nil
+}
+
+// skipWidth returns the index after the digits of format starting at i,
+// and whether the number they make is at most MAX_FORMAT_WIDTH.
+func skipWidth(format string, i int) (int, bool) {
+	width := 0
+	for ; i < len(format) && isDigit(format[i]); i++ {
+		if width <= MAX_FORMAT_WIDTH {
+			width = width*10 + int(format[i]-'0')
+		}
+	}
+	return i, width <= MAX_FORMAT_WIDTH
+}
+
+// formatValue formats value with spec, a verb along with its flags, width
+// and precision.
+func formatValue(spec string, value object.Object) (string, *object.Error) {
+	verb := spec[len(spec)-1]
+	switch verb {
+	case 'd':
+		switch value := value.(type) {
+		case *object.Integer:
+			return fmt.Sprintf(spec, value.Value), nil
+		case *object.BigInt:
+			return fmt.Sprintf(spec, value.Value), nil
+		}
+	case 's':
+		if value, ok := value.(*object.String); ok {
+			return fmt.Sprintf(spec, value.Value), nil
+		}
+	case 'x':
+		switch value := value.(type) {
+		case *object.Integer:
+			return fmt.Sprintf(spec, value.Value), nil
+		case *object.BigInt:
+			return fmt.Sprintf(spec, value.Value), nil
+		case *object.String:
+			return fmt.Sprintf(spec, value.Value), nil
+		}
+	case 'v':
+		if str, ok := value.(*object.String); ok {
+			return fmt.Sprintf(spec, str.Value), nil
+		}
+		return fmt.Sprintf(spec, value.Inspect()), nil
+	default:
+		return "", newError("unknown verb %s", spec)
+	}
+	return "", newError("verb %s cannot format %s", spec, value.Type())
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
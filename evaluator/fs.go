@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"magot/object"
+	"magot/sandbox"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The fs module calls functions, which refers back to the builtins table, so
+// it is added once the table is initialized.
+func init() {
+	addModule("fs", map[string]*object.Builtin{
+		"read_file": {
+			Usage: `fs["read_file"](path)`,
+			Doc:   "Returns the content of a file as a string.",
+			Fn:    fsReadFile,
+		},
+		"read_lines": {
+			Usage: `fs["read_lines"](path)`,
+			Doc:   "Returns the lines of a file as an array of strings, without their line\nterminators.",
+			Fn:    fsReadLines,
+		},
+		"each_line": {
+			Usage: `fs["each_line"](path, fn)`,
+			Doc:   "Calls fn with each line of a file in turn, without reading the whole file,\nand stops at the first error fn returns.",
+			Fn:    fsEachLine,
+		},
+		"write_file": {
+			Usage: `fs["write_file"](path, content)`,
+			Doc:   "Writes a string to a file, replacing its content, and returns null.",
+			Fn:    fsWriteFile,
+		},
+		"list_dir": {
+			Usage: `fs["list_dir"](path)`,
+			Doc:   "Returns the names of the entries of a directory, sorted.",
+			Fn:    fsListDir,
+		},
+		"exists": {
+			Usage: `fs["exists"](path)`,
+			Doc:   "Returns whether a file or a directory exists.",
+			Fn:    fsExists,
+		},
+		"stat": {
+			Usage: `fs["stat"](path)`,
+			Doc:   "Returns a hash describing a file, with its name, its size in bytes, whether\nit is a dir, its mode such as \"-rw-r--r--\" and its modified time in seconds\nsince the Unix epoch.",
+			Fn:    fsStat,
+		},
+	})
+}
+
+// pathArgument checks the arguments of the fs function called name, the first
+// of which is a path the access to which the context of env must grant, and
+// returns the resolved path. It holds no symbolic links, and the files at it
+// are opened with sandbox.OpenFile and described with os.Lstat to not follow
+// the links put in their place since.
+func pathArgument(env *object.Environment, name string, access sandbox.Access, want int, args []object.Object) (string, *object.Error) {
+	if len(args) != want {
+		return "", newError("wrong number of arguments, got=%d, want=%d", len(args), want)
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to '%s' must be STRING, got %s", name, args[0].Type())
+	}
+	resolved, err := evaluationOf(env).Files.Check(access, path.Value)
+	if err != nil {
+		return "", newError("%s", err)
+	}
+	return resolved, nil
+}
+
+// fsError reports a failed file operation with the path the program gave
+// rather than the resolved one.
+func fsError(err error, path object.Object) *object.Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		pathErr.Path = path.(*object.String).Value
+	}
+	return newError("%s", err)
+}
+
+func fsReadFile(env *object.Environment, args ...object.Object) object.Object {
+	path, errObj := pathArgument(env, "read_file", sandbox.READ, 1, args)
+	if errObj != nil {
+		return errObj
+	}
+	file, err := sandbox.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	return &object.String{Value: string(content)}
+}
+
+func fsReadLines(env *object.Environment, args ...object.Object) object.Object {
+	lines := []object.Object{}
+	result := eachLine(env, "read_lines", args, func(line string) object.Object {
+		lines = append(lines, &object.String{Value: line})
+		return nil
+	})
+	if result != nil {
+		return result
+	}
+	return &object.Array{Elements: lines}
+}
+
+func fsEachLine(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments, got=%d, want=2", len(args))
+	}
+	switch fn := args[1].(type) {
+	case *object.Function:
+		if len(fn.Parameters) != 1 {
+			return newError("argument to 'each_line' must take 1 argument, got %d parameters", len(fn.Parameters))
+		}
+	case *object.Builtin:
+	default:
+		return newError("argument to 'each_line' must be FUNCTION, got %s", args[1].Type())
+	}
+	result := eachLine(env, "each_line", args[:1], func(line string) object.Object {
+		if result := applyFunction(env, args[1], []object.Object{&object.String{Value: line}}); isError(result) {
+			return result
+		}
+		return nil
+	})
+	if result != nil {
+		return result
+	}
+	return NULL
+}
+
+// eachLine calls f with each line of the file given in args, and returns the
+// first error, which f may return.
+func eachLine(env *object.Environment, name string, args []object.Object, f func(line string) object.Object) object.Object {
+	path, errObj := pathArgument(env, name, sandbox.READ, 1, args)
+	if errObj != nil {
+		return errObj
+	}
+	file, err := sandbox.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		if result := f(strings.TrimSuffix(scanner.Text(), "\r")); result != nil {
+			return result
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fsError(err, args[0])
+	}
+	return nil
+}
+
+func fsWriteFile(env *object.Environment, args ...object.Object) object.Object {
+	path, errObj := pathArgument(env, "write_file", sandbox.WRITE, 2, args)
+	if errObj != nil {
+		return errObj
+	}
+	content, ok := args[1].(*object.String)
+	if !ok {
+		return newError("argument to 'write_file' must be STRING, got %s", args[1].Type())
+	}
+	file, err := sandbox.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	_, err = file.WriteString(content.Value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	return NULL
+}
+
+func fsListDir(env *object.Environment, args ...object.Object) object.Object {
+	path, errObj := pathArgument(env, "list_dir", sandbox.READ, 1, args)
+	if errObj != nil {
+		return errObj
+	}
+	dir, err := sandbox.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	defer dir.Close()
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	names := make([]object.Object, len(entries))
+	for i, entry := range entries {
+		names[i] = &object.String{Value: entry.Name()}
+	}
+	return &object.Array{Elements: names}
+}
+
+func fsExists(env *object.Environment, args ...object.Object) object.Object {
+	path, errObj := pathArgument(env, "exists", sandbox.READ, 1, args)
+	if errObj != nil {
+		return errObj
+	}
+	_, err := os.Lstat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fsError(err, args[0])
+	}
+	return nativeBoolToBooleanObject(err == nil)
+}
+
+func fsStat(env *object.Environment, args ...object.Object) object.Object {
+	path, errObj := pathArgument(env, "stat", sandbox.READ, 1, args)
+	if errObj != nil {
+		return errObj
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fsError(err, args[0])
+	}
+	pairs := make(map[object.HashKey]object.HashPair)
+	for key, value := range map[string]object.Object{
+		"name":     &object.String{Value: info.Name()},
+		"size":     &object.Integer{Value: info.Size()},
+		"dir":      nativeBoolToBooleanObject(info.IsDir()),
+		"mode":     &object.String{Value: info.Mode().String()},
+		"modified": &object.Integer{Value: info.ModTime().Unix()},
+	} {
+		keyObj := &object.String{Value: key}
+		pairs[keyObj.HashKey()] = object.HashPair{Key: keyObj, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
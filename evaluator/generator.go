@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"magot/ast"
+	"magot/object"
+	"runtime"
+	"sync"
+)
+
+// generator evaluates a call to a generator function in a goroutine of its
+// own, which hands each value it yields over to the iterator the call
+// returned and waits until the next one is asked for.
+type generator struct {
+	resume chan struct{}
+	values chan object.Object
+	stop   chan struct{} // closed once the iterator is garbage collected
+}
+
+// stopGenerator unwinds the evaluation of a stopped generator.
+type stopGenerator struct{}
+
+var (
+	// generatorBodies records whether the function bodies evaluated so
+	// far yield.
+	generatorBodies sync.Map
+	// generators holds the generator evaluated in the environment of each
+	// running call to a generator function, where its yield statements are
+	// evaluated.
+	generators sync.Map
+)
+
+// isGenerator reports whether a function body holds yield statements, the
+// ones of the functions nested in it aside.
+func isGenerator(body *ast.BlockStatement) bool {
+	if cached, ok := generatorBodies.Load(body); ok {
+		return cached.(bool)
+	}
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.YieldStatement:
+			found = true
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		}
+		return !found
+	})
+	generatorBodies.Store(body, found)
+	return found
+}
+
+// newGenerator returns the iterator over the values yielded by body, whose
+// evaluation in env starts when the first one is asked for. What body
+// returns is dropped, except for errors, which end the iterator.
+func newGenerator(body *ast.BlockStatement, env *object.Environment) *object.Iterator {
+	g := &generator{
+		resume: make(chan struct{}),
+		values: make(chan object.Object),
+		stop:   make(chan struct{}),
+	}
+	started := false
+	it := object.NewIterator(func() (object.Object, bool) {
+		if started {
+			g.resume <- struct{}{}
+		} else {
+			started = true
+			go g.run(body, env)
+		}
+		value, ok := <-g.values
+		return value, ok
+	})
+	// the goroutine of a generator whose values are no longer wanted would
+	// otherwise wait forever
+	runtime.AddCleanup(it, func(stop chan struct{}) { close(stop) }, g.stop)
+	return it
+}
+
+func (g *generator) run(body *ast.BlockStatement, env *object.Environment) {
+	defer close(g.values)
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(stopGenerator); !ok {
+				panic(r)
+			}
+		}
+	}()
+	generators.Store(env, g)
+	defer generators.Delete(env)
+
+	result := traceGoroutine(env, "generator", func() object.Object {
+		return unwrapReturnValue(Eval(body, env))
+	})
+	if isError(result) {
+		select {
+		case g.values <- result:
+		case <-g.stop:
+		}
+	}
+}
+
+// yield hands value over to the iterator of the generator evaluated in env,
+// or in an environment enclosing it such as the one of a match arm, and
+// waits until the next value is asked for.
+func yield(value object.Object, env *object.Environment) object.Object {
+	found, ok := generators.Load(env)
+	for !ok && env.Outer() != nil {
+		env = env.Outer()
+		found, ok = generators.Load(env)
+	}
+	if !ok {
+		return newError("yield outside of a generator function")
+	}
+	g := found.(*generator)
+	g.values <- orNull(value)
+	select {
+	case <-g.resume:
+		return nil
+	case <-g.stop:
+		panic(stopGenerator{})
+	}
+}
@@ -0,0 +1,305 @@
+package evaluator
+
+import (
+	"magot/ast"
+	"magot/object"
+)
+
+// map and filter go through applyFunction, which refers back to the builtins
+// table, so the iterator builtins are added once the table is initialized.
+func init() {
+	for _, builtin := range []*object.Builtin{
+		{
+			Name:  "iter",
+			Usage: "iter(value)",
+			Doc:   "Returns an iterator over the elements of an array, or the iterator given.",
+			Fn:    iteratorIter,
+		},
+		{
+			Name:  "next",
+			Usage: "next(it)",
+			Doc:   "Returns the next value of an iterator, or null once it is exhausted.",
+			Fn:    iteratorNext,
+		},
+		{
+			Name:  "collect",
+			Usage: "collect(values)",
+			Doc:   "Returns the values of an iterator as an array, or the array given.",
+			Fn:    iteratorCollect,
+		},
+		{
+			Name:  "map",
+			Usage: "map(values, fn)",
+			Doc:   "Returns the results of fn applied to each value of an array, as an array,\nor of an iterator, as an iterator applying fn as its values are asked for.",
+			Fn:    iteratorMap,
+		},
+		{
+			Name:  "filter",
+			Usage: "filter(values, fn)",
+			Doc:   "Returns the values of an array, as an array, or of an iterator, as an\niterator testing them as they are asked for, for which fn returns a truthy\nvalue.",
+			Fn:    iteratorFilter,
+		},
+		{
+			Name:  "take",
+			Usage: "take(values, n)",
+			Doc:   "Returns the first n values of an array, as an array, or of an iterator, as\nan iterator asking for no more than n of them.",
+			Fn:    iteratorTake,
+		},
+		{
+			Name:  "range",
+			Usage: "range(start?, end)",
+			Doc:   "Returns an iterator over the integers from start, 0 by default, up to end,\nend excluded.",
+			Fn:    iteratorRange,
+		},
+		{
+			Name:  "lines",
+			Usage: "lines()",
+			Doc:   "Returns an iterator over the lines of the standard input, read as they are\nasked for, without their line terminators.",
+			Fn:    iteratorLines,
+		},
+	} {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+// evalForExpression evaluates the body of a for loop with its variable bound
+// in env to each value of an array or an iterator in turn, and returns null
+// unless the body returns or fails.
+func evalForExpression(node *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	it := iterate(iterable)
+	if it == nil {
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+	for {
+		value, ok := it.Next()
+		if !ok {
+			return NULL
+		}
+		if isError(value) {
+			return value
+		}
+		env.Set(node.Variable.Value, value)
+		result := Eval(node.Body, env)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+}
+
+// iterate returns an iterator over the elements of an array, the iterator
+// given, or nil for the other values.
+func iterate(obj object.Object) *object.Iterator {
+	switch obj := obj.(type) {
+	case *object.Iterator:
+		return obj
+	case *object.Array:
+		elements, i := obj.Elements, 0
+		return object.NewIterator(func() (object.Object, bool) {
+			if i == len(elements) {
+				return nil, false
+			}
+			i++
+			return orNull(elements[i-1]), true
+		})
+	}
+	return nil
+}
+
+// sequenceArguments checks the arguments of the iterator builtin called name,
+// the first of which is an array or an iterator, and returns an iterator over
+// it.
+func sequenceArguments(name string, args []object.Object, want int) (*object.Iterator, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments, got=%d, want=%d", len(args), want)
+	}
+	it := iterate(args[0])
+	if it == nil {
+		return nil, newError("argument to '%s' must be ARRAY or ITERATOR, got %s", name, args[0].Type())
+	}
+	return it, nil
+}
+
+// functionArgument checks that the second argument of the iterator builtin
+// called name is a function taking one argument.
+func functionArgument(name string, args []object.Object) *object.Error {
+	switch fn := args[1].(type) {
+	case *object.Function:
+		if len(fn.Parameters) != 1 {
+			return newError("argument to '%s' must take 1 argument, got %d parameters", name, len(fn.Parameters))
+		}
+	case *object.Builtin:
+	default:
+		return newError("argument to '%s' must be FUNCTION, got %s", name, args[1].Type())
+	}
+	return nil
+}
+
+// collect returns the values of an iterator as an array, or the first error
+// among them.
+func collect(it *object.Iterator) object.Object {
+	elements := []object.Object{}
+	for {
+		value, ok := it.Next()
+		if !ok {
+			return &object.Array{Elements: elements}
+		}
+		if isError(value) {
+			return value
+		}
+		elements = append(elements, value)
+	}
+}
+
+// sequenceOf returns it as the same kind of sequence as arg, collecting its
+// values in an array when arg is an array.
+func sequenceOf(arg object.Object, it *object.Iterator) object.Object {
+	if _, ok := arg.(*object.Array); ok {
+		return collect(it)
+	}
+	return it
+}
+
+func iteratorIter(env *object.Environment, args ...object.Object) object.Object {
+	it, err := sequenceArguments("iter", args, 1)
+	if err != nil {
+		return err
+	}
+	return it
+}
+
+func iteratorNext(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	it, ok := args[0].(*object.Iterator)
+	if !ok {
+		return newError("argument to 'next' must be ITERATOR, got %s", args[0].Type())
+	}
+	value, ok := it.Next()
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func iteratorCollect(env *object.Environment, args ...object.Object) object.Object {
+	it, err := sequenceArguments("collect", args, 1)
+	if err != nil {
+		return err
+	}
+	return collect(it)
+}
+
+func iteratorMap(env *object.Environment, args ...object.Object) object.Object {
+	it, err := sequenceArguments("map", args, 2)
+	if err != nil {
+		return err
+	}
+	if err := functionArgument("map", args); err != nil {
+		return err
+	}
+	return sequenceOf(args[0], object.NewIterator(func() (object.Object, bool) {
+		value, ok := it.Next()
+		if !ok || isError(value) {
+			return value, ok
+		}
+		return orNull(applyFunction(env, args[1], []object.Object{value})), true
+	}))
+}
+
+func iteratorFilter(env *object.Environment, args ...object.Object) object.Object {
+	it, err := sequenceArguments("filter", args, 2)
+	if err != nil {
+		return err
+	}
+	if err := functionArgument("filter", args); err != nil {
+		return err
+	}
+	return sequenceOf(args[0], object.NewIterator(func() (object.Object, bool) {
+		for {
+			value, ok := it.Next()
+			if !ok || isError(value) {
+				return value, ok
+			}
+			keep := orNull(applyFunction(env, args[1], []object.Object{value}))
+			if isError(keep) {
+				return keep, true
+			}
+			if isTruthy(keep) {
+				return value, true
+			}
+		}
+	}))
+}
+
+func iteratorTake(env *object.Environment, args ...object.Object) object.Object {
+	it, err := sequenceArguments("take", args, 2)
+	if err != nil {
+		return err
+	}
+	n, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError("argument to 'take' must be INTEGER, got %s", args[1].Type())
+	}
+	if n.Value < 0 {
+		return newError("argument to 'take' must not be negative, got %d", n.Value)
+	}
+	var taken int64
+	return sequenceOf(args[0], object.NewIterator(func() (object.Object, bool) {
+		if taken == n.Value {
+			return nil, false
+		}
+		taken++
+		return it.Next()
+	}))
+}
+
+func iteratorRange(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("wrong number of arguments, got=%d, want=1 or 2", len(args))
+	}
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return newError("argument to 'range' must be INTEGER, got %s", arg.Type())
+		}
+		bounds[i] = n.Value
+	}
+	var next, end int64
+	if len(bounds) == 1 {
+		end = bounds[0]
+	} else {
+		next, end = bounds[0], bounds[1]
+	}
+	return object.NewIterator(func() (object.Object, bool) {
+		if next >= end {
+			return nil, false
+		}
+		next++
+		return &object.Integer{Value: next - 1}, true
+	})
+}
+
+func iteratorLines(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments, got=%d, want=0", len(args))
+	}
+	return object.NewIterator(func() (object.Object, bool) {
+		e := evaluationOf(env)
+		e.streamLock.Lock()
+		defer e.streamLock.Unlock()
+		line := readLine(e)
+		if line == NULL {
+			return nil, false
+		}
+		return line, true
+	})
+}
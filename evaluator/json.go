@@ -0,0 +1,203 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"magot/object"
+	"math/big"
+	"strings"
+)
+
+func init() {
+	builtins["json_parse"] = &object.Builtin{
+		Name:  "json_parse",
+		Usage: "json_parse(str)",
+		Doc:   "Returns the value encoded in a JSON string, objects becoming hashes and\narrays arrays. Numbers must be integers.",
+		Fn:    jsonParse,
+	}
+	builtins["json_stringify"] = &object.Builtin{
+		Name:  "json_stringify",
+		Usage: "json_stringify(value, indent?)",
+		Doc:   "Returns the JSON encoding of a value, with the keys of hashes sorted. When\nindent, a number of spaces or a string of at most 10 characters, is given, the\nvalues are written one per line and indented.",
+		Fn:    jsonStringify,
+	}
+}
+
+// json_parse(str) decodes a JSON document.
+func jsonParse(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to 'json_parse' must be STRING, got %s", args[0].Type())
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(str.Value))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return newError("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return newError("invalid JSON: unexpected end of input")
+		}
+		return newError("invalid JSON: %s", err)
+	}
+	end := decoder.InputOffset()
+	if _, err := decoder.Token(); err != io.EOF {
+		return newError("invalid JSON at offset %d: unexpected data after the value", end)
+	}
+	return fromJSON(value)
+}
+
+func fromJSON(value interface{}) object.Object {
+	switch value := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return nativeBoolToBooleanObject(value)
+	case string:
+		return &object.String{Value: value}
+	case json.Number:
+		integer, ok := new(big.Int).SetString(value.String(), 10)
+		if !ok {
+			return newError("invalid JSON: %s is not an integer", value)
+		}
+		return newBigInteger(integer)
+	case []interface{}:
+		elements := make([]object.Object, len(value))
+		for i, element := range value {
+			elements[i] = fromJSON(element)
+			if isError(elements[i]) {
+				return elements[i]
+			}
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for key, element := range value {
+			keyObj := &object.String{Value: key}
+			valueObj := fromJSON(element)
+			if isError(valueObj) {
+				return valueObj
+			}
+			pairs[keyObj.HashKey()] = object.HashPair{Key: keyObj, Value: valueObj}
+		}
+		return &object.Hash{Pairs: pairs}
+	}
+	return newError("invalid JSON: unexpected %T", value)
+}
+
+// maxIndent is the largest number of spaces or characters json_stringify
+// indents with, as in JavaScript's JSON.stringify.
+const maxIndent = 10
+
+// json_stringify(value, indent?) encodes a value as JSON.
+func jsonStringify(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments, got=%d, want=1 or 2", len(args))
+	}
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 {
+				return newError("indent of 'json_stringify' must not be negative, got %d", arg.Value)
+			}
+			if arg.Value > maxIndent {
+				return newError("indent of 'json_stringify' must be at most %d, got %d", maxIndent, arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *object.String:
+			if length := len([]rune(arg.Value)); length > maxIndent {
+				return newError("indent of 'json_stringify' must be at most %d characters long, got %d", maxIndent, length)
+			}
+			indent = arg.Value
+		default:
+			return newError("indent of 'json_stringify' must be INTEGER or STRING, got %s", args[1].Type())
+		}
+	}
+
+	value, err := toJSON(args[0], make(map[object.Object]bool))
+	if err != nil {
+		return newError("cannot stringify: %s", err)
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if len(args) == 2 {
+		encoder.SetIndent("", indent)
+	}
+	if err := encoder.Encode(value); err != nil {
+		return newError("cannot stringify: %s", err)
+	}
+	return &object.String{Value: strings.TrimSuffix(out.String(), "\n")}
+}
+
+// toJSON converts an object to the value encoding/json encodes the same way,
+// the maps being encoded with their keys sorted. The arrays and hashes being
+// converted are in visiting, to detect cycles.
+func toJSON(obj object.Object, visiting map[object.Object]bool) (interface{}, error) {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInt:
+		return json.Number(obj.Value.String()), nil
+	case *object.Time, *object.Duration:
+		return obj.Inspect(), nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array, *object.Hash:
+		if visiting[obj] {
+			return nil, fmt.Errorf("%s contains itself", obj.Type())
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+	default:
+		return nil, fmt.Errorf("%s values are not serializable", obj.Type())
+	}
+
+	if array, ok := obj.(*object.Array); ok {
+		elements := make([]interface{}, len(array.Elements))
+		for i, element := range array.Elements {
+			value, err := toJSON(element, visiting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	}
+
+	pairs := make(map[string]interface{})
+	for _, pair := range obj.(*object.Hash).Pairs {
+		var key string
+		switch k := pair.Key.(type) {
+		case *object.String:
+			key = k.Value
+		case *object.Integer, *object.BigInt, *object.Boolean:
+			key = k.Inspect()
+		default:
+			return nil, fmt.Errorf("%s keys are not serializable", k.Type())
+		}
+		if _, ok := pairs[key]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		value, err := toJSON(pair.Value, visiting)
+		if err != nil {
+			return nil, err
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
@@ -0,0 +1,179 @@
+package evaluator
+
+import (
+	"fmt"
+	"magot/ast"
+	"magot/object"
+	"sync/atomic"
+)
+
+// DefineMacros binds the top-level macro definitions of program in env and
+// removes them from the program.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+	for i := len(definitions) - 1; i >= 0; i-- {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement, _ := stmt.(*ast.LetStatement)
+	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros replaces the calls to the macros bound in env by the code they
+// return. Bindings introduced by a macro's own code are renamed, so that they
+// cannot capture or shadow the names used in the code passed to the macro.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		macro, ok := isMacroCall(callExpression, env)
+		if !ok {
+			return node
+		}
+		if len(callExpression.Arguments) != len(macro.Parameters) {
+			err = fmt.Errorf("wrong number of arguments to macro %s, got=%d, want=%d",
+				callExpression.Function, len(callExpression.Arguments), len(macro.Parameters))
+			return node
+		}
+
+		args := quoteArgs(callExpression)
+		evalEnv := extendMacroEnv(macro, args)
+		evaluated := unwrapReturnValue(Eval(macro.Body, evalEnv))
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			if isError(evaluated) {
+				err = fmt.Errorf("expanding macro %s: %s", callExpression.Function, evaluated.(*object.Error).Message)
+			} else {
+				err = fmt.Errorf("macro %s must return a QUOTE, got %s", callExpression.Function, typeOf(evaluated))
+			}
+			return node
+		}
+		return renameIntroducedBindings(quote.Node, args)
+	})
+	return expanded, err
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+	return extended
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
+
+var gensymCounter int64
+
+// renameIntroducedBindings gives fresh names to the let bindings and function
+// parameters that an expansion introduces outside of the macro's arguments,
+// along with the references to them.
+func renameIntroducedBindings(expansion ast.Node, args []*object.Quote) ast.Node {
+	fromArgs := make(map[ast.Node]bool)
+	for _, arg := range args {
+		ast.Inspect(arg.Node, func(node ast.Node) bool {
+			if node != nil {
+				fromArgs[node] = true
+			}
+			return true
+		})
+	}
+
+	renames := make(map[string]string)
+	introduce := func(ident *ast.Identifier) {
+		if _, ok := renames[ident.Value]; !ok {
+			id := atomic.AddInt64(&gensymCounter, 1)
+			renames[ident.Value] = fmt.Sprintf("%s__%d", ident.Value, id)
+		}
+	}
+	ast.Inspect(expansion, func(node ast.Node) bool {
+		if fromArgs[node] {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			introduce(node.Name)
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				introduce(param)
+			}
+		}
+		return true
+	})
+	if len(renames) == 0 {
+		return expansion
+	}
+
+	ast.Inspect(expansion, func(node ast.Node) bool {
+		if fromArgs[node] {
+			return false
+		}
+		if ident, ok := node.(*ast.Identifier); ok {
+			if renamed, ok := renames[ident.Value]; ok {
+				ident.Value = renamed
+				ident.Token.Literal = renamed
+			}
+		}
+		return true
+	})
+	return expansion
+}
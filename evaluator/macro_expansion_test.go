@@ -0,0 +1,167 @@
+package evaluator
+
+import (
+	"magot/ast"
+	"magot/lexer"
+	"magot/object"
+	"magot/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	expectedBody := "(x + y)"
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+let infixExpression = macro() { quote(1 + 2); };
+infixExpression();
+`,
+			`(1 + 2)`,
+		},
+		{
+			`
+let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+reverse(2 + 2, 10 - 5);
+`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+let unless = macro(condition, consequence, alternative) {
+	quote(if (!(unquote(condition))) {
+		unquote(consequence);
+	} else {
+		unquote(alternative);
+	});
+};
+unless(10 > 5, puts("not greater"), puts("greater"));
+`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`
+let unless = macro(cond, then) { quote(if (!(unquote(cond))) { unquote(then) }) };
+unless(1 > 2, 10) + unless(3 > 4, 30);
+`,
+			40,
+		},
+		{
+			`
+let assert = macro(cond) { quote(if (!(unquote(cond))) { "assertion failed" } else { "ok" }) };
+assert(1 < 2) + assert(2 < 1);
+`,
+			"okassertion failed",
+		},
+		{
+			`
+let twice = macro(exp) { quote(fn() { let x = unquote(exp); x + x }()) };
+let x = 1;
+twice(x + 1);
+`,
+			4,
+		},
+		{
+			`let m = macro(a) { quote(unquote(a)) }; m(1, 2);`,
+			"wrong number of arguments to macro m, got=2, want=1",
+		},
+		{
+			`let m = macro() { 1 }; m();`,
+			"macro m must return a QUOTE, got INTEGER",
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		macroEnv := object.NewEnvironment()
+		DefineMacros(program, macroEnv)
+		expanded, err := ExpandMacros(program, macroEnv)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			testIntegerObject(t, Eval(expanded, object.NewEnvironment()), int64(expected))
+		case string:
+			if err != nil {
+				if err.Error() != expected {
+					t.Errorf("wrong error. expected=%q, got=%q", expected, err)
+				}
+				continue
+			}
+			evaluated := Eval(expanded, object.NewEnvironment())
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result. expected=%q, got=%T(%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
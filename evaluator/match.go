@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"magot/ast"
+	"magot/object"
+)
+
+// evalMatchExpression evaluates the value of the first arm of a match
+// expression whose pattern the value matches and whose guard holds. Each arm
+// is evaluated in an environment enclosed by env, where the names bound by
+// its pattern are set before its guard is evaluated.
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+	tracer := evaluationOf(env).Tracer
+	for i, arm := range node.Arms {
+		bindings := map[string]object.Object{}
+		if !matchPattern(arm.Pattern, value, bindings) {
+			continue
+		}
+		armEnv := object.NewEnclosedEnvironment(env)
+		for name, bound := range bindings {
+			armEnv.Set(name, bound)
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			holds := isTruthy(guard)
+			if tracer != nil {
+				way := 1
+				if holds {
+					way = 0
+				}
+				tracer.Branch(arm.Guard, way)
+			}
+			if !holds {
+				continue
+			}
+		}
+		if tracer != nil {
+			tracer.Branch(node, i)
+		}
+		return Eval(arm.Value, armEnv)
+	}
+	return newError("no pattern matches %s", value.Inspect())
+}
+
+// matchPattern reports whether value matches pattern, adding the names the
+// pattern binds to bindings.
+func matchPattern(pattern ast.Pattern, value object.Object, bindings map[string]object.Object) bool {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		return equalLiteral(pattern, value)
+	case *ast.BindingPattern:
+		if pattern.Name.Value != "_" {
+			bindings[pattern.Name.Value] = value
+		}
+		return true
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return false
+		}
+		n := len(pattern.Elements)
+		if len(array.Elements) < n || (pattern.Rest == nil && len(array.Elements) != n) {
+			return false
+		}
+		for i, element := range pattern.Elements {
+			if !matchPattern(element, array.Elements[i], bindings) {
+				return false
+			}
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			rest := make([]object.Object, len(array.Elements)-n)
+			copy(rest, array.Elements[n:])
+			bindings[pattern.Rest.Value] = &object.Array{Elements: rest}
+		}
+		return true
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return false
+		}
+		for i, key := range pattern.Keys {
+			k := Eval(key.Value, nil).(object.Hashable)
+			pair, ok := hash.Pairs[k.HashKey()]
+			if !ok || !equalLiteral(key, pair.Key) || !matchPattern(pattern.Values[i], pair.Value, bindings) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// equalLiteral reports whether value is equal to the literal of pattern.
+func equalLiteral(pattern *ast.LiteralPattern, value object.Object) bool {
+	literal := Eval(pattern.Value, nil)
+	switch literal := literal.(type) {
+	case *object.Integer, *object.BigInt:
+		return isInteger(value) && bigValue(value).Cmp(bigValue(literal)) == 0
+	case *object.String:
+		str, ok := value.(*object.String)
+		return ok && str.Value == literal.Value
+	}
+	// booleans are singletons
+	return value == literal
+}
@@ -0,0 +1,213 @@
+package evaluator
+
+import (
+	"magot/object"
+	"math/big"
+	"math/rand"
+)
+
+func init() {
+	addModule("math", map[string]*object.Builtin{
+		"abs": {
+			Usage: `math["abs"](n)`,
+			Doc:   "Returns the absolute value of an integer.",
+			Fn:    mathAbs,
+		},
+		"min": {
+			Usage: `math["min"](values...)`,
+			Doc:   "Returns the smallest of the integers given, or of the elements of the array\ngiven alone.",
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return mathExtremum("min", args, -1)
+			},
+		},
+		"max": {
+			Usage: `math["max"](values...)`,
+			Doc:   "Returns the largest of the integers given, or of the elements of the array\ngiven alone.",
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return mathExtremum("max", args, 1)
+			},
+		},
+		"pow": {
+			Usage: `math["pow"](base, exponent)`,
+			Doc:   "Returns base raised to a power, which must not be negative.",
+			Fn:    mathPow,
+		},
+		"sqrt": {
+			Usage: `math["sqrt"](n)`,
+			Doc:   "Returns the square root of an integer that is not negative, rounded down.",
+			Fn:    mathSqrt,
+		},
+		"floor": {
+			Usage: `math["floor"](a, b)`,
+			Doc:   "Returns a divided by b rounded down, where a / b rounds toward zero.",
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return mathDivide("floor", args, -1)
+			},
+		},
+		"ceil": {
+			Usage: `math["ceil"](a, b)`,
+			Doc:   "Returns a divided by b rounded up, where a / b rounds toward zero.",
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return mathDivide("ceil", args, 1)
+			},
+		},
+		"gcd": {
+			Usage: `math["gcd"](a, b)`,
+			Doc:   "Returns the greatest common divisor of two integers, which is not negative.",
+			Fn:    mathGCD,
+		},
+		"random": {
+			Usage: `math["random"](n)`,
+			Doc:   "Returns a random integer from 0 up to n, n excluded.",
+			Fn:    mathRandom,
+		},
+		"seed": {
+			Usage: `math["seed"](n)`,
+			Doc:   "Seeds the random integers, which then come in the same sequence for the\nsame seed, and returns null.",
+			Fn:    mathSeed,
+		},
+	})
+}
+
+// integerArguments checks that the arguments of the math function called name
+// are want integers, and returns their values.
+func integerArguments(name string, args []object.Object, want int) ([]*big.Int, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments, got=%d, want=%d", len(args), want)
+	}
+	values := make([]*big.Int, len(args))
+	for i, arg := range args {
+		if !isInteger(arg) {
+			return nil, newError("argument to '%s' must be INTEGER, got %s", name, arg.Type())
+		}
+		values[i] = bigValue(arg)
+	}
+	return values, nil
+}
+
+func mathAbs(env *object.Environment, args ...object.Object) object.Object {
+	values, err := integerArguments("abs", args, 1)
+	if err != nil {
+		return err
+	}
+	if values[0].Sign() >= 0 {
+		return args[0]
+	}
+	return newBigInteger(new(big.Int).Neg(values[0]))
+}
+
+// mathExtremum returns the smallest of the arguments for a sign of -1, and
+// the largest for a sign of 1.
+func mathExtremum(name string, args []object.Object, sign int) object.Object {
+	if len(args) == 1 {
+		if array, ok := args[0].(*object.Array); ok {
+			args = array.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newError("argument to '%s' must not be empty", name)
+	}
+	var result object.Object
+	for _, arg := range args {
+		if !isInteger(arg) {
+			return newError("argument to '%s' must be INTEGER, got %s", name, arg.Type())
+		}
+		if result == nil || bigValue(arg).Cmp(bigValue(result)) == sign {
+			result = arg
+		}
+	}
+	return result
+}
+
+// MAX_POW_BITS bounds the size of the results of pow, whose computation
+// takes longer than programs are willing to wait past it.
+const MAX_POW_BITS = 1 << 20
+
+func mathPow(env *object.Environment, args ...object.Object) object.Object {
+	values, err := integerArguments("pow", args, 2)
+	if err != nil {
+		return err
+	}
+	if values[1].Sign() < 0 {
+		return newError("negative exponent: %s", values[1])
+	}
+	// a base of 0, 1 or -1 keeps its size, the others grow by at least one
+	// bit for each multiplication
+	if values[0].CmpAbs(big.NewInt(1)) > 0 {
+		bits := int64(values[0].BitLen() - 1)
+		if !values[1].IsInt64() || values[1].Int64() > MAX_POW_BITS || values[1].Int64()*bits > MAX_POW_BITS {
+			return newError("exponent too large: %s, results have at most %d bits", values[1], MAX_POW_BITS)
+		}
+	}
+	return newBigInteger(new(big.Int).Exp(values[0], values[1], nil))
+}
+
+func mathSqrt(env *object.Environment, args ...object.Object) object.Object {
+	values, err := integerArguments("sqrt", args, 1)
+	if err != nil {
+		return err
+	}
+	if values[0].Sign() < 0 {
+		return newError("square root of a negative integer: %s", values[0])
+	}
+	return newBigInteger(new(big.Int).Sqrt(values[0]))
+}
+
+// mathDivide divides its arguments, rounding down for a direction of -1 and
+// up for a direction of 1.
+func mathDivide(name string, args []object.Object, direction int) object.Object {
+	values, err := integerArguments(name, args, 2)
+	if err != nil {
+		return err
+	}
+	if values[1].Sign() == 0 {
+		return newError("division by zero")
+	}
+	quotient, remainder := new(big.Int).QuoRem(values[0], values[1], new(big.Int))
+	// the quotient was rounded toward zero, which is the wrong way when the
+	// exact one is on the side of direction
+	if remainder.Sign() != 0 && (values[0].Sign() == values[1].Sign()) == (direction > 0) {
+		quotient.Add(quotient, big.NewInt(int64(direction)))
+	}
+	return newBigInteger(quotient)
+}
+
+func mathGCD(env *object.Environment, args ...object.Object) object.Object {
+	values, err := integerArguments("gcd", args, 2)
+	if err != nil {
+		return err
+	}
+	return newBigInteger(new(big.Int).GCD(nil, nil, values[0], values[1]))
+}
+
+func mathRandom(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	n, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to 'random' must be INTEGER, got %s", args[0].Type())
+	}
+	if n.Value <= 0 {
+		return newError("argument to 'random' must be positive, got %d", n.Value)
+	}
+	e := evaluationOf(env)
+	e.randomLock.Lock()
+	defer e.randomLock.Unlock()
+	return &object.Integer{Value: e.Random.Int63n(n.Value)}
+}
+
+func mathSeed(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	seed, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to 'seed' must be INTEGER, got %s", args[0].Type())
+	}
+	e := evaluationOf(env)
+	e.randomLock.Lock()
+	defer e.randomLock.Unlock()
+	e.Random = rand.New(rand.NewSource(seed.Value))
+	return NULL
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"magot/object"
+	"sort"
+	"strings"
+)
+
+// modules maps the names of the builtin modules to the hashes of their
+// functions, which programs call as fs["read_file"](path).
+var modules = map[string]*object.Hash{}
+
+// addModule adds a module of builtin functions, which are named after the
+// module and their key, such as fs.read_file.
+func addModule(name string, functions map[string]*object.Builtin) {
+	pairs := make(map[object.HashKey]object.HashPair)
+	for key, fn := range functions {
+		fn.Name = name + "." + key
+		keyObj := &object.String{Value: key}
+		pairs[keyObj.HashKey()] = object.HashPair{Key: keyObj, Value: fn}
+	}
+	modules[name] = &object.Hash{Pairs: pairs}
+}
+
+// LookupModule returns the builtin module called name.
+func LookupModule(name string) (*object.Hash, bool) {
+	module, ok := modules[name]
+	return module, ok
+}
+
+// ModuleNames returns the names of the builtin modules, sorted.
+func ModuleNames() []string {
+	names := []string{}
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// moduleFunction returns the function of a module named module.key.
+func moduleFunction(name string) (*object.Builtin, bool) {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return nil, false
+	}
+	module, ok := modules[name[:i]]
+	if !ok {
+		return nil, false
+	}
+	key := &object.String{Value: name[i+1:]}
+	pair, ok := module.Pairs[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	builtin, ok := pair.Value.(*object.Builtin)
+	return builtin, ok
+}
@@ -0,0 +1,80 @@
+package evaluator
+
+import (
+	"fmt"
+	"magot/ast"
+	"magot/object"
+	"magot/token"
+)
+
+// quote returns the unevaluated node, with the unquote calls it contains
+// replaced by their evaluated arguments, or the error of the first argument
+// that fails to evaluate or has no literal to replace its call with. The node
+// is copied first so that the source tree, such as a macro body expanded more
+// than once, is left intact.
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node, failure := evalUnquoteCalls(ast.Copy(node), env)
+	if failure != nil {
+		return failure
+	}
+	return &object.Quote{Node: node}
+}
+
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, object.Object) {
+	var failure object.Object
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if failure != nil || !isUnquoteCall(node) {
+			return node
+		}
+		call, ok := node.(*ast.CallExpression)
+		if !ok || len(call.Arguments) != 1 {
+			return node
+		}
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			failure = unquoted
+			return node
+		}
+		converted := convertObjectToASTNode(unquoted)
+		if converted == nil {
+			failure = newError("cannot unquote: %s values have no literal", typeOf(unquoted))
+			return node
+		}
+		return converted
+	})
+	return node, failure
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+func convertObjectToASTNode(obj object.Object) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.BigInt:
+		t := token.Token{Type: token.INT, Literal: obj.Value.String()}
+		return &ast.BigIntegerLiteral{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+	case *object.Quote:
+		return obj.Node
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,248 @@
+package evaluator
+
+import (
+	"magot/object"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The replace builtin calls functions, which refers back to the builtins
+// table, so the pattern builtins are added once the table is initialized.
+func init() {
+	for _, builtin := range []*object.Builtin{
+		{
+			Name:  "regex",
+			Usage: "regex(pattern)",
+			Doc:   "Returns a compiled regular expression, in the syntax of Go's regexp package.\nThe other pattern builtins take either a regex or a pattern string.",
+			Fn:    regex,
+		},
+		{
+			Name:  "match",
+			Usage: "match(pattern, str)",
+			Doc:   "Returns whether pattern matches a part of str.",
+			Fn:    regexMatch,
+		},
+		{
+			Name:  "find_all",
+			Usage: "find_all(pattern, str, n?)",
+			Doc:   "Returns the matches of pattern in str, or the first n of them if n is given\nand not negative.",
+			Fn:    regexFindAll,
+		},
+		{
+			Name:  "captures",
+			Usage: "captures(pattern, str)",
+			Doc:   "Returns the groups of the first match of pattern in str as a hash, from\ntheir index, 0 being the whole match, and from the names of the named\nones. Groups that did not take part in the match are null. Returns null\nwhen there is no match.",
+			Fn:    regexCaptures,
+		},
+		{
+			Name:  "replace",
+			Usage: "replace(pattern, str, replacement)",
+			Doc:   "Returns str with the matches of pattern replaced. The replacement is either\na string, in which $1 or $${name} stand for groups, or a function called with\neach match and returning its replacement.",
+			Fn:    regexReplace,
+		},
+		{
+			Name:  "split",
+			Usage: "split(pattern, str, n?)",
+			Doc:   "Returns the parts of str between the matches of pattern, at most n of them\nif n is given and not negative.",
+			Fn:    regexSplit,
+		},
+	} {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+// patterns caches the regular expressions compiled from pattern strings.
+var patterns = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: make(map[string]*regexp.Regexp)}
+
+// MAX_CACHED_PATTERNS bounds the cache, which is emptied once it is full so
+// that programs building many patterns do not grow it forever.
+const MAX_CACHED_PATTERNS = 256
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patterns.Lock()
+	defer patterns.Unlock()
+	if re, ok := patterns.compiled[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns.compiled) >= MAX_CACHED_PATTERNS {
+		patterns.compiled = make(map[string]*regexp.Regexp)
+	}
+	patterns.compiled[pattern] = re
+	return re, nil
+}
+
+// patternArguments checks the arguments of the pattern builtin called name,
+// a regex or a pattern string followed by a string, and returns them.
+func patternArguments(name string, args []object.Object, min, max int) (*regexp.Regexp, string, *object.Error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return nil, "", newError("wrong number of arguments, got=%d, want=%d", len(args), min)
+		}
+		return nil, "", newError("wrong number of arguments, got=%d, want=%d or %d", len(args), min, max)
+	}
+	var re *regexp.Regexp
+	switch pattern := args[0].(type) {
+	case *object.Regex:
+		re = pattern.Regexp
+	case *object.String:
+		var err error
+		if re, err = compilePattern(pattern.Value); err != nil {
+			return nil, "", patternError(err)
+		}
+	default:
+		return nil, "", newError("argument to '%s' must be REGEX or STRING, got %s", name, args[0].Type())
+	}
+	str, ok := args[1].(*object.String)
+	if !ok {
+		return nil, "", newError("argument to '%s' must be STRING, got %s", name, args[1].Type())
+	}
+	return re, str.Value, nil
+}
+
+func patternError(err error) *object.Error {
+	return newError("invalid pattern: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+}
+
+// countArgument returns the optional count of matches at index i of args, -1
+// standing for all of them.
+func countArgument(name string, args []object.Object, i int) (int, *object.Error) {
+	if len(args) <= i {
+		return -1, nil
+	}
+	n, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, newError("argument to '%s' must be INTEGER, got %s", name, args[i].Type())
+	}
+	return int(n.Value), nil
+}
+
+func stringArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, str := range strs {
+		elements[i] = &object.String{Value: str}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func regex(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	switch pattern := args[0].(type) {
+	case *object.Regex:
+		return pattern
+	case *object.String:
+		re, err := compilePattern(pattern.Value)
+		if err != nil {
+			return patternError(err)
+		}
+		return &object.Regex{Regexp: re}
+	}
+	return newError("argument to 'regex' must be STRING, got %s", args[0].Type())
+}
+
+func regexMatch(env *object.Environment, args ...object.Object) object.Object {
+	re, str, err := patternArguments("match", args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(re.MatchString(str))
+}
+
+func regexFindAll(env *object.Environment, args ...object.Object) object.Object {
+	re, str, err := patternArguments("find_all", args, 2, 3)
+	if err != nil {
+		return err
+	}
+	n, err := countArgument("find_all", args, 2)
+	if err != nil {
+		return err
+	}
+	return stringArray(re.FindAllString(str, n))
+}
+
+func regexCaptures(env *object.Environment, args ...object.Object) object.Object {
+	re, str, err := patternArguments("captures", args, 2, 2)
+	if err != nil {
+		return err
+	}
+	match := re.FindStringSubmatchIndex(str)
+	if match == nil {
+		return NULL
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	add := func(key object.Hashable, value object.Object) {
+		pairs[key.HashKey()] = object.HashPair{Key: key.(object.Object), Value: value}
+	}
+	for i, name := range re.SubexpNames() {
+		var value object.Object = NULL
+		if match[2*i] >= 0 {
+			value = &object.String{Value: str[match[2*i]:match[2*i+1]]}
+		}
+		add(&object.Integer{Value: int64(i)}, value)
+		if name != "" {
+			add(&object.String{Value: name}, value)
+		}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+func regexReplace(env *object.Environment, args ...object.Object) object.Object {
+	re, str, err := patternArguments("replace", args, 3, 3)
+	if err != nil {
+		return err
+	}
+	switch replacement := args[2].(type) {
+	case *object.String:
+		return &object.String{Value: re.ReplaceAllString(str, replacement.Value)}
+	case *object.Function, *object.Builtin:
+		if fn, ok := replacement.(*object.Function); ok && len(fn.Parameters) != 1 {
+			return newError("argument to 'replace' must take 1 argument, got %d parameters", len(fn.Parameters))
+		}
+		// The replacements are computed until one fails, whose error is
+		// returned.
+		var failure object.Object
+		replaced := re.ReplaceAllStringFunc(str, func(match string) string {
+			if failure != nil {
+				return match
+			}
+			result := orNull(applyFunction(env, replacement, []object.Object{&object.String{Value: match}}))
+			s, ok := result.(*object.String)
+			switch {
+			case isError(result):
+				failure = result
+			case !ok:
+				failure = newError("replacement function must return STRING, got %s", result.Type())
+			default:
+				return s.Value
+			}
+			return match
+		})
+		if failure != nil {
+			return failure
+		}
+		return &object.String{Value: replaced}
+	}
+	return newError("argument to 'replace' must be STRING or FUNCTION, got %s", args[2].Type())
+}
+
+func regexSplit(env *object.Environment, args ...object.Object) object.Object {
+	re, str, err := patternArguments("split", args, 2, 3)
+	if err != nil {
+		return err
+	}
+	n, err := countArgument("split", args, 2)
+	if err != nil {
+		return err
+	}
+	return stringArray(re.Split(str, n))
+}
@@ -0,0 +1,302 @@
+package evaluator
+
+import (
+	"cmp"
+	"magot/object"
+	"time"
+)
+
+func init() {
+	for _, builtin := range []*object.Builtin{
+		{
+			Name:  "now",
+			Usage: "now()",
+			Doc:   "Returns the current time in the local time zone.",
+			Fn:    timeNow,
+		},
+		{
+			Name:  "parse_time",
+			Usage: "parse_time(str, layout?, zone?)",
+			Doc:   "Returns the time str stands for in layout, which is RFC 3339 by default\nand otherwise shows how Go's reference time, 2006-01-02T15:04:05Z07:00,\nwould be written. The times without a time zone offset are in zone,\nsuch as \"Europe/Paris\", or in UTC.",
+			Fn:    timeParse,
+		},
+		{
+			Name:  "format_time",
+			Usage: "format_time(t, layout?)",
+			Doc:   "Returns a time as a string in layout, which is RFC 3339 by default and\notherwise shows how Go's reference time, 2006-01-02T15:04:05Z07:00, would\nbe written.",
+			Fn:    timeFormat,
+		},
+		{
+			Name:  "in_zone",
+			Usage: "in_zone(t, zone)",
+			Doc:   "Returns the same instant as a time in a time zone, such as \"UTC\",\n\"Local\" or \"America/New_York\".",
+			Fn:    timeInZone,
+		},
+		{
+			Name:  "time_fields",
+			Usage: "time_fields(t)",
+			Doc:   "Returns a hash of the fields of a time in its time zone: its year, month,\nday, hour, minute, second, weekday from 0 for Sunday to 6, and zone.",
+			Fn:    timeFields,
+		},
+		{
+			Name:  "unix",
+			Usage: "unix(t)",
+			Doc:   "Returns a time as a number of seconds since the Unix epoch.",
+			Fn:    timeUnix,
+		},
+		{
+			Name:  "from_unix",
+			Usage: "from_unix(seconds)",
+			Doc:   "Returns the time in UTC a number of seconds after the Unix epoch.",
+			Fn:    timeFromUnix,
+		},
+		{
+			Name:  "duration",
+			Usage: "duration(str)",
+			Doc:   "Returns the duration str stands for, such as \"1h30m\", \"-90s\" or \"250ms\".\nDurations are added to and subtracted from times, subtracting times gives\ntheir duration, and durations are multiplied and divided by integers.",
+			Fn:    timeDuration,
+		},
+	} {
+		builtins[builtin.Name] = builtin
+	}
+}
+
+func isTemporal(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Time, *object.Duration:
+		return true
+	}
+	return false
+}
+
+// evalTimeInfixExpression evaluates the operations on times and durations,
+// returning nil for the other ones.
+func evalTimeInfixExpression(operator string, left, right object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Time:
+		switch right := right.(type) {
+		case *object.Time:
+			if operator == "-" {
+				return &object.Duration{Value: left.Value.Sub(right.Value)}
+			}
+			return compare(operator, left.Value.Compare(right.Value))
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Time{Value: left.Value.Add(right.Value)}
+			case "-":
+				return &object.Time{Value: left.Value.Add(-right.Value)}
+			}
+		}
+	case *object.Duration:
+		switch right := right.(type) {
+		case *object.Time:
+			if operator == "+" {
+				return &object.Time{Value: right.Value.Add(left.Value)}
+			}
+		case *object.Duration:
+			switch operator {
+			case "+", "-", "%":
+				return durationArithmetic(operator, left.Value, int64(right.Value))
+			case "/":
+				return evalIntegerArithmetic(operator, &object.Integer{Value: int64(left.Value)}, &object.Integer{Value: int64(right.Value)})
+			}
+			return compare(operator, cmp.Compare(left.Value, right.Value))
+		case *object.Integer:
+			switch operator {
+			case "*", "/":
+				return durationArithmetic(operator, left.Value, right.Value)
+			}
+		}
+	case *object.Integer:
+		if right, ok := right.(*object.Duration); ok && operator == "*" {
+			return durationArithmetic(operator, right.Value, left.Value)
+		}
+	}
+	return nil
+}
+
+// compare returns the result of a comparison operator applied to two values,
+// given the sign of their difference, or nil for the other operators.
+func compare(operator string, sign int) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(sign == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(sign != 0)
+	case "<":
+		return nativeBoolToBooleanObject(sign < 0)
+	case ">":
+		return nativeBoolToBooleanObject(sign > 0)
+	}
+	return nil
+}
+
+// durationArithmetic applies an arithmetic operator to a duration and a
+// number of nanoseconds, failing when the result overflows.
+func durationArithmetic(operator string, d time.Duration, n int64) object.Object {
+	result := evalIntegerArithmetic(operator, &object.Integer{Value: int64(d)}, &object.Integer{Value: n})
+	switch result := result.(type) {
+	case *object.Integer:
+		return &object.Duration{Value: time.Duration(result.Value)}
+	case *object.BigInt:
+		return newError("duration out of range: %s %s %d", d, operator, n)
+	}
+	return result
+}
+
+// timeArgument checks the arguments of the time builtin called name, the
+// first of which is a time, and returns it.
+func timeArgument(name string, args []object.Object, min, max int) (time.Time, *object.Error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return time.Time{}, newError("wrong number of arguments, got=%d, want=%d", len(args), min)
+		}
+		return time.Time{}, newError("wrong number of arguments, got=%d, want=%d or %d", len(args), min, max)
+	}
+	t, ok := args[0].(*object.Time)
+	if !ok {
+		return time.Time{}, newError("argument to '%s' must be TIME, got %s", name, args[0].Type())
+	}
+	return t.Value, nil
+}
+
+// stringArgument returns the optional string at index i of args, or def
+// when it is missing.
+func stringArgument(name string, args []object.Object, i int, def string) (string, *object.Error) {
+	if len(args) <= i {
+		return def, nil
+	}
+	str, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("argument to '%s' must be STRING, got %s", name, args[i].Type())
+	}
+	return str.Value, nil
+}
+
+func loadZone(name string) (*time.Location, *object.Error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+	return loc, nil
+}
+
+func timeNow(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments, got=%d, want=0", len(args))
+	}
+	return &object.Time{Value: evaluationOf(env).Now()}
+}
+
+func timeParse(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments, got=%d, want=1 to 3", len(args))
+	}
+	str, err := stringArgument("parse_time", args, 0, "")
+	if err != nil {
+		return err
+	}
+	layout, err := stringArgument("parse_time", args, 1, time.RFC3339)
+	if err != nil {
+		return err
+	}
+	zone, err := stringArgument("parse_time", args, 2, "UTC")
+	if err != nil {
+		return err
+	}
+	loc, err := loadZone(zone)
+	if err != nil {
+		return err
+	}
+	t, parseErr := time.ParseInLocation(layout, str, loc)
+	if parseErr != nil {
+		return newError("%s", parseErr)
+	}
+	return &object.Time{Value: t}
+}
+
+func timeFormat(env *object.Environment, args ...object.Object) object.Object {
+	t, err := timeArgument("format_time", args, 1, 2)
+	if err != nil {
+		return err
+	}
+	layout, err := stringArgument("format_time", args, 1, time.RFC3339)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: t.Format(layout)}
+}
+
+func timeInZone(env *object.Environment, args ...object.Object) object.Object {
+	t, err := timeArgument("in_zone", args, 2, 2)
+	if err != nil {
+		return err
+	}
+	zone, err := stringArgument("in_zone", args, 1, "")
+	if err != nil {
+		return err
+	}
+	loc, err := loadZone(zone)
+	if err != nil {
+		return err
+	}
+	return &object.Time{Value: t.In(loc)}
+}
+
+func timeFields(env *object.Environment, args ...object.Object) object.Object {
+	t, err := timeArgument("time_fields", args, 1, 1)
+	if err != nil {
+		return err
+	}
+	zone, _ := t.Zone()
+	pairs := make(map[object.HashKey]object.HashPair)
+	for key, value := range map[string]object.Object{
+		"year":    &object.Integer{Value: int64(t.Year())},
+		"month":   &object.Integer{Value: int64(t.Month())},
+		"day":     &object.Integer{Value: int64(t.Day())},
+		"hour":    &object.Integer{Value: int64(t.Hour())},
+		"minute":  &object.Integer{Value: int64(t.Minute())},
+		"second":  &object.Integer{Value: int64(t.Second())},
+		"weekday": &object.Integer{Value: int64(t.Weekday())},
+		"zone":    &object.String{Value: zone},
+	} {
+		keyObj := &object.String{Value: key}
+		pairs[keyObj.HashKey()] = object.HashPair{Key: keyObj, Value: value}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+func timeUnix(env *object.Environment, args ...object.Object) object.Object {
+	t, err := timeArgument("unix", args, 1, 1)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: t.Unix()}
+}
+
+func timeFromUnix(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	seconds, ok := args[0].(*object.Integer)
+	if !ok {
+		return newError("argument to 'from_unix' must be INTEGER, got %s", args[0].Type())
+	}
+	return &object.Time{Value: time.Unix(seconds.Value, 0).UTC()}
+}
+
+func timeDuration(env *object.Environment, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments, got=%d, want=1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to 'duration' must be STRING, got %s", args[0].Type())
+	}
+	d, err := time.ParseDuration(str.Value)
+	if err != nil {
+		return newError("%s", err)
+	}
+	return &object.Duration{Value: d}
+}
@@ -0,0 +1,50 @@
+package evaluator
+
+import (
+	"magot/ast"
+	"magot/object"
+)
+
+// Tracer is notified of the progress of evaluations, for tools such as
+// coverage reports. The functions run by spawn notify it from their own
+// goroutines, concurrently.
+type Tracer interface {
+	// Statement is called before stmt is evaluated in env.
+	Statement(stmt ast.Statement, env *object.Environment)
+	// Branch is called when an expression branches, with the index of the
+	// way it takes: 0 for the consequence of an if expression and 1 for its
+	// alternative, which may be missing, 0 when the guard of a match arm
+	// holds and 1 when it does not, the index of the arm of a match
+	// expression that is taken and the index of the case of a select
+	// expression, its default case counting last.
+	Branch(node ast.Expression, way int)
+}
+
+// CallTracer is a Tracer also notified of the function calls.
+type CallTracer interface {
+	Tracer
+	// Call is called before fn is applied to the arguments of call.
+	Call(call *ast.CallExpression, fn object.Object)
+	// Return is called once fn returns.
+	Return(call *ast.CallExpression, fn object.Object)
+}
+
+// GoroutineTracer is a Tracer also notified of the goroutines evaluating
+// the functions run by spawn and the bodies of generators.
+type GoroutineTracer interface {
+	Tracer
+	// Goroutine is called in a new goroutine, named after what started
+	// it, and returns the result of evaluate, which it calls.
+	Goroutine(name string, evaluate func() object.Object) object.Object
+}
+
+// traceGoroutine evaluates in the goroutine it is called from, which spawn
+// or a generator started in env, notifying the goroutine tracer of the
+// context of env if there is one.
+func traceGoroutine(env *object.Environment, name string, evaluate func() object.Object) object.Object {
+	goroutineTracer := evaluationOf(env).goroutineTracer
+	if goroutineTracer == nil {
+		return evaluate()
+	}
+	return goroutineTracer.Goroutine(name, evaluate)
+}
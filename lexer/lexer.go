@@ -1,21 +1,40 @@
 package lexer
 
-import "magot/token"
+import (
+	"errors"
+	"magot/token"
+	"strings"
+)
 
 type Lexer struct {
 	input     string
 	index     int  // current char index
 	readIndex int  // current read index (after current char)
 	ch        byte // char being examined
+	line      int  // line of the char being examined
+	column    int  // column of the char being examined
+	tokenLine int  // line the last token ended on
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewAt(input, 1, 1)
+}
+
+// NewAt returns a lexer for input starting at a line and a column of a larger
+// source, such as the expressions interpolated in strings.
+func NewAt(input string, line, column int) *Lexer {
+	l := &Lexer{input: input, line: line, column: column - 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
 	if l.readIndex >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -32,10 +51,43 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readIndex]
 }
 
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+// skipWhitespace skips the whitespace and the // comments up to the next
+// token, and returns the doc of the token: the comment lines right above it,
+// each of them alone on its line.
+func (l *Lexer) skipWhitespace() string {
+	var doc []string
+	docLine := 0 // the line of the last comment of doc
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			line := l.line
+			comment := l.readComment()
+			if line == l.tokenLine || (doc != nil && line != docLine+1) {
+				doc = nil
+			}
+			if line != l.tokenLine {
+				doc, docLine = append(doc, comment), line
+			}
+		default:
+			if doc == nil || l.line != docLine+1 {
+				return ""
+			}
+			return strings.Join(doc, "\n")
+		}
+	}
+}
+
+// readComment reads a comment up to the end of its line, and returns its text
+// without the // marker and the space after it.
+func (l *Lexer) readComment() string {
+	index := l.index + 2
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	text := strings.TrimRight(l.input[index:l.index], "\r")
+	return strings.TrimPrefix(text, " ")
 }
 
 func newToken(tokType token.TokenType, ch byte) token.Token {
@@ -43,15 +95,27 @@ func newToken(tokType token.TokenType, ch byte) token.Token {
 }
 
 func (l *Lexer) NextToken() token.Token {
+	doc := l.skipWhitespace()
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line, tok.Column, tok.Doc = line, column, doc
+	l.tokenLine = l.line
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			l.readChar()
 			tok.Type = token.EQ
 			tok.Literal = "=="
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok.Type = token.ARROW
+			tok.Literal = "=>"
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -71,6 +135,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.MUL, l.ch)
 	case '/':
 		tok = newToken(token.DIV, l.ch)
+	case '%':
+		tok = newToken(token.MOD, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -93,9 +159,22 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if strings.HasPrefix(l.input[l.index:], "...") {
+			l.readChar()
+			l.readChar()
+			tok.Type = token.ELLIPSIS
+			tok.Literal = "..."
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, interpolated := l.readString()
+		if interpolated {
+			tok.Type = token.INTERPOLATED
+		}
+		tok.Literal = literal
 	case 0:
 		tok.Type = token.EOF
 		tok.Literal = ""
@@ -116,15 +195,112 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) readString() string {
+// readString reads a string up to its closing quote, and reports whether it
+// holds interpolations, whose expressions may hold strings of their own. A run
+// of dollar signs before a brace counts as one too, its $$ being escaped $, so
+// that InterpolationParts unescapes them.
+func (l *Lexer) readString() (string, bool) {
 	index := l.index + 1
+	interpolated := false
 	for {
 		l.readChar()
+		if l.ch == '$' {
+			dollars := l.readDollars()
+			if l.peekChar() == '{' {
+				interpolated = true
+				if dollars%2 == 1 {
+					l.readChar()
+					l.skipInterpolation()
+				}
+			}
+		}
 		if l.ch == '"' || l.ch == 0 {
-			break
+			return l.input[index:l.index], interpolated
 		}
 	}
-	return l.input[index:l.index]
+}
+
+// readDollars reads a run of dollar signs up to its last one, and returns its
+// length.
+func (l *Lexer) readDollars() int {
+	dollars := 1
+	for l.peekChar() == '$' {
+		l.readChar()
+		dollars++
+	}
+	return dollars
+}
+
+// skipInterpolation skips the expression of an interpolation from its opening
+// brace up to its closing one, or up to the end of the input.
+func (l *Lexer) skipInterpolation() {
+	for depth := 1; depth > 0 && l.ch != 0; {
+		l.readChar()
+		switch l.ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			l.readString()
+		}
+	}
+}
+
+// Part is a piece of an interpolated string, text or the source of an
+// interpolated expression, along with the position it starts at.
+type Part struct {
+	Text         string
+	Expression   bool
+	Line, Column int
+}
+
+// InterpolationParts splits the literal of a token.INTERPOLATED token into
+// its parts, failing when an interpolation is not closed. Before a brace, $$
+// stands for $ and a dollar sign left over starts an interpolation, so that
+// $${ is the text ${ and $$${ a $ followed by an interpolation.
+func InterpolationParts(tok token.Token) ([]Part, error) {
+	// The literal starts right after the opening quote.
+	l := NewAt(tok.Literal, tok.Line, tok.Column+1)
+	parts := []Part{}
+	text := Part{Line: l.line, Column: l.column}
+	index := l.index
+	for l.ch != 0 {
+		if l.ch != '$' {
+			l.readChar()
+			continue
+		}
+		run := l.index
+		dollars := l.readDollars()
+		if l.peekChar() != '{' {
+			l.readChar()
+			continue
+		}
+		text.Text += l.input[index:run] + strings.Repeat("$", dollars/2)
+		if dollars%2 == 0 {
+			// The text goes on from the brace.
+			l.readChar()
+			index = l.index
+			continue
+		}
+		if text.Text != "" {
+			parts = append(parts, text)
+		}
+		line, column := l.line, l.column
+		l.readChar()
+		start := l.index + 1
+		l.skipInterpolation()
+		if l.ch == 0 {
+			return nil, errors.New("unterminated interpolation")
+		}
+		parts = append(parts, Part{Text: l.input[start:l.index], Expression: true, Line: line, Column: column + 2})
+		l.readChar()
+		text, index = Part{Line: l.line, Column: l.column}, l.index
+	}
+	if text.Text += l.input[index:]; text.Text != "" {
+		parts = append(parts, text)
+	}
+	return parts, nil
 }
 
 func (l *Lexer) readNumber() string {
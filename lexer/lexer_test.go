@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"fmt"
 	"magot/token"
 	"testing"
 )
@@ -14,7 +15,7 @@ let add = fn(x, y) {
 };
 let result = add(five, ten);
 
-!-/*5;
+!-/*%5;
 
 if (5 < 10) {
   return true;
@@ -28,6 +29,7 @@ if (5 < 10) {
 " foo bar "
 [1, 2, 3];
 {"foo": "bar"}
+[x, ...xs] => x
 `
 
 	tests := []struct {
@@ -74,6 +76,7 @@ if (5 < 10) {
 		{token.MINUS, "-"},
 		{token.DIV, "/"},
 		{token.MUL, "*"},
+		{token.MOD, "%"},
 		{token.INT, "5"},
 		{token.SEMICOLON, ";"},
 		{token.IF, "if"},
@@ -123,6 +126,14 @@ if (5 < 10) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "xs"},
+		{token.RBRACKET, "]"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "x"},
 		{token.EOF, ""},
 	}
 
@@ -138,3 +149,151 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  "foo" + x`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"foo", 2, 3},
+		{"+", 2, 9},
+		{"x", 2, 11},
+		{"", 2, 12},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong literal. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - wrong position. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// A file comment.
+
+// add adds
+// two integers.
+//
+//   add(1, 2)
+let add = fn(a, b) { a + b }; // not a doc
+// detached
+
+let x = 1 // trailing
+// doc of y
+let y = 2;
+let z = "//"; //`
+
+	docs := map[string]string{}
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.ILLEGAL || tok.Type == token.DIV {
+			t.Fatalf("comment lexed as %s %q at %d:%d", tok.Type, tok.Literal, tok.Line, tok.Column)
+		}
+		if tok.Doc != "" {
+			docs[fmt.Sprintf("%s %d:%d", tok.Literal, tok.Line, tok.Column)] = tok.Doc
+		}
+	}
+
+	expected := map[string]string{
+		"let 7:1":  "add adds\ntwo integers.\n\n  add(1, 2)",
+		"let 12:1": "doc of y",
+	}
+	if len(docs) != len(expected) {
+		t.Fatalf("wrong docs. expected=%q, got=%q", expected, docs)
+	}
+	for key, doc := range expected {
+		if docs[key] != doc {
+			t.Errorf("wrong doc of %s. expected=%q, got=%q", key, doc, docs[key])
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	input := `"a ${b} c" "x ${ {"k": "}"}["k"] }" "${"in ${d}"}" "${e"`
+
+	l := New(input)
+	tokens := []token.Token{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	expected := []string{`a ${b} c`, `x ${ {"k": "}"}["k"] }`, `${"in ${d}"}`, `${e"`}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong tokens. expected=%q, got=%+v", expected, tokens)
+	}
+	for i, tok := range tokens {
+		if tok.Type != token.INTERPOLATED || tok.Literal != expected[i] {
+			t.Errorf("tokens[%d] - expected INTERPOLATED %q, got %s %q", i, expected[i], tok.Type, tok.Literal)
+		}
+	}
+
+	parts, err := InterpolationParts(tokens[0])
+	if err != nil {
+		t.Fatalf("InterpolationParts failed: %s", err)
+	}
+	expectedParts := []Part{
+		{Text: "a ", Line: 1, Column: 2},
+		{Text: "b", Expression: true, Line: 1, Column: 6},
+		{Text: " c", Line: 1, Column: 8},
+	}
+	if len(parts) != len(expectedParts) {
+		t.Fatalf("wrong parts. expected=%+v, got=%+v", expectedParts, parts)
+	}
+	for i, part := range parts {
+		if part != expectedParts[i] {
+			t.Errorf("parts[%d] - expected %+v, got %+v", i, expectedParts[i], part)
+		}
+	}
+
+	parts, err = InterpolationParts(tokens[2])
+	if err != nil || len(parts) != 1 || parts[0].Text != `"in ${d}"` {
+		t.Errorf("wrong parts of a nested interpolation: %+v, %v", parts, err)
+	}
+	if _, err := InterpolationParts(tokens[3]); err == nil {
+		t.Errorf("expected an error for an unterminated interpolation")
+	}
+}
+
+func TestEscapedInterpolation(t *testing.T) {
+	tok := New(`"a $${b} ${c} $$${d} $$$${ $$ $"`).NextToken()
+	if tok.Type != token.INTERPOLATED || tok.Literal != `a $${b} ${c} $$${d} $$$${ $$ $` {
+		t.Fatalf("wrong token. got %s %q", tok.Type, tok.Literal)
+	}
+	parts, err := InterpolationParts(tok)
+	if err != nil {
+		t.Fatalf("InterpolationParts failed: %s", err)
+	}
+	expectedParts := []Part{
+		{Text: "a ${b} ", Line: 1, Column: 2},
+		{Text: "c", Expression: true, Line: 1, Column: 12},
+		{Text: " $", Line: 1, Column: 14},
+		{Text: "d", Expression: true, Line: 1, Column: 19},
+		{Text: " $${ $$ $", Line: 1, Column: 21},
+	}
+	if len(parts) != len(expectedParts) {
+		t.Fatalf("wrong parts. expected=%+v, got=%+v", expectedParts, parts)
+	}
+	for i, part := range parts {
+		if part != expectedParts[i] {
+			t.Errorf("parts[%d] - expected %+v, got %+v", i, expectedParts[i], part)
+		}
+	}
+
+	if tok := New(`"$$ $1"`).NextToken(); tok.Type != token.STRING || tok.Literal != "$$ $1" {
+		t.Errorf("wrong token. got %s %q", tok.Type, tok.Literal)
+	}
+}
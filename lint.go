@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"magot/lexer"
+	"magot/lint"
+	"magot/parser"
+	"os"
+)
+
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: magot lint file.mg...\n\n")
+		fmt.Fprintf(os.Stderr, "Reports problems found in Magot programs as a JSON array.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	diagnostics := []lint.Diagnostic{}
+	for _, file := range flags.Args() {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for _, diagnostic := range lintSource(string(source)) {
+			diagnostic.File = file
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(diagnostics); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// lintSource lints a program, or reports its syntax errors when it does not
+// parse.
+func lintSource(source string) []lint.Diagnostic {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) == 0 {
+		return lint.Lint(program)
+	}
+
+	diagnostics := []lint.Diagnostic{}
+	for _, err := range p.ParseErrors() {
+		diagnostics = append(diagnostics, lint.Diagnostic{
+			Line:    err.Line,
+			Column:  err.Column,
+			Rule:    lint.SYNTAX_ERROR,
+			Message: err.Message,
+		})
+	}
+	return diagnostics
+}
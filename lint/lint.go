@@ -0,0 +1,446 @@
+package lint
+
+import (
+	"fmt"
+	"magot/ast"
+	"magot/object"
+	"magot/token"
+	"sort"
+	"strings"
+)
+
+const (
+	SYNTAX_ERROR          = "syntax-error"
+	UNUSED_VARIABLE       = "unused-variable"
+	SHADOWED_BUILTIN      = "shadowed-builtin"
+	USE_BEFORE_DEFINITION = "use-before-definition"
+	UNDEFINED_IDENTIFIER  = "undefined-identifier"
+	UNREACHABLE_CODE      = "unreachable-code"
+	WRONG_ARGUMENT_COUNT  = "wrong-argument-count"
+	NOT_A_FUNCTION        = "not-a-function"
+	TYPE_MISMATCH         = "type-mismatch"
+)
+
+// builtinArity lists the evaluator's builtins with their arity, -1 marking
+// variadic ones and the ones taking optional arguments, as well as its
+// modules, which are not called.
+var builtinArity = map[string]int{
+	"len":            1,
+	"first":          1,
+	"last":           1,
+	"rest":           1,
+	"push":           2,
+	"puts":           -1,
+	"print":          -1,
+	"eprint":         -1,
+	"read_line":      0,
+	"input":          -1,
+	"assert":         -1,
+	"assert_eq":      2,
+	"assert_error":   -1,
+	"help":           1,
+	"json_parse":     1,
+	"json_stringify": -1,
+	"regex":          1,
+	"match":          2,
+	"find_all":       -1,
+	"captures":       2,
+	"replace":        3,
+	"split":          -1,
+	"format":         -1,
+	"printf":         -1,
+	"now":            0,
+	"parse_time":     -1,
+	"format_time":    -1,
+	"in_zone":        2,
+	"time_fields":    1,
+	"unix":           1,
+	"from_unix":      1,
+	"duration":       1,
+	"channel":        -1,
+	"send":           2,
+	"recv":           1,
+	"close":          1,
+	"iter":           1,
+	"next":           1,
+	"collect":        1,
+	"map":            2,
+	"filter":         2,
+	"take":           2,
+	"range":          -1,
+	"lines":          0,
+	"fs":             -1,
+	"math":           -1,
+}
+
+// Diagnostic is a single problem found in a program.
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.File, d.Line, d.Column, d.Message, d.Rule)
+}
+
+type binding struct {
+	name  *ast.Identifier
+	value ast.Expression // nil for function parameters
+	used  bool
+}
+
+type reference struct {
+	ident  *ast.Identifier
+	nested bool // made from a function literal nested in the scope
+}
+
+// scope mirrors an object.Environment: the program, every function call and
+// every match arm get their own, while blocks share the scope they appear in.
+type scope struct {
+	outer      *scope
+	bindings   map[string]*binding
+	declared   []*binding
+	unresolved []reference
+}
+
+type linter struct {
+	scope       *scope
+	diagnostics []Diagnostic
+}
+
+// Lint checks a parsed program and returns its diagnostics sorted by position.
+func Lint(program *ast.Program) []Diagnostic {
+	l := &linter{}
+	l.openScope()
+	l.statements(program.Statements)
+	l.closeScope()
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.diagnostics
+}
+
+func (l *linter) report(tok token.Token, rule string, format string, a ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (l *linter) openScope() {
+	l.scope = &scope{outer: l.scope, bindings: make(map[string]*binding)}
+}
+
+// closeScope settles the references that could not be resolved when they
+// were made: a name bound later in the same scope is fine when referenced from
+// a nested function, which cannot run before the binding exists, and an error
+// otherwise.
+func (l *linter) closeScope() {
+	s := l.scope
+	l.scope = s.outer
+
+	for _, ref := range s.unresolved {
+		if b, ok := s.bindings[ref.ident.Value]; ok {
+			if !ref.nested {
+				l.report(ref.ident.Token, USE_BEFORE_DEFINITION, "%s used before definition", ref.ident.Value)
+			}
+			b.used = true
+		} else if s.outer != nil {
+			s.outer.unresolved = append(s.outer.unresolved, reference{ident: ref.ident, nested: true})
+		} else {
+			l.report(ref.ident.Token, UNDEFINED_IDENTIFIER, "identifier not found: %s", ref.ident.Value)
+		}
+	}
+
+	for _, b := range s.declared {
+		if b.used || b.value == nil || strings.HasPrefix(b.name.Value, "_") {
+			continue
+		}
+		// Top-level functions and macros are the API of a file, not dead code.
+		if isCallable(b.value) && s.outer == nil {
+			continue
+		}
+		l.report(b.name.Token, UNUSED_VARIABLE, "%s declared but not used", b.name.Value)
+	}
+}
+
+func (l *linter) declare(name *ast.Identifier, value ast.Expression) {
+	if _, ok := builtinArity[name.Value]; ok {
+		l.report(name.Token, SHADOWED_BUILTIN, "%s shadows a builtin", name.Value)
+	}
+	b := &binding{name: name, value: value}
+	l.scope.bindings[name.Value] = b
+	l.scope.declared = append(l.scope.declared, b)
+}
+
+func (l *linter) lookup(name string) (*binding, bool) {
+	for s := l.scope; s != nil; s = s.outer {
+		if b, ok := s.bindings[name]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+func (l *linter) resolve(ident *ast.Identifier) {
+	if b, ok := l.lookup(ident.Value); ok {
+		b.used = true
+		return
+	}
+	if _, ok := builtinArity[ident.Value]; ok {
+		return
+	}
+	l.scope.unresolved = append(l.scope.unresolved, reference{ident: ident})
+}
+
+func (l *linter) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		l.statement(stmt)
+		if _, ok := stmt.(*ast.ReturnStatement); ok && i+1 < len(stmts) {
+			l.report(ast.StartToken(stmts[i+1]), UNREACHABLE_CODE, "unreachable code after return")
+			for _, dead := range stmts[i+1:] {
+				l.statement(dead)
+			}
+			return
+		}
+	}
+}
+
+func (l *linter) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		l.expression(stmt.Value)
+		l.declare(stmt.Name, stmt.Value)
+	case *ast.ReturnStatement:
+		l.expression(stmt.ReturnValue)
+	case *ast.YieldStatement:
+		l.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		l.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		l.statements(stmt.Statements)
+	}
+}
+
+func (l *linter) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		l.resolve(exp)
+	case *ast.PrefixExpression:
+		l.expression(exp.Right)
+		l.checkPrefix(exp)
+	case *ast.InfixExpression:
+		l.expression(exp.Left)
+		l.expression(exp.Right)
+		l.checkInfix(exp)
+	case *ast.IfExpression:
+		l.expression(exp.Condition)
+		if exp.Consequence != nil {
+			l.statement(exp.Consequence)
+		}
+		if exp.Alternative != nil {
+			l.statement(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		l.function(exp.Parameters, exp.Body)
+	case *ast.MacroLiteral:
+		l.function(exp.Parameters, exp.Body)
+	case *ast.CallExpression:
+		if exp.Function.TokenLiteral() == "quote" {
+			l.quote(exp)
+			return
+		}
+		l.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			l.expression(arg)
+		}
+		l.checkCall(exp)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			l.expression(el)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			l.expression(part)
+		}
+	case *ast.IndexExpression:
+		l.expression(exp.Left)
+		l.expression(exp.Index)
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys() {
+			l.expression(key)
+			l.expression(exp.Pairs[key])
+		}
+	case *ast.ForExpression:
+		l.expression(exp.Iterable)
+		l.declare(exp.Variable, exp.Iterable)
+		if exp.Body != nil {
+			l.statement(exp.Body)
+		}
+	case *ast.SelectExpression:
+		for _, c := range exp.Cases {
+			l.expression(c.Operation)
+			if c.Name != nil {
+				l.declare(c.Name, c.Operation)
+			}
+			l.statement(c.Body)
+		}
+		if exp.Default != nil {
+			l.statement(exp.Default)
+		}
+	case *ast.MatchExpression:
+		l.expression(exp.Value)
+		for _, arm := range exp.Arms {
+			l.openScope()
+			ast.Inspect(arm.Pattern, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.BindingPattern:
+					l.declareMatched(node.Name, exp.Value)
+				case *ast.ArrayPattern:
+					l.declareMatched(node.Rest, exp.Value)
+				}
+				return true
+			})
+			if arm.Guard != nil {
+				l.expression(arm.Guard)
+			}
+			l.expression(arm.Value)
+			l.closeScope()
+		}
+	}
+}
+
+// declareMatched declares a name bound by a pattern to a part of value, _
+// binding nothing.
+func (l *linter) declareMatched(name *ast.Identifier, value ast.Expression) {
+	if name != nil && name.Value != "_" {
+		l.declare(name, value)
+	}
+}
+
+func (l *linter) function(params []*ast.Identifier, body *ast.BlockStatement) {
+	l.openScope()
+	for _, param := range params {
+		l.declare(param, nil)
+	}
+	if body != nil {
+		l.statement(body)
+	}
+	l.closeScope()
+}
+
+// quote only checks the arguments of the unquote calls in quoted code, the
+// rest being evaluated wherever a macro expands it.
+func (l *linter) quote(call *ast.CallExpression) {
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			unquote, ok := node.(*ast.CallExpression)
+			if !ok || unquote.Function.TokenLiteral() != "unquote" {
+				return true
+			}
+			for _, arg := range unquote.Arguments {
+				l.expression(arg)
+			}
+			return false
+		})
+	}
+}
+
+func (l *linter) checkPrefix(exp *ast.PrefixExpression) {
+	right := literalType(exp.Right)
+	if exp.Operator == "-" && right != "" && right != object.INTEGER_OBJ {
+		l.report(exp.Token, TYPE_MISMATCH, "unknown operator: -%s", right)
+	}
+}
+
+// checkInfix reports the operations on literals that the evaluator rejects.
+func (l *linter) checkInfix(exp *ast.InfixExpression) {
+	left, right := literalType(exp.Left), literalType(exp.Right)
+	if left == "" || right == "" {
+		return
+	}
+	// strings are added but not compared
+	bothStrings := left == object.STRING_OBJ && right == object.STRING_OBJ
+	switch {
+	case left == object.INTEGER_OBJ && right == object.INTEGER_OBJ:
+	case bothStrings && exp.Operator == "+":
+	case !bothStrings && (exp.Operator == "==" || exp.Operator == "!="):
+	case left != right:
+		l.report(exp.Token, TYPE_MISMATCH, "type mismatch: %s %s %s", left, exp.Operator, right)
+	default:
+		l.report(exp.Token, TYPE_MISMATCH, "unknown operator: %s %s %s", left, exp.Operator, right)
+	}
+}
+
+func (l *linter) checkCall(call *ast.CallExpression) {
+	callee := call.Function
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		b, ok := l.lookup(ident.Value)
+		if !ok {
+			if arity, ok := builtinArity[ident.Value]; ok && arity >= 0 && arity != len(call.Arguments) {
+				l.report(ident.Token, WRONG_ARGUMENT_COUNT,
+					"wrong number of arguments to %s, got=%d, want=%d", ident.Value, len(call.Arguments), arity)
+			}
+			return
+		}
+		callee = b.value
+	}
+
+	switch callee := callee.(type) {
+	case nil:
+	case *ast.FunctionLiteral:
+		l.checkArgumentCount(call, callee.Parameters)
+	case *ast.MacroLiteral:
+		l.checkArgumentCount(call, callee.Parameters)
+	default:
+		if typ := literalType(callee); typ != "" {
+			l.report(ast.StartToken(call.Function), NOT_A_FUNCTION, "not a function: %s", typ)
+		}
+	}
+}
+
+func (l *linter) checkArgumentCount(call *ast.CallExpression, params []*ast.Identifier) {
+	if len(params) != len(call.Arguments) {
+		l.report(ast.StartToken(call.Function), WRONG_ARGUMENT_COUNT,
+			"wrong number of arguments to %s, got=%d, want=%d", call.Function, len(call.Arguments), len(params))
+	}
+}
+
+func isCallable(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		return true
+	}
+	return false
+}
+
+// literalType returns the type of the object a literal evaluates to, or "" for
+// expressions whose type is only known at runtime.
+func literalType(exp ast.Expression) object.ObjectType {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.BigIntegerLiteral:
+		// BIGINTs are integers to the checks
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral, *ast.InterpolatedString:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+	}
+	return ""
+}
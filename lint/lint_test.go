@@ -0,0 +1,90 @@
+package lint
+
+import (
+	"magot/lexer"
+	"magot/parser"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Diagnostic
+	}{
+		{"let x = 5; x;", nil},
+		{"let f = fn(n) { if (n < 1) { return 0; } f(n - 1) }; f(3);", nil},
+		{"let f = fn() { g() }; let g = fn() { 1 }; f();", nil},
+		{"let x = 5;", []Diagnostic{
+			{Line: 1, Column: 5, Rule: UNUSED_VARIABLE, Message: "x declared but not used"},
+		}},
+		{"let f = fn(x) { let y = x; x };", []Diagnostic{
+			{Line: 1, Column: 21, Rule: UNUSED_VARIABLE, Message: "y declared but not used"},
+		}},
+		{"let len = 1; len;", []Diagnostic{
+			{Line: 1, Column: 5, Rule: SHADOWED_BUILTIN, Message: "len shadows a builtin"},
+		}},
+		{"x; let x = 1;", []Diagnostic{
+			{Line: 1, Column: 1, Rule: USE_BEFORE_DEFINITION, Message: "x used before definition"},
+		}},
+		{"foobar;", []Diagnostic{
+			{Line: 1, Column: 1, Rule: UNDEFINED_IDENTIFIER, Message: "identifier not found: foobar"},
+		}},
+		{"fn() { return 1;\n 2; }();", []Diagnostic{
+			{Line: 2, Column: 2, Rule: UNREACHABLE_CODE, Message: "unreachable code after return"},
+		}},
+		{"let add = fn(a, b) { a + b }; add(1);", []Diagnostic{
+			{Line: 1, Column: 31, Rule: WRONG_ARGUMENT_COUNT, Message: "wrong number of arguments to add, got=1, want=2"},
+		}},
+		{`len("a", "b");`, []Diagnostic{
+			{Line: 1, Column: 1, Rule: WRONG_ARGUMENT_COUNT, Message: "wrong number of arguments to len, got=2, want=1"},
+		}},
+		{"let x = 1; x();", []Diagnostic{
+			{Line: 1, Column: 12, Rule: NOT_A_FUNCTION, Message: "not a function: INTEGER"},
+		}},
+		{`"a" - 1;`, []Diagnostic{
+			{Line: 1, Column: 5, Rule: TYPE_MISMATCH, Message: "type mismatch: STRING - INTEGER"},
+		}},
+		{`"a" - "b";`, []Diagnostic{
+			{Line: 1, Column: 5, Rule: TYPE_MISMATCH, Message: "unknown operator: STRING - STRING"},
+		}},
+		{`"a" == "b"; "a" != "b"; "a" == 1; true != false;`, []Diagnostic{
+			{Line: 1, Column: 5, Rule: TYPE_MISMATCH, Message: "unknown operator: STRING == STRING"},
+			{Line: 1, Column: 17, Rule: TYPE_MISMATCH, Message: "unknown operator: STRING != STRING"},
+		}},
+		{`-true;`, []Diagnostic{
+			{Line: 1, Column: 1, Rule: TYPE_MISMATCH, Message: "unknown operator: -BOOLEAN"},
+		}},
+		{"for (x in [1]) { puts(1) } for (_y in [2]) { yield 1; }", []Diagnostic{
+			{Line: 1, Column: 6, Rule: UNUSED_VARIABLE, Message: "x declared but not used"},
+		}},
+		{`match ([1, 2]) { [x, ...more] if x > 0 => x, {"a": n, "b": _} => 0, _ => y }`, []Diagnostic{
+			{Line: 1, Column: 25, Rule: UNUSED_VARIABLE, Message: "more declared but not used"},
+			{Line: 1, Column: 52, Rule: UNUSED_VARIABLE, Message: "n declared but not used"},
+			{Line: 1, Column: 74, Rule: UNDEFINED_IDENTIFIER, Message: "identifier not found: y"},
+		}},
+		{"let n = 1; match (2) { n => n }; puts(n); match (1) { x => x }; x", []Diagnostic{
+			{Line: 1, Column: 65, Rule: UNDEFINED_IDENTIFIER, Message: "identifier not found: x"},
+		}},
+		{"let c = channel(); select { let v = recv(c) { 1 } send(c, 2) { 2 } };", []Diagnostic{
+			{Line: 1, Column: 33, Rule: UNUSED_VARIABLE, Message: "v declared but not used"},
+		}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parse errors for %q: %v", tt.input, p.Errors())
+		}
+		diagnostics := Lint(program)
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("wrong diagnostics for %q. expected=%v, got=%v", tt.input, tt.expected, diagnostics)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if diagnostics[i] != expected {
+				t.Errorf("wrong diagnostic for %q. expected=%+v, got=%+v", tt.input, expected, diagnostics[i])
+			}
+		}
+	}
+}
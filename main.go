@@ -5,13 +5,50 @@ import (
 	"magot/repl"
 	"os"
 	"os/user"
+	"sort"
+	_ "time/tzdata" // for in_zone on the systems lacking a time zone database
 )
 
+// commands maps the subcommands of the magot binary to their entry points,
+// which return the process exit code.
+var commands = map[string]func(args []string) int{
+	"debug": debugCommand,
+	"doc":   docCommand,
+	"lint":  lintCommand,
+	"parse": parseCommand,
+	"run":   runCommand,
+	"test":  testCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			usage()
+			os.Exit(2)
+		}
+		os.Exit(command(os.Args[2:]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Hello %s! This is the Magot programming language!\n", user.Username)
+	fmt.Println("Type :help for the list of commands.")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func usage() {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "usage: magot [command] [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "Without a command, magot starts an interactive session.\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%s\n", name)
+	}
+}
@@ -1,8 +1,19 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
+// Environment binds names to values. Its bindings are locked, as closures
+// share the environment they are defined in with the functions run
+// concurrently by spawn. A global environment may also hold the context of
+// the evaluations in it, which the environments enclosing it share.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu      sync.RWMutex
+	store   map[string]Object
+	outer   *Environment
+	context interface{}
 }
 
 func NewEnvironment() *Environment {
@@ -17,7 +28,9 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -25,6 +38,68 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, obj Object) Object {
+	e.mu.Lock()
 	e.store[name] = obj
+	e.mu.Unlock()
 	return obj
 }
+
+// bindings returns a copy of the names bound in the environment itself.
+func (e *Environment) bindings() map[string]Object {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	store := make(map[string]Object, len(e.store))
+	for name, obj := range e.store {
+		store[name] = obj
+	}
+	return store
+}
+
+// SetContext sets the context of the evaluations in e and in the
+// environments enclosing it. It is set before evaluating in e.
+func (e *Environment) SetContext(context interface{}) {
+	e.context = context
+}
+
+// Context returns the context of the evaluations in e, set on e or on the
+// nearest environment e encloses, nil if there is none.
+func (e *Environment) Context() interface{} {
+	for env := e; env != nil; env = env.outer {
+		if env.context != nil {
+			return env.context
+		}
+	}
+	return nil
+}
+
+// Outer returns the environment e encloses, nil for a global one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// LocalNames returns the sorted names bound in the environment itself.
+func (e *Environment) LocalNames() []string {
+	names := []string{}
+	for name := range e.bindings() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Names returns the sorted names bound in the environment and the ones it
+// encloses.
+func (e *Environment) Names() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.bindings() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
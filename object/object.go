@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"hash/fnv"
 	"magot/ast"
+	"math/big"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -21,6 +26,14 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	REGEX_OBJ        = "REGEX"
+	TIME_OBJ         = "TIME"
+	DURATION_OBJ     = "DURATION"
+	FUTURE_OBJ       = "FUTURE"
+	CHANNEL_OBJ      = "CHANNEL"
+	ITERATOR_OBJ     = "ITERATOR"
 )
 
 type Object interface {
@@ -28,6 +41,14 @@ type Object interface {
 	Inspect() string
 }
 
+// The values are shared by every evaluation, booleans and null being compared
+// by identity.
+var (
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+	NULL  = &Null{}
+)
+
 type HashPair struct {
 	Key   Object
 	Value Object
@@ -74,10 +95,15 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction is the Go function of a builtin, called with the
+// environment of the call.
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Name  string
+	Usage string // the call syntax, such as "len(value)"
+	Doc   string
+	Fn    BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -120,6 +146,31 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// BigInt is an integer out of the range of Integer, which the arithmetic
+// operations overflowing it return. The integers within the range are always
+// Integers.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (i *BigInt) Inspect() string {
+	return i.Value.String()
+}
+
+func (i *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
+func (i *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(i.Value.Bytes())
+	value := h.Sum64()
+	if i.Value.Sign() < 0 {
+		value = ^value
+	}
+	return HashKey{Type: i.Type(), Value: value}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -155,6 +206,10 @@ func (n *Null) Type() ObjectType {
 
 type Error struct {
 	Message string
+	// Line and Column locate the expression that failed, both being 0 until
+	// the evaluator sets them.
+	Line   int
+	Column int
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -165,6 +220,7 @@ type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Doc        string // the doc of the let statement binding the literal, if any
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -186,7 +242,144 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
 }
+
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) Inspect() string  { return `regex("` + r.Regexp.String() + `")` }
+
+// Time is an instant along with the time zone it is shown in.
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType { return TIME_OBJ }
+func (t *Time) Inspect() string  { return t.Value.Format(time.RFC3339Nano) }
+
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() ObjectType { return DURATION_OBJ }
+func (d *Duration) Inspect() string  { return d.Value.String() }
+
+// Future is the value a function run concurrently by spawn returns, once
+// Resolve gives it.
+type Future struct {
+	done   chan struct{}
+	result Object
+}
+
+func NewFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Resolve sets the value of the future and wakes the ones awaiting it. It is
+// called once.
+func (f *Future) Resolve(result Object) {
+	f.result = result
+	close(f.done)
+}
+
+// Await blocks until the future is resolved and returns its value.
+func (f *Future) Await() Object {
+	<-f.done
+	return f.result
+}
+
+// Done returns a channel closed once the future is resolved.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *Future) Type() ObjectType { return FUTURE_OBJ }
+
+func (f *Future) Inspect() string {
+	select {
+	case <-f.done:
+		return "future(" + f.result.Inspect() + ")"
+	default:
+		return "future(pending)"
+	}
+}
+
+// Channel passes values between functions run concurrently by spawn.
+type Channel struct {
+	Values chan Object
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("channel(%d/%d)", len(c.Values), cap(c.Values))
+}
+
+// Iterator produces the values of a sequence one at a time, as they are
+// asked for, which lets generators and the lazy builtins process sequences
+// too long to be held in an array.
+type Iterator struct {
+	mu   sync.Mutex
+	next func() (Object, bool)
+	done bool
+}
+
+// NewIterator returns an iterator over the values next returns until it
+// returns false.
+func NewIterator(next func() (Object, bool)) *Iterator {
+	return &Iterator{next: next}
+}
+
+// Next returns the next value of the iterator, or false once it is exhausted.
+// An error ends the iterator after it is returned.
+func (it *Iterator) Next() (Object, bool) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.done {
+		return nil, false
+	}
+	value, ok := it.next()
+	if !ok || value.Type() == ERROR_OBJ {
+		it.done = true
+	}
+	return value, ok
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
@@ -1,6 +1,13 @@
 package object
 
-import "testing"
+import (
+	"magot/ast"
+	"math/big"
+	"regexp"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
 
 func TestStringHasKey(t *testing.T) {
 	hello1 := &String{Value: "Hello world"}
@@ -18,3 +25,149 @@ func TestStringHasKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("a", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", &Integer{Value: 3})
+	inner.Set("a", &Integer{Value: 4})
+
+	names := inner.Names()
+	expected := []string{"a", "b", "c"}
+	if len(names) != len(expected) {
+		t.Fatalf("wrong names. expected=%v, got=%v", expected, names)
+	}
+	for i, name := range expected {
+		if names[i] != name {
+			t.Errorf("wrong names. expected=%v, got=%v", expected, names)
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	body := &ast.BlockStatement{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "x"}},
+	}}
+	params := []*ast.Identifier{{Value: "x"}}
+	shared := &Array{Elements: []Object{&Integer{Value: 1}, TRUE}}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "k"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: shared}
+
+	env := NewEnvironment()
+	enclosed := NewEnclosedEnvironment(env)
+	enclosed.Set("captured", &String{Value: "c"})
+	env.Set("i", &Integer{Value: -7})
+	env.Set("b", FALSE)
+	env.Set("n", NULL)
+	env.Set("shared", shared)
+	env.Set("hash", hash)
+	env.Set("recursive", &Function{Parameters: params, Body: body, Env: env})
+	env.Set("first", &Function{Parameters: params, Body: body, Env: enclosed})
+	env.Set("second", &Function{Parameters: params, Body: body, Env: enclosed})
+	env.Set("macro", &Macro{Parameters: params, Body: body, Env: env})
+	env.Set("builtin", &Builtin{Name: "len"})
+	env.Set("quote", &Quote{Node: &ast.Identifier{Value: "q"}})
+	env.Set("regex", &Regex{Regexp: regexp.MustCompile(`\d+`)})
+	big, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	env.Set("big", &BigInt{Value: big})
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Set("time", &Time{Value: time.Date(2024, time.March, 10, 8, 15, 0, 5, paris)})
+	env.Set("duration", &Duration{Value: 90 * time.Minute})
+	env.Set("documented", &Function{Parameters: params, Body: body, Env: env, Doc: "Returns x."})
+
+	data, err := Snapshot(env)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %s", err)
+	}
+	lenBuiltin := &Builtin{Name: "len"}
+	restored, err := Restore(data, func(name string) (*Builtin, bool) {
+		return lenBuiltin, name == "len"
+	})
+	if err != nil {
+		t.Fatalf("Restore failed: %s", err)
+	}
+
+	get := func(name string) Object {
+		obj, ok := restored.Get(name)
+		if !ok {
+			t.Fatalf("%s not restored", name)
+		}
+		return obj
+	}
+	if i := get("i").(*Integer); i.Value != -7 {
+		t.Errorf("i restored as %d", i.Value)
+	}
+	if get("b") != FALSE || get("n") != NULL {
+		t.Errorf("booleans and null not restored as the shared values")
+	}
+	array := get("shared").(*Array)
+	if array.Inspect() != "[1, true]" || array.Elements[1] != TRUE {
+		t.Errorf("array restored as %s", array.Inspect())
+	}
+	restoredHash := get("hash").(*Hash)
+	if pair := restoredHash.Pairs[key.HashKey()]; pair.Value != array {
+		t.Errorf("array shared by the hash not restored shared")
+	}
+	if fn := get("recursive").(*Function); fn.Env != restored || fn.Body.String() != "x" || fn.Parameters[0].Value != "x" {
+		t.Errorf("recursive function not restored in its environment")
+	}
+	first, second := get("first").(*Function), get("second").(*Function)
+	if first.Env != second.Env || first.Env == restored {
+		t.Errorf("closure environment not restored shared")
+	}
+	if captured, ok := first.Env.Get("captured"); !ok || captured.Inspect() != "c" {
+		t.Errorf("captured variable not restored")
+	}
+	if _, ok := first.Env.Get("i"); !ok {
+		t.Errorf("closure environment not restored in its outer environment")
+	}
+	if macro := get("macro").(*Macro); macro.Env != restored {
+		t.Errorf("macro not restored in its environment")
+	}
+	if get("builtin") != lenBuiltin {
+		t.Errorf("builtin not looked up by name")
+	}
+	if quote := get("quote").(*Quote); quote.Node.String() != "q" {
+		t.Errorf("quote restored as %s", quote.Inspect())
+	}
+	if big := get("big").(*BigInt); big.Inspect() != "-123456789012345678901234567890" {
+		t.Errorf("big integer restored as %s", big.Inspect())
+	}
+	if tm := get("time").(*Time); tm.Inspect() != "2024-03-10T08:15:00.000000005+01:00" || tm.Value.Location().String() != "Europe/Paris" {
+		t.Errorf("time restored as %s in %s", tm.Inspect(), tm.Value.Location())
+	}
+	if d := get("duration").(*Duration); d.Inspect() != "1h30m0s" {
+		t.Errorf("duration restored as %s", d.Inspect())
+	}
+	if re := get("regex").(*Regex); re.Inspect() != `regex("\d+")` {
+		t.Errorf("regex restored as %s", re.Inspect())
+	}
+	if fn := get("documented").(*Function); fn.Doc != "Returns x." {
+		t.Errorf("function doc restored as %q", fn.Doc)
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	env := NewEnvironment()
+	env.Set("err", &Error{Message: "boom"})
+	if _, err := Snapshot(env); err == nil || err.Error() != "err: cannot snapshot ERROR values" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	env = NewEnvironment()
+	env.Set("f", &Builtin{Name: "gone"})
+	data, err := Snapshot(env)
+	if err != nil {
+		t.Fatalf("Snapshot failed: %s", err)
+	}
+	_, err = Restore(data, func(string) (*Builtin, bool) { return nil, false })
+	if err == nil || err.Error() != `unknown builtin "gone"` {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
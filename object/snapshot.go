@@ -0,0 +1,353 @@
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"magot/ast"
+	"math/big"
+	"regexp"
+	"time"
+)
+
+// A snapshot lists the environments and the objects reachable from an
+// environment, each of them once, and refers to them by their index in those
+// lists. Objects and environments shared by several closures are thus
+// restored shared, and the cycles formed by recursive closures, whose
+// environment holds the closure itself, are preserved. Functions and macros
+// are stored as the JSON encoding of their parameters and body.
+
+type snapshot struct {
+	Root         int                   `json:"root"`
+	Environments []snapshotEnvironment `json:"environments"`
+	Objects      []snapshotObject      `json:"objects"`
+}
+
+type snapshotEnvironment struct {
+	Outer int            `json:"outer"` // -1 when there is none
+	Store map[string]int `json:"store"`
+}
+
+type snapshotObject struct {
+	Type       ObjectType        `json:"type"`
+	Integer    int64             `json:"integer,omitempty"`
+	String     string            `json:"string,omitempty"`
+	Boolean    bool              `json:"boolean,omitempty"`
+	Elements   []int             `json:"elements,omitempty"`
+	Pairs      [][2]int          `json:"pairs,omitempty"`
+	Parameters []json.RawMessage `json:"parameters,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+	Node       json.RawMessage   `json:"node,omitempty"`
+	Env        int               `json:"env,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Doc        string            `json:"doc,omitempty"`
+}
+
+// Snapshot returns an encoding of env, the environments it encloses and the
+// values bound in them, which Restore turns back into an environment.
+func Snapshot(env *Environment) ([]byte, error) {
+	w := &snapshotWriter{
+		environments: make(map[*Environment]int),
+		objects:      make(map[Object]int),
+	}
+	root, err := w.environment(env)
+	if err != nil {
+		return nil, err
+	}
+	w.snapshot.Root = root
+	return json.Marshal(w.snapshot)
+}
+
+type snapshotWriter struct {
+	snapshot     snapshot
+	environments map[*Environment]int
+	objects      map[Object]int
+}
+
+// environment adds env to the snapshot before the objects bound in it, so
+// that the closures it holds refer back to it.
+func (w *snapshotWriter) environment(env *Environment) (int, error) {
+	if index, ok := w.environments[env]; ok {
+		return index, nil
+	}
+	index := len(w.snapshot.Environments)
+	w.environments[env] = index
+	w.snapshot.Environments = append(w.snapshot.Environments, snapshotEnvironment{Outer: -1, Store: map[string]int{}})
+
+	if env.outer != nil {
+		outer, err := w.environment(env.outer)
+		if err != nil {
+			return 0, err
+		}
+		w.snapshot.Environments[index].Outer = outer
+	}
+	for name, obj := range env.bindings() {
+		ref, err := w.object(obj)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s", name, err)
+		}
+		w.snapshot.Environments[index].Store[name] = ref
+	}
+	return index, nil
+}
+
+func (w *snapshotWriter) object(obj Object) (int, error) {
+	if index, ok := w.objects[obj]; ok {
+		return index, nil
+	}
+	index := len(w.snapshot.Objects)
+	w.objects[obj] = index
+	w.snapshot.Objects = append(w.snapshot.Objects, snapshotObject{Type: obj.Type()})
+
+	encoded := snapshotObject{Type: obj.Type()}
+	var err error
+	switch obj := obj.(type) {
+	case *Integer:
+		encoded.Integer = obj.Value
+	case *BigInt:
+		encoded.String = obj.Value.String()
+	case *String:
+		encoded.String = obj.Value
+	case *Boolean:
+		encoded.Boolean = obj.Value
+	case *Null:
+	case *Array:
+		encoded.Elements = []int{}
+		for _, element := range obj.Elements {
+			ref, err := w.object(element)
+			if err != nil {
+				return 0, err
+			}
+			encoded.Elements = append(encoded.Elements, ref)
+		}
+	case *Hash:
+		encoded.Pairs = [][2]int{}
+		for _, pair := range obj.Pairs {
+			key, err := w.object(pair.Key)
+			if err != nil {
+				return 0, err
+			}
+			value, err := w.object(pair.Value)
+			if err != nil {
+				return 0, err
+			}
+			encoded.Pairs = append(encoded.Pairs, [2]int{key, value})
+		}
+	case *Function:
+		encoded.Parameters, encoded.Body, encoded.Env, err = w.closure(obj.Parameters, obj.Body, obj.Env)
+		encoded.Doc = obj.Doc
+	case *Macro:
+		encoded.Parameters, encoded.Body, encoded.Env, err = w.closure(obj.Parameters, obj.Body, obj.Env)
+	case *Builtin:
+		encoded.Name = obj.Name
+	case *Quote:
+		encoded.Node, err = ast.Encode(obj.Node)
+	case *Regex:
+		encoded.String = obj.Regexp.String()
+	case *Time:
+		encoded.String = obj.Value.Format(time.RFC3339Nano)
+		encoded.Name = obj.Value.Location().String()
+	case *Duration:
+		encoded.Integer = int64(obj.Value)
+	default:
+		return 0, fmt.Errorf("cannot snapshot %s values", obj.Type())
+	}
+	if err != nil {
+		return 0, err
+	}
+	w.snapshot.Objects[index] = encoded
+	return index, nil
+}
+
+func (w *snapshotWriter) closure(params []*ast.Identifier, body *ast.BlockStatement, env *Environment) ([]json.RawMessage, json.RawMessage, int, error) {
+	encodedParams := []json.RawMessage{}
+	for _, param := range params {
+		encoded, err := ast.Encode(param)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		encodedParams = append(encodedParams, encoded)
+	}
+	encodedBody, err := ast.Encode(body)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	index, err := w.environment(env)
+	return encodedParams, encodedBody, index, err
+}
+
+// Restore returns the environment encoded in data by Snapshot, looking the
+// builtin functions up by name with builtin.
+func Restore(data []byte, builtin func(name string) (*Builtin, bool)) (*Environment, error) {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	envs := make([]*Environment, len(s.Environments))
+	for i := range envs {
+		envs[i] = NewEnvironment()
+	}
+	env := func(index int) (*Environment, error) {
+		if index < 0 || index >= len(envs) {
+			return nil, fmt.Errorf("invalid environment reference %d", index)
+		}
+		return envs[index], nil
+	}
+
+	// The objects are allocated before being filled in, as they can refer to
+	// the ones that come after them.
+	objects := make([]Object, len(s.Objects))
+	for i, encoded := range s.Objects {
+		obj, err := restoreObject(encoded, env, builtin)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = obj
+	}
+	object := func(index int) (Object, error) {
+		if index < 0 || index >= len(objects) {
+			return nil, fmt.Errorf("invalid object reference %d", index)
+		}
+		return objects[index], nil
+	}
+
+	for i, encoded := range s.Objects {
+		switch obj := objects[i].(type) {
+		case *Array:
+			for _, ref := range encoded.Elements {
+				element, err := object(ref)
+				if err != nil {
+					return nil, err
+				}
+				obj.Elements = append(obj.Elements, element)
+			}
+		case *Hash:
+			for _, pair := range encoded.Pairs {
+				key, err := object(pair[0])
+				if err != nil {
+					return nil, err
+				}
+				value, err := object(pair[1])
+				if err != nil {
+					return nil, err
+				}
+				hashable, ok := key.(Hashable)
+				if !ok {
+					return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+				}
+				obj.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+			}
+		}
+	}
+
+	for i, encoded := range s.Environments {
+		if encoded.Outer >= 0 {
+			outer, err := env(encoded.Outer)
+			if err != nil {
+				return nil, err
+			}
+			envs[i].outer = outer
+		}
+		for name, ref := range encoded.Store {
+			obj, err := object(ref)
+			if err != nil {
+				return nil, err
+			}
+			envs[i].store[name] = obj
+		}
+	}
+	return env(s.Root)
+}
+
+func restoreObject(encoded snapshotObject, env func(int) (*Environment, error), builtin func(string) (*Builtin, bool)) (Object, error) {
+	switch encoded.Type {
+	case INTEGER_OBJ:
+		return &Integer{Value: encoded.Integer}, nil
+	case BIGINT_OBJ:
+		value, ok := new(big.Int).SetString(encoded.String, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid BIGINT %q", encoded.String)
+		}
+		return &BigInt{Value: value}, nil
+	case STRING_OBJ:
+		return &String{Value: encoded.String}, nil
+	case BOOLEAN_OBJ:
+		if encoded.Boolean {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case NULL_OBJ:
+		return NULL, nil
+	case ARRAY_OBJ:
+		return &Array{Elements: []Object{}}, nil
+	case HASH_OBJ:
+		return &Hash{Pairs: make(map[HashKey]HashPair)}, nil
+	case FUNCTION_OBJ:
+		params, body, closureEnv, err := restoreClosure(encoded, env)
+		if err != nil {
+			return nil, err
+		}
+		return &Function{Parameters: params, Body: body, Env: closureEnv, Doc: encoded.Doc}, nil
+	case MACRO_OBJ:
+		params, body, closureEnv, err := restoreClosure(encoded, env)
+		if err != nil {
+			return nil, err
+		}
+		return &Macro{Parameters: params, Body: body, Env: closureEnv}, nil
+	case BUILTIN_OBJ:
+		if obj, ok := builtin(encoded.Name); ok {
+			return obj, nil
+		}
+		return nil, fmt.Errorf("unknown builtin %q", encoded.Name)
+	case REGEX_OBJ:
+		re, err := regexp.Compile(encoded.String)
+		if err != nil {
+			return nil, err
+		}
+		return &Regex{Regexp: re}, nil
+	case TIME_OBJ:
+		t, err := time.Parse(time.RFC3339Nano, encoded.String)
+		if err != nil {
+			return nil, err
+		}
+		// the zones unknown where the snapshot is restored keep their offset
+		if loc, err := time.LoadLocation(encoded.Name); err == nil {
+			t = t.In(loc)
+		}
+		return &Time{Value: t}, nil
+	case DURATION_OBJ:
+		return &Duration{Value: time.Duration(encoded.Integer)}, nil
+	case QUOTE_OBJ:
+		node, err := ast.Decode(encoded.Node)
+		if err != nil {
+			return nil, err
+		}
+		return &Quote{Node: node}, nil
+	}
+	return nil, fmt.Errorf("cannot restore %s values", encoded.Type)
+}
+
+func restoreClosure(encoded snapshotObject, env func(int) (*Environment, error)) ([]*ast.Identifier, *ast.BlockStatement, *Environment, error) {
+	params := []*ast.Identifier{}
+	for _, data := range encoded.Parameters {
+		node, err := ast.Decode(data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		param, ok := node.(*ast.Identifier)
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("expected an Identifier parameter, got %T", node)
+		}
+		params = append(params, param)
+	}
+	node, err := ast.Decode(encoded.Body)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	body, ok := node.(*ast.BlockStatement)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("expected a BlockStatement body, got %T", node)
+	}
+	closureEnv, err := env(encoded.Env)
+	return params, body, closureEnv, err
+}
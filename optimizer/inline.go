@@ -0,0 +1,89 @@
+package optimizer
+
+import "magot/ast"
+
+// inline replaces the references to let bindings of literals by the literals
+// themselves. As the evaluator resolves names at runtime, a binding is only
+// inlined when its name is bound nowhere else in the program, and only in the
+// code that follows it in the program or function body it belongs to, where
+// it is sure to be defined.
+func (o *optimizer) inline(program *ast.Program) {
+	bound := make(map[string]int)
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			bound[node.Name.Value]++
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				bound[param.Value]++
+			}
+		case *ast.MacroLiteral:
+			for _, param := range node.Parameters {
+				bound[param.Value]++
+			}
+		case *ast.ForExpression:
+			bound[node.Variable.Value]++
+		case *ast.SelectExpression:
+			for _, c := range node.Cases {
+				if c.Name != nil {
+					bound[c.Name.Value]++
+				}
+			}
+		case *ast.BindingPattern:
+			bound[node.Name.Value]++
+		case *ast.ArrayPattern:
+			if node.Rest != nil {
+				bound[node.Rest.Value]++
+			}
+		}
+		return true
+	})
+
+	o.inlineBody(program.Statements, bound)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if fn, ok := node.(*ast.FunctionLiteral); ok && fn.Body != nil {
+			o.inlineBody(fn.Body.Statements, bound)
+		}
+		return true
+	})
+}
+
+func (o *optimizer) inlineBody(stmts []ast.Statement, bound map[string]int) {
+	for i, stmt := range stmts {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || bound[let.Name.Value] != 1 || o.quoted[let] || !isLiteral(let.Value) {
+			continue
+		}
+		for j := i + 1; j < len(stmts); j++ {
+			stmts[j], _ = ast.Modify(stmts[j], func(node ast.Node) ast.Node {
+				ident, ok := node.(*ast.Identifier)
+				if !ok || ident.Value != let.Name.Value || o.quoted[ident] {
+					return node
+				}
+				o.changed = true
+				return withPosition(let.Value, ident)
+			}).(ast.Statement)
+		}
+	}
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// withPosition copies a literal to the position of the identifier it replaces.
+func withPosition(literal ast.Expression, ident *ast.Identifier) ast.Expression {
+	switch literal := literal.(type) {
+	case *ast.IntegerLiteral:
+		return newInteger(ident.Token, literal.Value)
+	case *ast.StringLiteral:
+		return newString(ident.Token, literal.Value)
+	case *ast.Boolean:
+		return newBoolean(ident.Token, literal.Value)
+	}
+	return literal
+}
@@ -0,0 +1,264 @@
+package optimizer
+
+import (
+	"fmt"
+	"magot/ast"
+	"magot/token"
+	"math"
+	"math/big"
+)
+
+// maxPasses bounds the folding and inlining rounds, each of which can expose
+// new constants to the other.
+const maxPasses = 8
+
+// Optimize folds the constant expressions of a program, removes the branches
+// of if expressions whose condition is constant and inlines the let bindings
+// of literals. The program is rewritten in place and evaluates to the same
+// values and runtime errors as before. Code inside quote calls is left alone.
+func Optimize(program *ast.Program) *ast.Program {
+	for pass := 0; pass < maxPasses; pass++ {
+		o := &optimizer{quoted: quotedNodes(program)}
+		ast.Modify(program, o.fold)
+		o.inline(program)
+		if !o.changed {
+			break
+		}
+	}
+	return program
+}
+
+type optimizer struct {
+	quoted  map[ast.Node]bool
+	changed bool
+}
+
+func quotedNodes(program *ast.Program) map[ast.Node]bool {
+	quoted := make(map[ast.Node]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || call.Function == nil || call.Function.TokenLiteral() != "quote" {
+			return true
+		}
+		for _, arg := range call.Arguments {
+			ast.Inspect(arg, func(node ast.Node) bool {
+				if node != nil {
+					quoted[node] = true
+				}
+				return true
+			})
+		}
+		return false
+	})
+	return quoted
+}
+
+func (o *optimizer) fold(node ast.Node) ast.Node {
+	if o.quoted[node] {
+		return node
+	}
+
+	var folded ast.Node
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		folded = foldPrefix(node)
+	case *ast.InfixExpression:
+		folded = foldInfix(node)
+	case *ast.IfExpression:
+		folded = foldIf(node)
+	case *ast.Program:
+		node.Statements = o.flatten(node.Statements)
+	case *ast.BlockStatement:
+		node.Statements = o.flatten(node.Statements)
+	}
+	if folded == nil {
+		return node
+	}
+	o.changed = true
+	return folded
+}
+
+func foldPrefix(node *ast.PrefixExpression) ast.Expression {
+	start := ast.StartToken(node)
+	switch node.Operator {
+	case "-":
+		if right, ok := node.Right.(*ast.IntegerLiteral); ok && right.Value != math.MinInt64 {
+			return newInteger(start, -right.Value)
+		}
+	case "!":
+		if right, ok := node.Right.(*ast.Boolean); ok {
+			return newBoolean(start, !right.Value)
+		}
+		if _, ok := truthiness(node.Right); ok {
+			return newBoolean(start, false)
+		}
+	}
+	return nil
+}
+
+// foldInfix only folds the operations that succeed at runtime, leaving the
+// ones that produce errors, such as divisions by zero, and the ones that
+// overflow to the evaluator.
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	start := ast.StartToken(node)
+	switch left := node.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := node.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		leftVal, rightVal := big.NewInt(left.Value), big.NewInt(right.Value)
+		switch node.Operator {
+		case "+":
+			return foldInteger(start, leftVal.Add(leftVal, rightVal))
+		case "-":
+			return foldInteger(start, leftVal.Sub(leftVal, rightVal))
+		case "*":
+			return foldInteger(start, leftVal.Mul(leftVal, rightVal))
+		case "/":
+			if right.Value != 0 {
+				return foldInteger(start, leftVal.Quo(leftVal, rightVal))
+			}
+		case "%":
+			if right.Value != 0 {
+				return foldInteger(start, leftVal.Rem(leftVal, rightVal))
+			}
+		case "<":
+			return newBoolean(start, left.Value < right.Value)
+		case ">":
+			return newBoolean(start, left.Value > right.Value)
+		case "==":
+			return newBoolean(start, left.Value == right.Value)
+		case "!=":
+			return newBoolean(start, left.Value != right.Value)
+		}
+	case *ast.StringLiteral:
+		right, ok := node.Right.(*ast.StringLiteral)
+		if ok && node.Operator == "+" {
+			return newString(start, left.Value+right.Value)
+		}
+	case *ast.Boolean:
+		right, ok := node.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+		switch node.Operator {
+		case "==":
+			return newBoolean(start, left.Value == right.Value)
+		case "!=":
+			return newBoolean(start, left.Value != right.Value)
+		}
+	}
+	return nil
+}
+
+// foldIf drops the branch of an if expression that cannot run. The condition
+// stays as a literal so that the expression keeps evaluating to null when no
+// branch is left.
+func foldIf(node *ast.IfExpression) ast.Expression {
+	truthy, ok := truthiness(node.Condition)
+	if !ok {
+		return nil
+	}
+	if condition, ok := node.Condition.(*ast.Boolean); ok && node.Alternative == nil {
+		if condition.Value || len(node.Consequence.Statements) == 0 {
+			return nil // already folded
+		}
+	}
+
+	start := ast.StartToken(node)
+	folded := &ast.IfExpression{Token: node.Token}
+	switch {
+	case truthy:
+		folded.Condition = newBoolean(start, true)
+		folded.Consequence = node.Consequence
+	case node.Alternative != nil:
+		folded.Condition = newBoolean(start, true)
+		folded.Consequence = node.Alternative
+	default:
+		folded.Condition = newBoolean(start, false)
+		folded.Consequence = &ast.BlockStatement{Token: node.Consequence.Token}
+	}
+	return folded
+}
+
+// flatten splices the statements of a constant true branch into the
+// enclosing statement list, blocks sharing the environment they appear in, and
+// drops the if statements left with nothing to run.
+func (o *optimizer) flatten(stmts []ast.Statement) []ast.Statement {
+	flattened := []ast.Statement{}
+	for i, stmt := range stmts {
+		ie, ok := constantIf(stmt)
+		if !ok || o.quoted[stmt] {
+			flattened = append(flattened, stmt)
+			continue
+		}
+		last := i == len(stmts)-1
+		condition := ie.Condition.(*ast.Boolean).Value
+		switch {
+		case condition && len(ie.Consequence.Statements) > 0:
+			flattened = append(flattened, ie.Consequence.Statements...)
+			o.changed = true
+		case !condition && !last:
+			o.changed = true
+		default:
+			flattened = append(flattened, stmt)
+		}
+	}
+	return flattened
+}
+
+func constantIf(stmt ast.Statement) (*ast.IfExpression, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok || ie.Alternative != nil {
+		return nil, false
+	}
+	if _, ok := ie.Condition.(*ast.Boolean); !ok {
+		return nil, false
+	}
+	return ie, true
+}
+
+// truthiness reports whether a literal is truthy, the second result being
+// false when exp is not a literal.
+func truthiness(exp ast.Expression) (bool, bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.IntegerLiteral, *ast.BigIntegerLiteral, *ast.StringLiteral, *ast.FunctionLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+// foldInteger returns the literal of the result of an integer operation,
+// unless it overflows and is thus a BIGINT at runtime, which is left to the
+// evaluator.
+func foldInteger(at token.Token, value *big.Int) ast.Expression {
+	if !value.IsInt64() {
+		return nil
+	}
+	return newInteger(at, value.Int64())
+}
+
+func newInteger(at token.Token, value int64) *ast.IntegerLiteral {
+	tok := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", value), Line: at.Line, Column: at.Column}
+	return &ast.IntegerLiteral{Token: tok, Value: value}
+}
+
+func newString(at token.Token, value string) *ast.StringLiteral {
+	tok := token.Token{Type: token.STRING, Literal: value, Line: at.Line, Column: at.Column}
+	return &ast.StringLiteral{Token: tok, Value: value}
+}
+
+func newBoolean(at token.Token, value bool) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Line: at.Line, Column: at.Column}
+	if value {
+		tok.Type, tok.Literal = token.TRUE, "true"
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
@@ -0,0 +1,84 @@
+package optimizer
+
+import (
+	"magot/ast"
+	"magot/evaluator"
+	"magot/lexer"
+	"magot/object"
+	"magot/parser"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"60 * 60 * 24", "86400"},
+		{"-(2 + 3) * 2", "-10"},
+		{"1 < 2 == true", "true"},
+		{"!true == !5", "true"},
+		{`"foo" + "bar"`, "foobar"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"1 / 0", "(1 / 0)"},
+		{"7 % 3", "1"},
+		{"1 % 0", "(1 % 0)"},
+		{"9223372036854775807 + 1", "(9223372036854775807 + 1)"},
+		{"-(0 - 9223372036854775807 - 1)", "(--9223372036854775808)"},
+		{`"a" - 1`, `(a - 1)`},
+		{"if (1 > 2) { 10 } else { 20 }", "20"},
+		{"if (true) { 10 } else { 20 }", "10"},
+		{"if (1 > 2) { 10 }; 5", "5"},
+		{"if (1 > 2) { 10 }", "iffalse "},
+		{"if (x) { 10 } else { 20 }", "ifx 10else 20"},
+		{"let a = 60 * 60; let b = a * 24; b", "let a = 3600;let b = 86400;86400"},
+		{"let a = 1; let f = fn() { a }; let a = 2; f()", "let a = 1;let f = fn()a;let a = 2;f()"},
+		{"a; let a = 1; a", "alet a = 1;1"},
+		{"let f = fn(n) { let k = 2; n * k }; f(3)", "let f = fn(n)let k = 2;(n * 2);f(3)"},
+		{"if (true) { let a = 1; } a", "let a = 1;1"},
+		{"let a = 1; select { let a = recv(c) { a } }; a", "let a = 1;select { let a = recv(c) { a } }a"},
+		{"let a = 1; match ([2, 3]) { [a, ...b] => a + b[0] }; a", "let a = 1;match ([2, 3]) { [a, ...b] => (a + (b[0])) }a"},
+		{"quote(1 + 2)", "quote((1 + 2))"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		optimized := Optimize(program)
+		if optimized.String() != tt.expected {
+			t.Errorf("wrong optimization of %q. expected=%q, got=%q", tt.input, tt.expected, optimized.String())
+		}
+	}
+}
+
+func TestOptimizePreservesResults(t *testing.T) {
+	inputs := []string{
+		"let x = 2; let f = fn(y) { if (x > 1) { return y * x; } y }; f(21)",
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(10)",
+		"if (1 > 2) { 10 }",
+		"5; if (true) { }",
+		`"a" - 1`,
+		"-true",
+		"let a = 5; if (a == 5) { let b = a + 1; } b",
+		"[1, 2 * 2, 3][1 + 0]",
+		"let a = 1; let c = channel(1); send(c, 2); select { let a = recv(c) { a } }; a",
+		`{"a" + "b": 1 + 1}["ab"]`,
+		"let a = 1; let b = 2; match ([3, 4]) { [_, ...a] if a[0] > 1 + 1 => a[0] + b, _ => b }; a",
+		"4611686018427387904 * 4 / 8",
+	}
+
+	for _, input := range inputs {
+		expected := eval(parser.New(lexer.New(input)).ParseProgram())
+		optimized := eval(Optimize(parser.New(lexer.New(input)).ParseProgram()))
+		if expected != optimized {
+			t.Errorf("optimization changed the result of %q. expected=%q, got=%q", input, expected, optimized)
+		}
+	}
+}
+
+func eval(program *ast.Program) string {
+	evaluated := evaluator.Eval(program, object.NewEnvironment())
+	if evaluated == nil {
+		return "<nil>"
+	}
+	return evaluated.Inspect()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"magot/ast"
+	"magot/lexer"
+	"magot/parser"
+	"os"
+)
+
+func parseCommand(args []string) int {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "dump the syntax tree as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: magot parse [flags] file.mg\n\n")
+		fmt.Fprintf(os.Stderr, "Parses a Magot program and prints its syntax tree.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file := flags.Arg(0)
+	source, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		for _, err := range p.ParseErrors() {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", file, err.Error())
+		}
+		return 1
+	}
+
+	if !*asJSON {
+		fmt.Println(program.String())
+		return 0
+	}
+	encoded, err := ast.EncodeIndent(program, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(encoded))
+	return 0
+}
@@ -1,10 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"magot/ast"
 	"magot/lexer"
 	"magot/token"
+	"math/big"
 	"strconv"
 )
 
@@ -30,6 +32,7 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.DIV:      PRODUCT,
 	token.MUL:      PRODUCT,
+	token.MOD:      PRODUCT,
 	token.LBRACKET: INDEX,
 }
 
@@ -39,14 +42,14 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	errors []ParseError
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []ParseError{}}
 
 	// set curToken and peekToken.
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -54,20 +57,27 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.SPAWN, p.parsePrefixExpression)
+	p.registerPrefix(token.AWAIT, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBool)
 	p.registerPrefix(token.FALSE, p.parseBool)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERPOLATED, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.MUL, p.parseInfixExpression)
 	p.registerInfix(token.DIV, p.parseInfixExpression)
+	p.registerInfix(token.MOD, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
@@ -120,6 +130,39 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseInterpolatedString parses the expressions of an interpolated string
+// with parsers of their own, positioned where they are in the source.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	parts, err := lexer.InterpolationParts(p.curToken)
+	if err != nil {
+		p.addError(p.curToken, "%s", err)
+		return nil
+	}
+	str := &ast.InterpolatedString{Token: p.curToken}
+	for _, part := range parts {
+		tok := token.Token{Type: token.STRING, Literal: part.Text, Line: part.Line, Column: part.Column}
+		if !part.Expression {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: tok, Value: part.Text})
+			continue
+		}
+		parser := New(lexer.NewAt(part.Text, part.Line, part.Column))
+		if parser.curTokenIs(token.EOF) {
+			p.addError(tok, "empty interpolation")
+			return nil
+		}
+		exp := parser.parseExpression(LOWEST)
+		if !parser.peekTokenIs(token.EOF) && len(parser.errors) == 0 {
+			parser.addError(parser.peekToken, "expected end of interpolation, got %s instead", parser.peekToken.Type)
+		}
+		if len(parser.errors) > 0 {
+			p.errors = append(p.errors, parser.errors...)
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+	}
+	return str
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -144,13 +187,37 @@ func (p *Parser) nextToken() {
 	p.peekToken = p.l.NextToken()
 }
 
+// ParseError is a parser error along with the position of the offending token.
+type ParseError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
 func (p *Parser) Errors() []string {
+	messages := []string{}
+	for _, err := range p.errors {
+		messages = append(messages, err.Message)
+	}
+	return messages
+}
+
+// ParseErrors returns the parser errors with their source positions.
+func (p *Parser) ParseErrors() []ParseError {
 	return p.errors
 }
 
+func (p *Parser) addError(tok token.Token, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	p.errors = append(p.errors, ParseError{Message: msg, Line: tok.Line, Column: tok.Column})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -173,6 +240,8 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -206,6 +275,16 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseYieldStatement() ast.Statement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -265,7 +344,11 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if ident.Value == "match" && p.peekTokenIs(token.LPAREN) {
+		return p.parseMatchExpression(ident)
+	}
+	return ident
 }
 
 func (p *Parser) parseBool() ast.Expression {
@@ -309,6 +392,298 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseForExpression() ast.Expression {
+	expression := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+	expression.Body = p.parseBlockStatement()
+	return expression
+}
+
+// parseSelectExpression parses the cases of a select expression, which are
+// calls to recv or send followed by a block, and its default case, a block
+// following the default identifier.
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		if p.curTokenIs(token.IDENT) && p.curToken.Literal == "default" {
+			if expression.Default != nil {
+				p.addError(p.curToken, "select has more than one default case")
+				return nil
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			p.nextToken()
+			expression.Default = p.parseBlockStatement()
+			continue
+		}
+		c := p.parseSelectCase()
+		if c == nil {
+			return nil
+		}
+		expression.Cases = append(expression.Cases, c)
+	}
+	p.nextToken()
+	return expression
+}
+
+// parseSelectCase parses a call to recv, which may be bound with let, or to
+// send, followed by the block evaluated when the case is chosen.
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	c := &ast.SelectCase{Token: p.curToken}
+	if p.curTokenIs(token.LET) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		c.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.ASSIGN) {
+			return nil
+		}
+		p.nextToken()
+	}
+	start := p.curToken
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+	call, ok := exp.(*ast.CallExpression)
+	if !ok {
+		p.addError(start, "expected a call to recv or send in select")
+		return nil
+	}
+	switch call.Function.TokenLiteral() {
+	case "recv":
+		if len(call.Arguments) != 1 {
+			p.addError(start, "wrong number of arguments to recv in select, got=%d, want=1", len(call.Arguments))
+			return nil
+		}
+	case "send":
+		if len(call.Arguments) != 2 {
+			p.addError(start, "wrong number of arguments to send in select, got=%d, want=2", len(call.Arguments))
+			return nil
+		}
+		if c.Name != nil {
+			p.addError(c.Token, "only the value received by recv can be bound in select")
+			return nil
+		}
+	default:
+		p.addError(start, "expected a call to recv or send in select")
+		return nil
+	}
+	c.Operation = call
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+	c.Body = p.parseBlockStatement()
+	return c
+}
+
+// parseMatchExpression parses a match expression, match not being a keyword
+// for the calls to the match builtin to keep working: match followed by a
+// single expression in parentheses and a { starts a match expression, and is
+// otherwise the match builtin called.
+func (p *Parser) parseMatchExpression(ident *ast.Identifier) ast.Expression {
+	p.nextToken()
+	call := &ast.CallExpression{Token: p.curToken, Function: ident}
+	call.Arguments = p.parseExpressionList(token.RPAREN)
+	if len(call.Arguments) != 1 || !p.peekTokenIs(token.LBRACE) {
+		return call
+	}
+	expression := &ast.MatchExpression{Token: ident.Token, Value: call.Arguments[0]}
+	p.nextToken()
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return expression
+}
+
+// parseMatchArm parses a pattern, the guard following it if any, and the
+// expression after its =>.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken}
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
+	if name := duplicateBinding(arm.Pattern); name != nil {
+		p.addError(name.Token, "%s is bound more than once in the pattern", name.Value)
+		return nil
+	}
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		if arm.Guard = p.parseExpression(LOWEST); arm.Guard == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	if arm.Value = p.parseExpression(LOWEST); arm.Value == nil {
+		return nil
+	}
+	return arm
+}
+
+// duplicateBinding returns the first name a pattern binds again, _ aside, or
+// nil if it binds each name once.
+func duplicateBinding(pattern ast.Pattern) *ast.Identifier {
+	bound := map[string]bool{}
+	var duplicate *ast.Identifier
+	ast.Inspect(pattern, func(node ast.Node) bool {
+		var name *ast.Identifier
+		switch node := node.(type) {
+		case *ast.BindingPattern:
+			name = node.Name
+		case *ast.ArrayPattern:
+			name = node.Rest
+		}
+		if name != nil && name.Value != "_" {
+			if bound[name.Value] {
+				duplicate = name
+			}
+			bound[name.Value] = true
+		}
+		return duplicate == nil
+	})
+	return duplicate
+}
+
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		return &ast.BindingPattern{Token: p.curToken, Name: name}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+	if literal := p.parseLiteralPattern(); literal != nil {
+		return literal
+	}
+	return nil
+}
+
+// parseLiteralPattern parses an integer, possibly negated, a string or a
+// boolean.
+func (p *Parser) parseLiteralPattern() *ast.LiteralPattern {
+	pattern := &ast.LiteralPattern{Token: p.curToken}
+	switch p.curToken.Type {
+	case token.INT:
+		pattern.Value = p.parseIntegerLiteral()
+	case token.MINUS:
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+		if right := p.parseIntegerLiteral(); right != nil {
+			pattern.Value = &ast.PrefixExpression{Token: pattern.Token, Operator: "-", Right: right}
+		}
+	case token.STRING:
+		pattern.Value = p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		pattern.Value = p.parseBool()
+	default:
+		p.addError(p.curToken, "expected a pattern, got %s instead", p.curToken.Type)
+		return nil
+	}
+	if pattern.Value == nil {
+		return nil
+	}
+	return pattern
+}
+
+// parseArrayPattern parses the patterns of the elements of an array, the last
+// of which may be a name prefixed by ... that the elements left are bound to.
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken, Elements: []ast.Pattern{}}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+// parseHashPattern parses the pairs of literal keys and patterns of a hash.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseLiteralPattern()
+		if key == nil {
+			return nil
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -338,6 +713,21 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+	lit.Body = p.parseBlockStatement()
+	return lit
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -364,9 +754,13 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	intLiteral := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: value}
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as Integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "could not parse %q as Integer", p.curToken.Literal)
 		return nil
 	}
 	intLiteral.Value = value
@@ -400,8 +794,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) noPrefixParseError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -78,6 +78,24 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "18446744073709551616;"
+
+	program := getProgram(t, input, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("expected *ast.ExpressionStatement, got=%T", stmt)
+	}
+
+	bigLit, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("expected *ast.BigIntegerLiteral, got=%T", stmt.Expression)
+	}
+	if bigLit.Value.String() != "18446744073709551616" {
+		t.Errorf("Erroneous value. expected=%s, got=%s", "18446744073709551616", bigLit.Value)
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -199,6 +217,7 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + b + c", "((a + b) + c)"},
 		{"a + b - c", "((a + b) - c)"},
 		{"a * b / c", "((a * b) / c)"},
+		{"a + b % c * d", "(a + ((b % c) * d))"},
 		{"a + b / c", "(a + (b / c))"},
 		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
 		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
@@ -218,6 +237,9 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"spawn f(a) + 1", "((spawn f(a)) + 1)"},
+		{"await spawn fn() { x }", "(await (spawn fn()x))"},
+		{"await [spawn f(), g][0]", "(await ([(spawn f()), g][0]))"},
 	}
 
 	for i, tt := range infixTests {
@@ -234,6 +256,142 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestYieldStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		value interface{}
+	}{
+		{"yield 5;", 5},
+		{"yield true", true},
+		{"yield foo;", "foo"},
+	}
+
+	for _, tt := range tests {
+		program := getProgram(t, tt.input, 1)
+		stmt, ok := program.Statements[0].(*ast.YieldStatement)
+		if !ok {
+			t.Fatalf("expected *ast.YieldStatement, got=%T", program.Statements[0])
+		}
+		if !testLiteralExpression(t, stmt.Value, tt.value) {
+			return
+		}
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	input := `for (x in xs) { yield x * 2; }`
+
+	program := getProgram(t, input, 1)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.ForExpression, got=%T", stmt.Expression)
+	}
+	if exp.Variable.Value != "x" || !testIdentifier(t, exp.Iterable, "xs") {
+		t.Fatalf("wrong variable or iterable, got=%s and %s", exp.Variable, exp.Iterable)
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement, got=%d", len(exp.Body.Statements))
+	}
+	if exp.String() != "for (x in xs) yield (x * 2);" {
+		t.Errorf("wrong String(), got=%q", exp.String())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for x in xs { x }`, "1:5: expected next token to be (, got IDENT instead"},
+		{`for (1 in xs) { x }`, "1:6: expected next token to be IDENT, got INT instead"},
+		{`for (x of xs) { x }`, "1:8: expected next token to be IN, got IDENT instead"},
+		{`for (x in xs) x`, "1:15: expected next token to be {, got IDENT instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.ParseErrors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %s. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (event) {
+		{"type": "user", "name": n} if len(n) > 3 => n,
+		[1, -2, true, "s", _, ...tail] => tail,
+		x => x,
+	}`
+
+	program := getProgram(t, input, 1)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.MatchExpression, got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Value, "event") {
+		return
+	}
+	if len(exp.Arms) != 3 {
+		t.Fatalf("match does not have 3 arms, got=%d", len(exp.Arms))
+	}
+	hash, ok := exp.Arms[0].Pattern.(*ast.HashPattern)
+	if !ok || len(hash.Keys) != 2 || exp.Arms[0].Guard == nil {
+		t.Fatalf("wrong first arm, got=%s", exp.Arms[0])
+	}
+	array, ok := exp.Arms[1].Pattern.(*ast.ArrayPattern)
+	if !ok || len(array.Elements) != 5 || array.Rest == nil || array.Rest.Value != "tail" {
+		t.Fatalf("wrong second arm, got=%s", exp.Arms[1])
+	}
+	if _, ok := exp.Arms[2].Pattern.(*ast.BindingPattern); !ok {
+		t.Fatalf("wrong third arm, got=%s", exp.Arms[2])
+	}
+	expected := "match (event) { {type:user, name:n} if (len(n) > 3) => n, [1, (-2), true, s, _, ...tail] => tail, x => x }"
+	if exp.String() != expected {
+		t.Errorf("wrong String(). expected=%q, got=%q", expected, exp.String())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match(re, s)`, "match(re, s)"},
+		{`match(s)[0]`, "(match(s)[0])"},
+		{`match (x) { }`, "match (x) {  }"},
+		{`match (x) { 1 => 2 } + 1`, "(match (x) { 1 => 2 } + 1)"},
+		{`match (x) { [_, _, ...b] => b, [b] => b }`, "match (x) { [_, _, ...b] => b, [b] => b }"},
+	}
+	for _, tt := range tests {
+		program := getProgram(t, tt.input, 1)
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %s. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (x) { 1 + 1 => 2 }`, "1:15: expected next token to be =>, got + instead"},
+		{`match (x) { a => 1 b => 2 }`, "1:20: expected next token to be ,, got IDENT instead"},
+		{`match (x) { [...a, b] => 1 }`, "1:18: expected next token to be ], got , instead"},
+		{`match (x) { {a: 1} => 1 }`, "1:14: expected a pattern, got IDENT instead"},
+		{`match (x) { (1) => 1 }`, "1:13: expected a pattern, got ( instead"},
+		{`match (x) { -a => 1 }`, "1:14: expected next token to be INT, got IDENT instead"},
+		{`match (x) { a if => 1 }`, "1:18: no prefix parse function for => found"},
+		{`match (x) { [a, a] => 1 }`, "1:17: a is bound more than once in the pattern"},
+		{`match (x) { {1: a, 2: [b, ...a]} => 1 }`, "1:30: a is bound more than once in the pattern"},
+	}
+	for _, tt := range errorTests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.ParseErrors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %s. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -451,6 +609,101 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	input := `"sum: ${a + b}, ${f(1)}!"`
+
+	program := getProgram(t, input, 1)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString, got=%T", stmt.Expression)
+	}
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. expected=5, got=%d", len(str.Parts))
+	}
+	testInfixExpression(t, str.Parts[1], "a", "+", "b")
+	if _, ok := str.Parts[3].(*ast.CallExpression); !ok {
+		t.Errorf("parts[3] not *ast.CallExpression, got=%T", str.Parts[3])
+	}
+	if str.String() != "sum: ${(a + b)}, ${f(1)}!" {
+		t.Errorf("wrong String(), got=%q", str.String())
+	}
+	if start := ast.StartToken(str.Parts[1]); start.Line != 1 || start.Column != 9 {
+		t.Errorf("wrong position of the interpolated expression, got=%d:%d", start.Line, start.Column)
+	}
+	if escaped := getProgram(t, `"$$${a}$${b} $$$$"`, 1).String(); escaped != "$$${a}$${b} $$$$" {
+		t.Errorf("wrong String() of escaped dollar signs, got=%q", escaped)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${a +}"`, "1:7: no prefix parse function for EOF found"},
+		{`"${a b}"`, "1:6: expected end of interpolation, got IDENT instead"},
+		{`"a ${}"`, "1:6: empty interpolation"},
+		{`"a ${b"`, "1:1: unterminated interpolation"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.ParseErrors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %s. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestSelectExpression(t *testing.T) {
+	input := `select {
+	let v = recv(jobs) { v }
+	send(out, 1 + 2) { 0 }
+	default { -1 }
+}`
+
+	program := getProgram(t, input, 1)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SelectExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SelectExpression, got=%T", stmt.Expression)
+	}
+	if len(exp.Cases) != 2 {
+		t.Fatalf("wrong number of cases. expected=2, got=%d", len(exp.Cases))
+	}
+	if exp.Cases[0].Name == nil || exp.Cases[0].Name.Value != "v" || exp.Cases[1].Name != nil {
+		t.Errorf("wrong names bound by the cases, got=%v and %v", exp.Cases[0].Name, exp.Cases[1].Name)
+	}
+	testInfixExpression(t, exp.Cases[1].Operation.Arguments[1], 1, "+", 2)
+	if exp.Default == nil || len(exp.Default.Statements) != 1 {
+		t.Fatalf("wrong default case, got=%v", exp.Default)
+	}
+	expected := "select { let v = recv(jobs) { v } send(out, (1 + 2)) { 0 } default { (-1) } }"
+	if exp.String() != expected {
+		t.Errorf("wrong String(). expected=%q, got=%q", expected, exp.String())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`select { puts(1) { 1 } }`, "1:10: expected a call to recv or send in select"},
+		{`select { let v = 1 { 1 } }`, "1:18: expected a call to recv or send in select"},
+		{`select { let v = send(c, 1) { 1 } }`, "1:10: only the value received by recv can be bound in select"},
+		{`select { recv(c, 1) { 1 } }`, "1:10: wrong number of arguments to recv in select, got=2, want=1"},
+		{`select { recv(c) 1 }`, "1:18: expected next token to be {, got INT instead"},
+		{`select { default { 1 } default { 2 } }`, "1:24: select has more than one default case"},
+		{`select { recv(c) { 1 }`, "1:23: no prefix parse function for EOF found"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.ParseErrors()
+		if len(errors) == 0 || errors[0].Error() != tt.expected {
+			t.Errorf("wrong errors for %s. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestArrayLiteral(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 4]"
 
@@ -544,6 +797,33 @@ func TestEmptyHashLiteral(t *testing.T) {
 	}
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	program := getProgram(t, input, 1)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("expected *ast.ExpressionStatement, got=%T", program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("expected *ast.MacroLiteral, got=%T", stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d", len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d", len(macro.Body.Statements))
+	}
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func checkParseErrors(t *testing.T, p *Parser) {
 	t.Helper()
 	errors := p.Errors()
@@ -0,0 +1,238 @@
+// Package profiler attributes the time and the allocations of evaluations to
+// the Magot functions and call sites they happen in, and writes them as
+// profiles that go tool pprof reads.
+package profiler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/object"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MAIN names the function of the top-level statements of a program.
+const MAIN = "main"
+
+const allocsMetric = "/gc/heap/allocs:objects"
+
+// Profiler records the Magot call stack as a program is evaluated, and
+// charges the time and the heap allocations between two evaluation events
+// to the stack they happened in. It is an evaluator.CallTracer. The functions
+// run by spawn push their calls on the same stack, in the order they happen.
+type Profiler struct {
+	mu    sync.Mutex
+	file  string
+	names map[*ast.BlockStatement]string
+
+	start, last time.Time
+	allocs      []metrics.Sample
+	lastAllocs  uint64
+
+	stack []frame
+	// The functions and locations are listed by id, minus 1, and indexed.
+	functions   []function
+	locations   []location
+	functionIDs map[function]uint64
+	locationIDs map[location]uint64
+	samples     map[string]*sample
+	order       []string // sample keys in creation order
+}
+
+type function struct {
+	name, file string
+}
+
+type frame struct {
+	function function
+	line     int
+}
+
+type location struct {
+	function uint64
+	line     int
+}
+
+type sample struct {
+	locations []uint64 // leaf first
+	nanos     int64
+	allocs    int64
+}
+
+// New returns a profiler for program, read from file, naming the functions
+// after the let statements that bind them. The measure starts at once.
+func New(file string, program *ast.Program) *Profiler {
+	p := &Profiler{
+		file:        file,
+		names:       ast.FunctionNames(program),
+		allocs:      []metrics.Sample{{Name: allocsMetric}},
+		stack:       []frame{{function: function{name: MAIN, file: file}}},
+		functionIDs: make(map[function]uint64),
+		locationIDs: make(map[location]uint64),
+		samples:     make(map[string]*sample),
+	}
+	p.start = time.Now()
+	p.last = p.start
+	p.lastAllocs = p.readAllocs()
+	return p
+}
+
+func (p *Profiler) readAllocs() uint64 {
+	metrics.Read(p.allocs)
+	if p.allocs[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return p.allocs[0].Value.Uint64()
+}
+
+// record charges what happened since the last event to the current stack.
+func (p *Profiler) record() {
+	now, allocs := time.Now(), p.readAllocs()
+	nanos, allocated := now.Sub(p.last).Nanoseconds(), int64(allocs-p.lastAllocs)
+	p.last, p.lastAllocs = now, allocs
+
+	locations := make([]uint64, len(p.stack))
+	var key strings.Builder
+	for i := range p.stack {
+		f := p.stack[len(p.stack)-1-i]
+		locations[i] = p.location(f)
+		fmt.Fprintf(&key, "%d,", locations[i])
+	}
+	s, ok := p.samples[key.String()]
+	if !ok {
+		s = &sample{locations: locations}
+		p.samples[key.String()] = s
+		p.order = append(p.order, key.String())
+	}
+	s.nanos += nanos
+	s.allocs += allocated
+}
+
+func (p *Profiler) location(f frame) uint64 {
+	id, ok := p.functionIDs[f.function]
+	if !ok {
+		p.functions = append(p.functions, f.function)
+		id = uint64(len(p.functions))
+		p.functionIDs[f.function] = id
+	}
+	loc := location{function: id, line: f.line}
+	id, ok = p.locationIDs[loc]
+	if !ok {
+		p.locations = append(p.locations, loc)
+		id = uint64(len(p.locations))
+		p.locationIDs[loc] = id
+	}
+	return id
+}
+
+func (p *Profiler) Statement(stmt ast.Statement, env *object.Environment) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record()
+	p.stack[len(p.stack)-1].line = ast.StartToken(stmt).Line
+}
+
+func (p *Profiler) Branch(node ast.Expression, way int) {}
+
+func (p *Profiler) Call(call *ast.CallExpression, fn object.Object) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record()
+	p.stack[len(p.stack)-1].line = ast.StartToken(call).Line
+	callee := frame{function: function{name: "?", file: p.file}}
+	switch fn := fn.(type) {
+	case *object.Function:
+		if name, ok := p.names[fn.Body]; ok {
+			callee.function.name = name
+		}
+		callee.line = fn.Body.Token.Line
+	case *object.Builtin:
+		callee.function = function{name: fn.Name, file: "<builtin>"}
+	}
+	p.stack = append(p.stack, callee)
+}
+
+func (p *Profiler) Return(call *ast.CallExpression, fn object.Object) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record()
+	if len(p.stack) > 1 {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// Stop ends the measure, charging the time since the last event to the
+// current stack.
+func (p *Profiler) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record()
+}
+
+// WriteProfile writes the profile as a gzipped protocol buffer, in the format
+// of github.com/google/pprof/proto/profile.proto. Its samples hold the time
+// spent in nanoseconds and the number of heap allocations.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	table := []string{""}
+	index := make(map[string]int64)
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+	valueType := func(typ, unit string) func(*protoBuffer) {
+		return func(b *protoBuffer) {
+			b.int64(1, str(typ))
+			b.int64(2, str(unit))
+		}
+	}
+
+	var b protoBuffer
+	b.message(1, valueType("cpu", "nanoseconds"))
+	b.message(1, valueType("alloc_objects", "count"))
+	for _, key := range p.order {
+		s := p.samples[key]
+		b.message(2, func(b *protoBuffer) {
+			b.packed(1, s.locations)
+			b.packed(2, []uint64{uint64(s.nanos), uint64(s.allocs)})
+		})
+	}
+	for i, loc := range p.locations {
+		b.message(4, func(b *protoBuffer) {
+			b.uint64(1, uint64(i+1))
+			b.message(4, func(b *protoBuffer) {
+				b.uint64(1, loc.function)
+				b.int64(2, int64(loc.line))
+			})
+		})
+	}
+	for i, fn := range p.functions {
+		b.message(5, func(b *protoBuffer) {
+			b.uint64(1, uint64(i+1))
+			b.int64(2, str(fn.name))
+			b.int64(3, str(fn.name))
+			b.int64(4, str(fn.file))
+		})
+	}
+	b.int64(9, p.start.UnixNano())
+	b.int64(10, p.last.Sub(p.start).Nanoseconds())
+	b.message(11, valueType("cpu", "nanoseconds"))
+	b.int64(12, 1)
+	b.int64(14, str("cpu")) // the default sample type
+	for _, s := range table {
+		b.bytes(6, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,117 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/evaluator"
+	"magot/lexer"
+	"magot/object"
+	"magot/parser"
+	"strings"
+	"testing"
+)
+
+const source = `let twice = fn(f, x) { f(f(x)) };
+let inc = fn(x) {
+  x + 1
+};
+let size = fn() { len("abc") };
+twice(inc, 1);
+twice(fn(x) { x * 2 }, size());
+`
+
+func TestProfile(t *testing.T) {
+	program := parse(t, source)
+	prof := New("prog.mg", program)
+	env := object.NewEnvironment()
+	evaluator.SetContext(env, &evaluator.Context{Tracer: prof})
+	evaluator.Eval(program, env)
+	prof.Stop()
+
+	stacks := make(map[string]bool)
+	for _, key := range prof.order {
+		names := []string{}
+		for _, id := range prof.samples[key].locations {
+			loc := prof.locations[id-1]
+			fn := prof.functions[loc.function-1]
+			names = append(names, fmt.Sprintf("%s:%d", fn.name, loc.line))
+		}
+		stacks[strings.Join(names, " ")] = true
+	}
+
+	for _, expected := range []string{
+		"main:6",
+		"twice:1 main:6",
+		"inc:3 twice:1 main:6",
+		"size:5 main:7",
+		"len:0 size:5 main:7",
+		"fn@7:7:7 twice:1 main:7",
+	} {
+		if !stacks[expected] {
+			t.Errorf("stack %q not sampled, got %v", expected, stacks)
+		}
+	}
+	if prof.stack[0].function.name != MAIN || len(prof.stack) != 1 {
+		t.Errorf("wrong stack after the evaluation. got=%v", prof.stack)
+	}
+	for _, fn := range prof.functions {
+		if fn.name == "len" && fn.file != "<builtin>" {
+			t.Errorf("builtin in file %q", fn.file)
+		}
+	}
+}
+
+func TestWriteProfile(t *testing.T) {
+	program := parse(t, source)
+	prof := New("prog.mg", program)
+	env := object.NewEnvironment()
+	evaluator.SetContext(env, &evaluator.Context{Tracer: prof})
+	evaluator.Eval(program, env)
+	prof.Stop()
+
+	var out bytes.Buffer
+	if err := prof.WriteProfile(&out); err != nil {
+		t.Fatalf("WriteProfile failed: %s", err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("profile not gzipped: %s", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, str := range []string{"cpu", "nanoseconds", "alloc_objects", "twice", "prog.mg", "<builtin>"} {
+		if !bytes.Contains(data, []byte(str)) {
+			t.Errorf("profile does not contain %q", str)
+		}
+	}
+}
+
+func TestProtoBuffer(t *testing.T) {
+	var b protoBuffer
+	b.uint64(1, 150)
+	b.uint64(2, 0)
+	b.message(3, func(b *protoBuffer) {
+		b.packed(4, []uint64{3, 270})
+	})
+	b.bytes(5, []byte("ab"))
+
+	expected := []byte{0x08, 0x96, 0x01, 0x1a, 0x05, 0x22, 0x03, 0x03, 0x8e, 0x02, 0x2a, 0x02, 'a', 'b'}
+	if !bytes.Equal(b.Bytes(), expected) {
+		t.Errorf("wrong encoding. expected=% x, got=% x", expected, b.Bytes())
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,64 @@
+package profiler
+
+import "bytes"
+
+// protoBuffer encodes protocol buffer messages, which is all that is needed
+// to write profiles in the format of github.com/google/pprof/proto.
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+func (b *protoBuffer) key(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint64 encodes a field, which is omitted when it holds the default value
+// like the other scalar fields.
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protoBuffer) packed(field int, xs []uint64) {
+	if len(xs) == 0 {
+		return
+	}
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, packed.Bytes())
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+// message encodes a field holding the message written by encode.
+func (b *protoBuffer) message(field int, encode func(*protoBuffer)) {
+	var message protoBuffer
+	encode(&message)
+	b.bytes(field, message.Bytes())
+}
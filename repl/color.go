@@ -0,0 +1,163 @@
+package repl
+
+import (
+	"io"
+	"magot/lexer"
+	"magot/object"
+	"magot/token"
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences of the colors used by the REPL.
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+// useColor reports whether out is a terminal that may be written in color,
+// which the user can prevent by setting NO_COLOR.
+func useColor(out io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	file, ok := out.(*os.File)
+	return ok && isTerminal(int(file.Fd()))
+}
+
+func paint(color, text string) string {
+	if text == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// tokenColor returns the color of a token type, or "" for the tokens written
+// as they are.
+func tokenColor(t token.TokenType) string {
+	switch t {
+	case token.LET, token.FUNCTION, token.IF, token.ELSE, token.RETURN, token.TRUE, token.FALSE, token.MACRO,
+		token.SPAWN, token.AWAIT, token.SELECT, token.FOR, token.IN, token.YIELD:
+		return colorBlue
+	case token.STRING, token.INTERPOLATED:
+		return colorGreen
+	case token.INT:
+		return colorCyan
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.MUL, token.DIV, token.MOD, token.LT, token.GT, token.EQ, token.NEQ:
+		return colorYellow
+	case token.ILLEGAL:
+		return colorRed
+	}
+	return ""
+}
+
+// highlight colors the tokens of input, and the comments between them. A
+// string token spans the source up to its closing quote, or up to the end
+// of the input when it is unterminated.
+func highlight(input string) string {
+	lineStarts := []int{0}
+	for i := 0; i < len(input); i++ {
+		if input[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	// the end of file comes past the input after an unterminated string
+	clamp := func(i int) int {
+		if i < len(input) {
+			return i
+		}
+		return len(input)
+	}
+	offset := func(tok token.Token) int {
+		return clamp(lineStarts[tok.Line-1] + tok.Column - 1)
+	}
+
+	var out strings.Builder
+	l := lexer.New(input)
+	tok := l.NextToken()
+	out.WriteString(paintComments(input[:offset(tok)]))
+	for tok.Type != token.EOF {
+		next := l.NextToken()
+		start, end := offset(tok), offset(tok)+len(tok.Literal)
+		if tok.Type == token.STRING || tok.Type == token.INTERPOLATED {
+			end += 2
+		}
+		end = clamp(end)
+		if color := tokenColor(tok.Type); color != "" {
+			out.WriteString(paint(color, input[start:end]))
+		} else {
+			out.WriteString(input[start:end])
+		}
+		out.WriteString(paintComments(input[end:offset(next)]))
+		tok = next
+	}
+	out.WriteString(input[offset(tok):])
+	return out.String()
+}
+
+// paintComments colors the comments found in the whitespace between tokens.
+func paintComments(gap string) string {
+	var out strings.Builder
+	for {
+		i := strings.Index(gap, "//")
+		if i < 0 {
+			out.WriteString(gap)
+			return out.String()
+		}
+		end := strings.IndexByte(gap[i:], '\n')
+		if end < 0 {
+			end = len(gap) - i
+		}
+		comment := strings.TrimRight(gap[i:i+end], "\r")
+		out.WriteString(gap[:i])
+		out.WriteString(paint(colorGray, comment))
+		gap = gap[i+len(comment):]
+	}
+}
+
+// objectColor returns the color results of the type of obj are printed in.
+func objectColor(obj object.Object) string {
+	switch obj.Type() {
+	case object.INTEGER_OBJ, object.BIGINT_OBJ:
+		return colorCyan
+	case object.STRING_OBJ:
+		return colorGreen
+	case object.BOOLEAN_OBJ:
+		return colorBlue
+	case object.NULL_OBJ:
+		return colorGray
+	case object.FUNCTION_OBJ, object.BUILTIN_OBJ, object.MACRO_OBJ, object.QUOTE_OBJ:
+		return colorMagenta
+	case object.ERROR_OBJ:
+		return colorRed
+	}
+	return ""
+}
+
+// caret returns the line of source at line followed by a line pointing at
+// column, tabs being kept so that the caret lines up with the source.
+func caret(source string, line, column int) string {
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := lines[line-1]
+	var pointer strings.Builder
+	for i := 0; i < column-1 && i < len(text); i++ {
+		if text[i] == '\t' {
+			pointer.WriteByte('\t')
+		} else {
+			pointer.WriteByte(' ')
+		}
+	}
+	for i := len(text); i < column-1; i++ {
+		pointer.WriteByte(' ')
+	}
+	return text + "\n" + pointer.String() + "^"
+}
@@ -0,0 +1,72 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"  ", "  "},
+		{"let x = 5;", "\x1b[34mlet\x1b[0m x \x1b[33m=\x1b[0m \x1b[36m5\x1b[0m;"},
+		{` "a b" + x `, " \x1b[32m\"a b\"\x1b[0m \x1b[33m+\x1b[0m x "},
+		{`fn(x) { "open`, "\x1b[34mfn\x1b[0m(x) { \x1b[32m\"open\x1b[0m"},
+		{"if (a\n  == b)", "\x1b[34mif\x1b[0m (a\n  \x1b[33m==\x1b[0m b)"},
+		{"a @ b", "a \x1b[31m@\x1b[0m b"},
+		{"// doc\nx; // note\r\n", "\x1b[90m// doc\x1b[0m\nx; \x1b[90m// note\x1b[0m\r\n"},
+		{`"http://a" // b`, "\x1b[32m\"http://a\"\x1b[0m \x1b[90m// b\x1b[0m"},
+	}
+
+	for _, tt := range tests {
+		if highlighted := highlight(tt.input); highlighted != tt.expected {
+			t.Errorf("wrong highlighting of %q. expected=%q, got=%q", tt.input, tt.expected, highlighted)
+		}
+	}
+}
+
+func TestCaret(t *testing.T) {
+	tests := []struct {
+		source       string
+		line, column int
+		expected     string
+	}{
+		{"let = 5;", 1, 5, "let = 5;\n    ^"},
+		{"\tlet = 5;", 1, 6, "\tlet = 5;\n\t    ^"},
+		{"let a = 1;\nlet 2", 2, 5, "let 2\n    ^"},
+		{"let a", 1, 6, "let a\n     ^"},
+		{"let a", 2, 1, ""},
+	}
+
+	for _, tt := range tests {
+		if c := caret(tt.source, tt.line, tt.column); c != tt.expected {
+			t.Errorf("wrong caret for %q at %d:%d. expected=%q, got=%q", tt.source, tt.line, tt.column, tt.expected, c)
+		}
+	}
+}
+
+func TestParseErrorOutput(t *testing.T) {
+	var out bytes.Buffer
+
+	Start(strings.NewReader("let a 5;"), &out)
+
+	expected := ">>>  parser errors:\n" +
+		"\t1:7: expected next token to be =, got INT instead\n" +
+		"\tlet a 5;\n" +
+		"\t      ^\n" +
+		">>> "
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if useColor(&bytes.Buffer{}) {
+		t.Errorf("color used when output is not a terminal")
+	}
+}
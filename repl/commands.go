@@ -0,0 +1,235 @@
+package repl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"magot/ast"
+	"magot/evaluator"
+	"magot/object"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// command is a REPL meta-command, typed with a leading colon.
+type command struct {
+	args string
+	help string
+	run  func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"help":         {"", "list the commands", (*session).help},
+		"env":          {"", "list the bindings of the session with their types", (*session).listEnv},
+		"type":         {"expr", "evaluate expr and print its type", (*session).printType},
+		"ast":          {"expr", "print the syntax tree of expr", (*session).printAST},
+		"load":         {"file.mg", "evaluate a file in the session", (*session).load},
+		"save":         {"file.mg", "write the inputs of the session to a file", (*session).save},
+		"reset":        {"", "clear the bindings and inputs of the session", (*session).reset},
+		"save-session": {"file", "write the bindings and inputs of the session to a file", (*session).saveSession},
+		"load-session": {"file", "replace the session with one written by :save-session", (*session).loadSession},
+		"time":         {"expr", "evaluate expr and print how long it took", (*session).time},
+		"quit":         {"", "leave the REPL", (*session).quit},
+	}
+}
+
+func isCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), ":")
+}
+
+func (s *session) runCommand(input string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), ":")
+	name, arg := input, ""
+	if i := strings.IndexAny(input, " \t\n"); i >= 0 {
+		name, arg = input[:i], strings.TrimSpace(input[i+1:])
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, type :help for the list of commands\n", name)
+		return
+	}
+	if cmd.args != "" && arg == "" {
+		fmt.Fprintf(s.out, "usage: :%s %s\n", name, cmd.args)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+func (s *session) help(string) {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		usage := strings.TrimSpace(":" + name + " " + cmd.args)
+		fmt.Fprintf(s.out, "%-20s %s\n", usage, cmd.help)
+	}
+}
+
+func (s *session) listEnv(string) {
+	for _, env := range []*object.Environment{s.macroEnv, s.env} {
+		for _, name := range env.Names() {
+			value, _ := env.Get(name)
+			fmt.Fprintf(s.out, "%s: %s\n", name, value.Type())
+		}
+	}
+}
+
+func (s *session) printType(arg string) {
+	evaluated, ok := s.eval(arg)
+	if !ok {
+		return
+	}
+	if evaluated == nil {
+		fmt.Fprintln(s.out, "no value")
+		return
+	}
+	fmt.Fprintln(s.out, evaluated.Type())
+}
+
+func (s *session) printAST(arg string) {
+	program, ok := s.parse(arg)
+	if !ok {
+		return
+	}
+	dumpAST(s.out, program)
+}
+
+func (s *session) load(arg string) {
+	source, err := os.ReadFile(arg)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	if evaluated, ok := s.eval(string(source)); ok {
+		s.inputs = append(s.inputs, string(source))
+		s.print(string(source), evaluated)
+	}
+}
+
+func (s *session) save(arg string) {
+	source := strings.Join(s.inputs, "\n")
+	if source != "" {
+		source += "\n"
+	}
+	if err := os.WriteFile(arg, []byte(source), 0644); err != nil {
+		s.printError(err)
+	}
+}
+
+func (s *session) reset(string) {
+	s.env = object.NewEnvironment()
+	s.macroEnv = object.NewEnvironment()
+	s.inputs = nil
+	s.setContext(s.context)
+}
+
+// sessionFile is the content of the files written by :save-session.
+type sessionFile struct {
+	Env    json.RawMessage `json:"env"`
+	Macros json.RawMessage `json:"macros"`
+	Inputs []string        `json:"inputs"`
+}
+
+func (s *session) saveSession(arg string) {
+	env, err := object.Snapshot(s.env)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	macros, err := object.Snapshot(s.macroEnv)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	data, err := json.Marshal(sessionFile{Env: env, Macros: macros, Inputs: s.inputs})
+	if err == nil {
+		err = os.WriteFile(arg, data, 0644)
+	}
+	if err != nil {
+		s.printError(err)
+	}
+}
+
+func (s *session) loadSession(arg string) {
+	data, err := os.ReadFile(arg)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	var file sessionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		s.printError(err)
+		return
+	}
+	env, err := object.Restore(file.Env, evaluator.LookupBuiltin)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	macroEnv, err := object.Restore(file.Macros, evaluator.LookupBuiltin)
+	if err != nil {
+		s.printError(err)
+		return
+	}
+	s.env, s.macroEnv, s.inputs = env, macroEnv, file.Inputs
+	s.setContext(s.context)
+}
+
+func (s *session) time(arg string) {
+	start := time.Now()
+	evaluated, ok := s.eval(arg)
+	elapsed := time.Since(start)
+	if !ok {
+		return
+	}
+	s.print(arg, evaluated)
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
+
+func (s *session) quit(string) {
+	s.done = true
+}
+
+// dumpAST prints a tree with one node per line, indented by depth.
+func dumpAST(out io.Writer, node ast.Node) {
+	depth := 0
+	ast.Inspect(node, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", depth), describeNode(node))
+		depth++
+		return true
+	})
+}
+
+func describeNode(node ast.Node) string {
+	name := reflect.TypeOf(node).Elem().Name()
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return name + " " + node.Value
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("%s %d", name, node.Value)
+	case *ast.BigIntegerLiteral:
+		return fmt.Sprintf("%s %d", name, node.Value)
+	case *ast.StringLiteral:
+		return fmt.Sprintf("%s %q", name, node.Value)
+	case *ast.Boolean:
+		return fmt.Sprintf("%s %t", name, node.Value)
+	case *ast.PrefixExpression:
+		return name + " " + node.Operator
+	case *ast.InfixExpression:
+		return name + " " + node.Operator
+	}
+	return name
+}
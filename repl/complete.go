@@ -0,0 +1,83 @@
+package repl
+
+import (
+	"magot/evaluator"
+	"magot/object"
+	"magot/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hashKeyContext matches a line ending inside the string index of a hash,
+// as in h["na.
+var hashKeyContext = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\["([^"]*)$`)
+
+// complete returns the completions of the word ending line, which is the
+// input before the cursor, along with that word. A completion replaces the
+// whole word.
+func complete(env *object.Environment, line string) (string, []string) {
+	if m := hashKeyContext.FindStringSubmatch(line); m != nil {
+		return m[2], completeHashKey(env, m[1], m[2])
+	}
+
+	start := len(line)
+	for start > 0 && isWordRune(rune(line[start-1])) {
+		start--
+	}
+	word := line[start:]
+	if word == "" || (word[0] >= '0' && word[0] <= '9') {
+		return word, nil
+	}
+
+	seen := make(map[string]bool)
+	candidates := []string{}
+	for _, names := range [][]string{env.Names(), evaluator.BuiltinNames(), evaluator.ModuleNames(), token.Keywords()} {
+		for _, name := range names {
+			if strings.HasPrefix(name, word) && !seen[name] {
+				seen[name] = true
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	sort.Strings(candidates)
+	return word, candidates
+}
+
+// completeHashKey returns the string keys of the hash bound to name that
+// start with prefix, followed by the closing quote and bracket.
+func completeHashKey(env *object.Environment, name, prefix string) []string {
+	obj, ok := env.Get(name)
+	if !ok {
+		if obj, ok = evaluator.LookupModule(name); !ok {
+			return nil
+		}
+	}
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return nil
+	}
+	candidates := []string{}
+	for _, pair := range hash.Pairs {
+		key, ok := pair.Key.(*object.String)
+		if ok && strings.HasPrefix(key.Value, prefix) {
+			candidates = append(candidates, key.Value+`"]`)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// commonPrefix returns the longest prefix shared by all the words.
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"magot/object"
+	"reflect"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("length", &object.Integer{Value: 1})
+	env.Set("h", &object.Hash{Pairs: map[object.HashKey]object.HashPair{
+		(&object.String{Value: "name"}).HashKey(): {Key: &object.String{Value: "name"}, Value: &object.Integer{Value: 1}},
+		(&object.String{Value: "nick"}).HashKey(): {Key: &object.String{Value: "nick"}, Value: &object.Integer{Value: 2}},
+		(&object.Integer{Value: 3}).HashKey():     {Key: &object.Integer{Value: 3}, Value: &object.Integer{Value: 3}},
+		(&object.String{Value: "age"}).HashKey():  {Key: &object.String{Value: "age"}, Value: &object.Integer{Value: 4}},
+	}})
+	inner := object.NewEnclosedEnvironment(env)
+	inner.Set("lemon", &object.Integer{Value: 2})
+
+	tests := []struct {
+		line        string
+		word        string
+		completions []string
+	}{
+		{"le", "le", []string{"lemon", "len", "length", "let"}},
+		{"1 + pu", "pu", []string{"push", "puts"}},
+		{"mac", "mac", []string{"macro"}},
+		{"re", "re", []string{"read_line", "recv", "regex", "replace", "rest", "return"}},
+		{"zz", "zz", []string{}},
+		{"12", "12", nil},
+		{"", "", nil},
+		{`h["n`, "n", []string{`name"]`, `nick"]`}},
+		{`len(h["`, "", []string{`age"]`, `name"]`, `nick"]`}},
+		{`lemon["`, "", nil},
+		{`nothing["a`, "a", nil},
+		{`f`, "f", []string{"false", "filter", "find_all", "first", "fn", "for", "format", "format_time", "from_unix", "fs"}},
+		{`fs["read_`, "read_", []string{`read_file"]`, `read_lines"]`}},
+	}
+
+	for _, tt := range tests {
+		word, completions := complete(inner, tt.line)
+		if word != tt.word {
+			t.Errorf("wrong word for %q. expected=%q, got=%q", tt.line, tt.word, word)
+		}
+		if !reflect.DeepEqual(completions, tt.completions) {
+			t.Errorf("wrong completions for %q. expected=%q, got=%q", tt.line, tt.completions, completions)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		words    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"push"}, "push"},
+		{[]string{"push", "puts"}, "pu"},
+		{[]string{"len", "let", "lemon"}, "le"},
+		{[]string{"first", "last"}, ""},
+	}
+
+	for _, tt := range tests {
+		if prefix := commonPrefix(tt.words); prefix != tt.expected {
+			t.Errorf("wrong prefix for %q. expected=%q, got=%q", tt.words, tt.expected, prefix)
+		}
+	}
+}
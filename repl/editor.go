@@ -0,0 +1,341 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by ReadLine when the user cancels the input.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the lines of input typed in the REPL.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader reads lines from a non-interactive input.
+type plainReader struct {
+	lines *bufio.Reader
+	out   io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	line, err := r.lines.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlT     = 20
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// lineEditor reads lines from a terminal with emacs-style key bindings and
+// history navigation.
+type lineEditor struct {
+	fd      int
+	in      *bufio.Reader
+	out     io.Writer
+	history *history
+	// complete returns the completions of the word ending the text before
+	// the cursor, and that word.
+	complete func(line string) (string, []string)
+	// highlight returns the line as displayed, nil leaving it as typed.
+	highlight func(line string) string
+
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func newLineEditor(in *os.File, out io.Writer, history *history, complete func(string) (string, []string)) *lineEditor {
+	return &lineEditor{
+		fd:       int(in.Fd()),
+		in:       bufio.NewReader(in),
+		out:      out,
+		history:  history,
+		complete: complete,
+	}
+}
+
+// ReadLine reads a line in raw mode, the terminal being restored before it
+// returns so that the evaluation output is displayed as usual.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restoreTerminal(e.fd, state)
+
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+	historyIndex := len(e.history.entries)
+	current := ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			e.pos = len(e.buf)
+			e.refresh()
+			io.WriteString(e.out, "\r\n")
+			return string(e.buf), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteRange(e.pos, e.pos+1)
+		case keyBackspace, keyDelete:
+			e.deleteRange(e.pos-1, e.pos)
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlB:
+			e.move(-1)
+		case keyCtrlF:
+			e.move(1)
+		case keyCtrlK:
+			e.deleteRange(e.pos, len(e.buf))
+		case keyCtrlU:
+			e.deleteRange(0, e.pos)
+		case keyCtrlW:
+			e.deleteRange(e.wordStart(), e.pos)
+		case keyCtrlT:
+			e.transpose()
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP, keyCtrlN:
+			historyIndex, current = e.browseHistory(r == keyCtrlP, historyIndex, current)
+		case keyTab:
+			e.completeWord()
+		case keyEscape:
+			historyIndex, current = e.escape(historyIndex, current)
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// escape handles the escape sequences sent by arrow, home, end and delete
+// keys, and the alt-b and alt-f word motions.
+func (e *lineEditor) escape(historyIndex int, current string) (int, string) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return historyIndex, current
+	}
+	switch r {
+	case 'b':
+		e.pos = e.wordStart()
+		return historyIndex, current
+	case 'f':
+		e.pos = e.wordEnd()
+		return historyIndex, current
+	case '[', 'O':
+	default:
+		return historyIndex, current
+	}
+
+	seq := []rune{}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return historyIndex, current
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return e.browseHistory(true, historyIndex, current)
+	case "B":
+		return e.browseHistory(false, historyIndex, current)
+	case "C":
+		e.move(1)
+	case "D":
+		e.move(-1)
+	case "H", "1~":
+		e.pos = 0
+	case "F", "4~":
+		e.pos = len(e.buf)
+	case "3~":
+		e.deleteRange(e.pos, e.pos+1)
+	}
+	return historyIndex, current
+}
+
+// browseHistory replaces the line with the previous or next history entry,
+// the line being edited before browsing coming back after the last entry.
+func (e *lineEditor) browseHistory(previous bool, index int, current string) (int, string) {
+	entries := e.history.entries
+	if index == len(entries) {
+		current = string(e.buf)
+	}
+	if previous && index > 0 {
+		index--
+	} else if !previous && index < len(entries) {
+		index++
+	} else {
+		return index, current
+	}
+	if index == len(entries) {
+		e.buf = []rune(current)
+	} else {
+		e.buf = []rune(entries[index])
+	}
+	e.pos = len(e.buf)
+	return index, current
+}
+
+func (e *lineEditor) insert(runes []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(runes))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	buf = append(buf, e.buf[e.pos:]...)
+	e.buf = buf
+	e.pos += len(runes)
+}
+
+func (e *lineEditor) deleteRange(from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(e.buf) {
+		to = len(e.buf)
+	}
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+}
+
+func (e *lineEditor) move(delta int) {
+	e.pos += delta
+	if e.pos < 0 {
+		e.pos = 0
+	}
+	if e.pos > len(e.buf) {
+		e.pos = len(e.buf)
+	}
+}
+
+func (e *lineEditor) transpose() {
+	if e.pos == 0 || len(e.buf) < 2 {
+		return
+	}
+	if e.pos == len(e.buf) {
+		e.pos--
+	}
+	e.buf[e.pos-1], e.buf[e.pos] = e.buf[e.pos], e.buf[e.pos-1]
+	e.pos++
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func (e *lineEditor) wordStart() int {
+	i := e.pos
+	for i > 0 && !isWordRune(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+func (e *lineEditor) wordEnd() int {
+	i := e.pos
+	for i < len(e.buf) && !isWordRune(e.buf[i]) {
+		i++
+	}
+	for i < len(e.buf) && isWordRune(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// completeWord extends the word before the cursor with the prefix its
+// completions share, listing them when there is nothing to add. Tab indents
+// when there is no word to complete.
+func (e *lineEditor) completeWord() {
+	word, candidates := "", []string(nil)
+	if e.complete != nil {
+		word, candidates = e.complete(string(e.buf[:e.pos]))
+	}
+	if len(candidates) == 0 {
+		if word == "" {
+			e.insert([]rune("  "))
+		}
+		return
+	}
+	if common := commonPrefix(candidates); len(common) > len(word) {
+		e.insert([]rune(common[len(word):]))
+		return
+	}
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+	}
+}
+
+// refresh redraws the prompt and the line, then puts the cursor back in
+// place.
+func (e *lineEditor) refresh() {
+	var out strings.Builder
+	out.WriteString("\r")
+	out.WriteString(e.prompt)
+	line := string(e.buf)
+	if e.highlight != nil {
+		line = e.highlight(line)
+	}
+	// the newlines of the inputs recalled from the history are shown as ⏎
+	// for the line to stay on a single row
+	out.WriteString(strings.ReplaceAll(line, "\n", "⏎"))
+	out.WriteString("\x1b[K\r")
+	if column := len([]rune(e.prompt)) + e.pos; column > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", column)
+	}
+	io.WriteString(e.out, out.String())
+}
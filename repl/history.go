@@ -0,0 +1,84 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxHistory = 1000
+
+// history holds the inputs entered in previous and current sessions. Entries
+// are appended to the history file as they are added, one per line, with
+// their newlines and backslashes escaped.
+type history struct {
+	entries []string
+	path    string
+}
+
+// historyPath returns the file holding the history, $MAGOT_HISTORY or
+// ~/.magot_history by default.
+func historyPath() string {
+	if path := os.Getenv("MAGOT_HISTORY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".magot_history")
+}
+
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescapeEntry(line))
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h
+}
+
+// add records an input, multi-line inputs keeping their newlines.
+func (h *history) add(input string) {
+	entry := strings.TrimSpace(input)
+	if entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if h.path == "" {
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(escapeEntry(entry) + "\n")
+}
+
+var entryEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeEntry(entry string) string {
+	return entryEscaper.Replace(entry)
+}
+
+var entryUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+
+func unescapeEntry(line string) string {
+	return entryUnescaper.Replace(line)
+}
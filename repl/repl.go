@@ -2,46 +2,220 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"magot/ast"
 	"magot/evaluator"
 	"magot/lexer"
 	"magot/object"
 	"magot/parser"
+	"magot/token"
+	"os"
+	"strings"
 )
 
-const PROMPT = ">>> "
+const (
+	PROMPT              = ">>> "
+	CONTINUATION_PROMPT = "... "
+)
+
+// session is the state of a REPL between two inputs.
+type session struct {
+	out      io.Writer
+	context  *evaluator.Context // of the evaluations in env and macroEnv
+	env      *object.Environment
+	macroEnv *object.Environment
+	inputs   []string // the inputs evaluated since the last reset
+	color    bool
+	done     bool
+}
+
+func newSession(out io.Writer) *session {
+	return &session{
+		out:      out,
+		env:      object.NewEnvironment(),
+		macroEnv: object.NewEnvironment(),
+		color:    useColor(out),
+	}
+}
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := newSession(out)
+	reader := newLineReader(in, out, func(line string) (string, []string) {
+		return complete(s.env, line)
+	})
+	if editor, ok := reader.(*lineEditor); ok && s.color {
+		editor.highlight = highlight
+	}
+	stdin := bufio.NewReader(in)
+	if plain, ok := reader.(*plainReader); ok {
+		// the programs read the lines following the inputs reading them
+		stdin = plain.lines
+	}
+	s.setContext(&evaluator.Context{Stdout: out, Stderr: out, Stdin: stdin})
 
-	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+	for !s.done {
+		input, err := readInput(reader)
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil && input == "" {
 			return
 		}
-		line := scanner.Text()
-		lex := lexer.New(line)
-		parse := parser.New(lex)
-		program := parse.ParseProgram()
+		if editor, ok := reader.(*lineEditor); ok {
+			editor.history.add(input)
+		}
 
-		if len(parse.Errors()) != 0 {
-			printParseErrors(out, parse.Errors())
+		if isCommand(input) {
+			s.runCommand(input)
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		if evaluated, ok := s.eval(input); ok {
+			s.inputs = append(s.inputs, input)
+			s.print(input, evaluated)
+		}
+	}
+}
+
+// setContext sets the context of the evaluations of the session, which its
+// environments keep once they are reset or loaded.
+func (s *session) setContext(context *evaluator.Context) {
+	s.context = context
+	evaluator.SetContext(s.env, context)
+	evaluator.SetContext(s.macroEnv, context)
+}
+
+// parse parses and expands an input, printing the errors it contains.
+func (s *session) parse(input string) (ast.Node, bool) {
+	lex := lexer.New(input)
+	parse := parser.New(lex)
+	program := parse.ParseProgram()
+
+	if len(parse.Errors()) != 0 {
+		s.printParseErrors(input, parse.ParseErrors())
+		return nil, false
+	}
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		s.printError(err)
+		return nil, false
+	}
+	return expanded, true
+}
+
+// eval evaluates an input in the session environment, the second result
+// being false when it does not parse.
+func (s *session) eval(input string) (object.Object, bool) {
+	program, ok := s.parse(input)
+	if !ok {
+		return nil, false
+	}
+	return evaluator.Eval(program, s.env), true
+}
+
+// print prints the result of evaluating source, with a caret under the
+// expression that failed for errors.
+func (s *session) print(source string, evaluated object.Object) {
+	if evaluated == nil {
+		return
+	}
+	if s.color {
+		io.WriteString(s.out, paint(objectColor(evaluated), evaluated.Inspect()))
+	} else {
+		io.WriteString(s.out, evaluated.Inspect())
+	}
+	io.WriteString(s.out, "\n")
+	if err, ok := evaluated.(*object.Error); ok {
+		if c := caret(source, err.Line, err.Column); c != "" {
+			io.WriteString(s.out, c+"\n")
+		}
+	}
+}
+
+func (s *session) printError(err error) {
+	s.printRed("ERROR: " + err.Error())
+	io.WriteString(s.out, "\n")
+}
+
+func (s *session) printRed(text string) {
+	if s.color {
+		text = paint(colorRed, text)
+	}
+	io.WriteString(s.out, text)
+}
+
+// newLineReader edits lines in place when in is a terminal, and reads them
+// as they come otherwise.
+func newLineReader(in io.Reader, out io.Writer, complete func(string) (string, []string)) lineReader {
+	if file, ok := in.(*os.File); ok && isTerminal(int(file.Fd())) {
+		return newLineEditor(file, out, loadHistory(historyPath()), complete)
+	}
+	return &plainReader{lines: bufio.NewReader(in), out: out}
+}
+
+// readInput reads lines until they form a complete input, showing the
+// continuation prompt after the first one.
+func readInput(reader lineReader) (string, error) {
+	line, err := reader.ReadLine(PROMPT)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{line}
+	for isIncomplete(strings.Join(lines, "\n")) {
+		line, err := reader.ReadLine(CONTINUATION_PROMPT)
+		if err != nil {
+			if err == errInterrupted {
+				return "", err
+			}
+			return strings.Join(lines, "\n"), err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// isIncomplete reports whether input ends inside a string or with unclosed
+// braces, brackets or parentheses.
+func isIncomplete(input string) bool {
+	depth := 0
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.STRING, token.INTERPOLATED:
+			if !isClosed(input, tok) {
+				return true
+			}
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
 		}
 	}
+	return depth > 0
+}
+
+// isClosed reports whether the string tok of input ends with a closing quote,
+// the lexer reading the strings left open up to the end of the input.
+func isClosed(input string, tok token.Token) bool {
+	offset := 0
+	for _, line := range strings.SplitAfter(input, "\n")[:tok.Line-1] {
+		offset += len(line)
+	}
+	// the literal follows the opening quote at the 1-based column of tok
+	end := offset + tok.Column + len(tok.Literal)
+	return end < len(input) && input[end] == '"'
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// printParseErrors prints each error with the line of input it is on and a
+// caret under its column.
+func (s *session) printParseErrors(input string, errors []parser.ParseError) {
+	s.printRed(" parser errors:")
+	io.WriteString(s.out, "\n")
+	for _, err := range errors {
+		s.printRed("\t" + err.Error())
+		io.WriteString(s.out, "\n")
+		if c := caret(input, err.Line, err.Column); c != "" {
+			io.WriteString(s.out, "\t"+strings.Replace(c, "\n", "\n\t", 1)+"\n")
+		}
 	}
 }
@@ -0,0 +1,194 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 5;", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n x + y\n};", false},
+		{"[1, 2,", true},
+		{"puts(", true},
+		{`"unterminated`, true},
+		{`"{"`, false},
+		{`"`, true},
+		{`""`, false},
+		{"\"a\nb", true},
+		{"let s = \"a\n\"; \"${s}", true},
+		{`"${"}"}"`, false},
+		{`1 // say "hi`, false},
+		{"1 // say \"hi\n\"", true},
+		{`puts("hi") // (`, false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	in := strings.NewReader("let add = fn(x, y) {\n  x + y\n};\nadd(1,\n 2)\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := ">>> ... ... >>> ... 3\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartComments(t *testing.T) {
+	in := strings.NewReader("1 // say \"hi\n2\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := ">>> 1\n>>> 2\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartProgramStreams(t *testing.T) {
+	in := strings.NewReader("puts(\"hi\");\nlet name = read_line();\nAda\nname\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := ">>> hi\nnull\n>>> >>> Ada\n>>> "
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartConcurrentSessions(t *testing.T) {
+	inputs := []string{
+		"puts(\"one\");\n:reset\nread_line()\nfirst\n",
+		"puts(\"two\");\n:reset\nread_line()\nsecond\n",
+	}
+	outs := make([]bytes.Buffer, len(inputs))
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		go func(in io.Reader, out *bytes.Buffer) {
+			defer wg.Done()
+			Start(in, out)
+		}(strings.NewReader(input), &outs[i])
+	}
+	wg.Wait()
+
+	for i, expected := range []string{">>> one\nnull\n>>> >>> first\n>>> ", ">>> two\nnull\n>>> >>> second\n>>> "} {
+		if outs[i].String() != expected {
+			t.Errorf("wrong output of session %d. expected=%q, got=%q", i, expected, outs[i].String())
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file := t.TempDir() + "/session.mg"
+	input := strings.Join([]string{
+		"let a = 5;",
+		`let s = "str";`,
+		":env",
+		":type a + 1",
+		":ast -a + 1",
+		":save " + file,
+		":reset",
+		"a",
+		":load " + file,
+		"a",
+		":nope",
+		":quit",
+		"a",
+	}, "\n")
+	var out bytes.Buffer
+
+	Start(strings.NewReader(input), &out)
+
+	expected := strings.Join([]string{
+		">>> >>> >>> a: INTEGER",
+		"s: STRING",
+		">>> INTEGER",
+		">>> Program",
+		"  ExpressionStatement",
+		"    InfixExpression +",
+		"      PrefixExpression -",
+		"        Identifier a",
+		"      IntegerLiteral 1",
+		">>> >>> >>> ERROR: identifier not found: a",
+		"a",
+		"^",
+		">>> >>> 5",
+		">>> unknown command :nope, type :help for the list of commands",
+		">>> ",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestSaveAndLoadSession(t *testing.T) {
+	file := t.TempDir() + "/session.json"
+	var out bytes.Buffer
+
+	Start(strings.NewReader(strings.Join([]string{
+		"let counter = fn() { let n = [1]; fn() { n } };",
+		"let f = counter();",
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };",
+		"let twice = macro(x) { quote(unquote(x) + unquote(x)) };",
+		"let size = len;",
+		":save-session " + file,
+	}, "\n")), &out)
+	out.Reset()
+	Start(strings.NewReader(strings.Join([]string{
+		":load-session " + file,
+		"fact(5)",
+		"f()",
+		"twice(2)",
+		`size("abc")`,
+		":env",
+	}, "\n")), &out)
+
+	expected := strings.Join([]string{
+		">>> >>> 120",
+		">>> [1]",
+		">>> 4",
+		">>> 3",
+		">>> twice: MACRO",
+		"counter: FUNCTION",
+		"f: FUNCTION",
+		"fact: FUNCTION",
+		"size: BUILTIN",
+		">>> ",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := t.TempDir() + "/history"
+	h := loadHistory(path)
+	h.add("let f = fn() {\n  // a comment\n  1\n};\n")
+	h.add(`match("\d+", "a\nb")`)
+	h.add(`match("\d+", "a\nb")`)
+
+	entries := loadHistory(path).entries
+	expected := []string{"let f = fn() {\n  // a comment\n  1\n};", `match("\d+", "a\nb")`}
+	if strings.Join(entries, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong entries. expected=%q, got=%q", expected, entries)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package repl
+
+import "errors"
+
+type terminalState struct{}
+
+// Line editing is only supported on Linux and macOS, other systems reading
+// input line by line.
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (*terminalState, error) {
+	return nil, errors.New("line editing is not supported on this system")
+}
+
+func restoreTerminal(fd int, state *terminalState) error { return nil }
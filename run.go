@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"magot/ast"
+	"magot/coverage"
+	"magot/evaluator"
+	"magot/lexer"
+	"magot/object"
+	"magot/optimizer"
+	"magot/parser"
+	"magot/profiler"
+	"magot/sandbox"
+	"os"
+	"strings"
+)
+
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	optimize := flags.Bool("optimize", true, "fold constants and remove dead branches before evaluation")
+	coverProfile := flags.String("coverprofile", "", "write a coverage report to the file, as HTML if it ends in .html and LCOV otherwise; disables -optimize")
+	cpuProfile := flags.String("cpuprofile", "", "write a pprof profile of the time and allocations of the Magot functions to the file")
+	grant := sandboxFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: magot run [flags] file.mg\n\n")
+		fmt.Fprintf(os.Stderr, "Runs a Magot program.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *coverProfile != "" && *cpuProfile != "" {
+		fmt.Fprintln(os.Stderr, "-coverprofile and -cpuprofile cannot be used together")
+		return 2
+	}
+	context, err := grant()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	program, err := loadProgram(flags.Arg(0), context)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var cov *coverage.Coverage
+	if *coverProfile != "" {
+		cov = coverage.New()
+		cov.Add(flags.Arg(0), program)
+		context.Tracer = cov
+	} else if *optimize {
+		program = optimizer.Optimize(program)
+	}
+	var prof *profiler.Profiler
+	if *cpuProfile != "" {
+		prof = profiler.New(flags.Arg(0), program)
+		context.Tracer = prof
+	}
+
+	env := object.NewEnvironment()
+	evaluator.SetContext(env, context)
+	evaluated := evaluator.Eval(program, env)
+	if prof != nil {
+		prof.Stop()
+		if err := writeCPUProfile(prof, *cpuProfile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if cov != nil {
+		for _, summary := range cov.Summaries() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", summary.File, summary)
+		}
+		if err := writeCoverProfile(cov, *coverProfile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", flags.Arg(0), errObj.Line, errObj.Column, errObj.Inspect())
+		return 1
+	}
+	return 0
+}
+
+// writeCoverProfile writes a coverage report to a file, in HTML when its name
+// ends in .html and in the LCOV format otherwise.
+func writeCoverProfile(cov *coverage.Coverage, file string) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(file, ".html") {
+		err = cov.WriteHTML(out)
+	} else {
+		err = cov.WriteLCOV(out)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeCPUProfile(prof *profiler.Profiler, file string) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = prof.WriteProfile(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// dirList is a flag value listing directories, given as repeated flags or
+// separated by commas.
+type dirList []string
+
+func (l *dirList) String() string { return strings.Join(*l, ",") }
+
+func (l *dirList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// sandboxFlags adds the -allow-read and -allow-write flags to flags, and
+// returns a function returning the context of the evaluations, which grants
+// the directories they list to the fs module, once they are parsed.
+func sandboxFlags(flags *flag.FlagSet) func() (*evaluator.Context, error) {
+	var read, write dirList
+	flags.Var(&read, "allow-read", "let the fs module read the files below the directories, separated by commas")
+	flags.Var(&write, "allow-write", "let the fs module write the files below the directories, separated by commas")
+	return func() (*evaluator.Context, error) {
+		s := sandbox.New()
+		for access, dirs := range map[sandbox.Access]dirList{sandbox.READ: read, sandbox.WRITE: write} {
+			for _, dir := range dirs {
+				if err := s.Allow(access, dir); err != nil {
+					return nil, fmt.Errorf("-allow-%s: %s", access, err)
+				}
+			}
+		}
+		return &evaluator.Context{Files: s}, nil
+	}
+}
+
+// loadProgram parses a source file and expands its macros, evaluating them
+// with the context given.
+func loadProgram(file string, context *evaluator.Context) (*ast.Program, error) {
+	source, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		err := p.ParseErrors()[0]
+		return nil, fmt.Errorf("%s:%s", file, err.Error())
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.SetContext(macroEnv, context)
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return expanded.(*ast.Program), nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package sandbox
+
+// Opening a file without following a symbolic link is only supported on
+// Linux and macOS, other systems relying on Check alone.
+const noFollow = 0
@@ -0,0 +1,7 @@
+//go:build linux || darwin
+
+package sandbox
+
+import "syscall"
+
+const noFollow = syscall.O_NOFOLLOW
@@ -0,0 +1,133 @@
+// Package sandbox limits the files that Magot programs access to the
+// directories granted to them, for reading and for writing separately.
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Access is a kind of file access.
+type Access string
+
+const (
+	READ  Access = "read"
+	WRITE Access = "write"
+)
+
+// Sandbox lists the granted directories, as absolute paths without symbolic
+// links, so that paths cannot escape them through links or "..". The zero
+// value grants nothing.
+type Sandbox struct {
+	dirs map[Access][]string
+}
+
+func New() *Sandbox {
+	return &Sandbox{dirs: make(map[Access][]string)}
+}
+
+// Allow grants an access to a directory, which must exist, and to all the
+// files below it.
+func (s *Sandbox) Allow(access Access, dir string) error {
+	resolved, err := resolve(dir)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if s.dirs == nil {
+		s.dirs = make(map[Access][]string)
+	}
+	s.dirs[access] = append(s.dirs[access], resolved)
+	return nil
+}
+
+// Dirs returns the directories granted an access.
+func (s *Sandbox) Dirs(access Access) []string {
+	return append([]string{}, s.dirs[access]...)
+}
+
+// Check returns the resolved path of a file if the access to it is granted,
+// and an error otherwise. Relative paths are relative to the working
+// directory.
+func (s *Sandbox) Check(access Access, path string) (string, error) {
+	resolved, err := resolve(path)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range s.dirs[access] {
+		if rel, err := filepath.Rel(dir, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s access to %s denied, it is outside the directories granted for it", access, path)
+}
+
+// OpenFile opens a file at a path returned by Check like os.OpenFile, failing
+// rather than following a symbolic link that replaced the file since.
+func OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(path, flag|noFollow, perm)
+}
+
+// maxLinks is the number of symbolic links resolve follows before giving up,
+// as filepath.EvalSymlinks does.
+const maxLinks = 255
+
+// resolve returns the absolute path of a file, with the symbolic links of the
+// part of it that exists followed, including the dangling ones, which the
+// files created at the path would be created through.
+func resolve(path string) (string, error) {
+	links := 0
+	return resolveLinks(path, &links)
+}
+
+func resolveLinks(path string, links *int) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	parent := filepath.Dir(abs)
+	if parent == abs {
+		return abs, nil
+	}
+	resolvedParent, err := resolveLinks(parent, links)
+	if err != nil {
+		return "", err
+	}
+	resolved = filepath.Join(resolvedParent, filepath.Base(abs))
+	info, err := os.Lstat(resolved)
+	if os.IsNotExist(err) {
+		return resolved, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return resolved, nil
+	}
+	if *links++; *links > maxLinks {
+		return "", fmt.Errorf("%s: too many links", path)
+	}
+	target, err := os.Readlink(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(resolvedParent, target)
+	}
+	return resolveLinks(target, links)
+}
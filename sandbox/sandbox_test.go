@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"data/sub", "out", "secret"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"data/link":      filepath.Join(root, "secret"),
+		"out/escape":     filepath.Join(root, "secret", "pwned"),
+		"out/relative":   "../secret/pwned",
+		"out/dangling":   "new.txt",
+		"out/missing":    filepath.Join(root, "secret", "missing", "dir"),
+		"out/loop":       "loop",
+		"out/chain":      "escape",
+		"data/sub/inner": "../../out/escape",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := New()
+	if err := s.Allow(READ, filepath.Join(root, "data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Allow(WRITE, filepath.Join(root, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Allow(READ, filepath.Join(root, "missing")); err == nil {
+		t.Errorf("expected an error granting a missing directory")
+	}
+
+	tests := []struct {
+		access  Access
+		path    string
+		allowed bool
+	}{
+		{READ, "data", true},
+		{READ, "data/sub/new.txt", true},
+		{READ, "data/sub/../../out/a.txt", false},
+		{READ, "data/link/key", false},
+		{READ, "datafile", false},
+		{READ, "out/a.txt", false},
+		{WRITE, "out/a.txt", true},
+		{WRITE, "out/new/dir/a.txt", true},
+		{WRITE, "data/a.txt", false},
+		{WRITE, "out/escape", false},
+		{WRITE, "out/relative", false},
+		{WRITE, "out/dangling", true},
+		{WRITE, "out/missing/a.txt", false},
+		{WRITE, "out/loop", false},
+		{WRITE, "out/chain", false},
+		{READ, "data/sub/inner", false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(root, tt.path)
+		_, err := s.Check(tt.access, path)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("wrong %s access to %s. expected allowed=%t, got error %v", tt.access, tt.path, tt.allowed, err)
+		}
+	}
+
+	resolved, err := s.Check(WRITE, filepath.Join(root, "out/dangling"))
+	if err != nil || resolved != filepath.Join(root, "out/new.txt") {
+		t.Errorf("expected the dangling link to resolve to its target, got %q and %v", resolved, err)
+	}
+
+	if _, err := New().Check(READ, root); err == nil {
+		t.Errorf("expected a new sandbox to grant nothing")
+	}
+	if err := os.WriteFile(filepath.Join(root, "out/a.txt"), []byte("a"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	path, err := s.Check(WRITE, filepath.Join(root, "out/a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "secret", "swapped"), path); err != nil {
+		t.Fatal(err)
+	}
+	if file, err := OpenFile(path, os.O_WRONLY|os.O_CREATE, 0666); err == nil && noFollow != 0 {
+		file.Close()
+		t.Errorf("expected OpenFile not to follow a link replacing the file checked")
+	}
+
+	var zero Sandbox
+	if err := zero.Allow(READ, root); err != nil {
+		t.Errorf("Allow on the zero sandbox: %s", err)
+	}
+}
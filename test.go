@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"magot/coverage"
+	"magot/evaluator"
+	"magot/tester"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	run := flags.String("run", "", "run only the tests whose name matches the regular expression")
+	verbose := flags.Bool("v", false, "print the name and result of every test")
+	cover := flags.Bool("cover", false, "print the statement and branch coverage of every file")
+	coverProfile := flags.String("coverprofile", "", "write a coverage report to the file, as HTML if it ends in .html and LCOV otherwise; implies -cover")
+	grant := sandboxFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: magot test [flags] [file.mg or directory...]\n\n")
+		fmt.Fprintf(os.Stderr, "Runs the test_* functions of *_test.mg files, searching the current\n")
+		fmt.Fprintf(os.Stderr, "directory when none is given.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	context, err := grant()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	filter, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -run: %s\n", err)
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var cov *coverage.Coverage
+	if *cover || *coverProfile != "" {
+		cov = coverage.New()
+		context.Tracer = cov
+	}
+
+	failed := false
+	for _, file := range files {
+		if !testFile(file, filter, *verbose, context, cov) {
+			failed = true
+		}
+	}
+	if *coverProfile != "" {
+		if err := writeCoverProfile(cov, *coverProfile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// testFiles returns the files given and the *_test.mg files found in the
+// directories given.
+func testFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(file, "_test.mg") {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// testFile runs the tests of a file matching filter with the context given
+// and prints their results, reporting whether they all passed. The coverage
+// of the file is recorded in cov unless it is nil.
+func testFile(file string, filter *regexp.Regexp, verbose bool, context *evaluator.Context, cov *coverage.Coverage) bool {
+	start := time.Now()
+	program, err := loadProgram(file, context)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Printf("FAIL\t%s\t[setup failed]\n", file)
+		return false
+	}
+	if cov != nil {
+		cov.Add(file, program)
+	}
+
+	passed, ran := true, 0
+	for _, test := range tester.Discover(program) {
+		if !filter.MatchString(test.Name) {
+			continue
+		}
+		ran++
+		if verbose {
+			fmt.Printf("=== RUN   %s\n", test.Name)
+		}
+		result := tester.Run(program, test, context)
+		elapsed := result.Elapsed.Seconds()
+		if result.Passed() {
+			if verbose {
+				fmt.Printf("--- PASS: %s (%.2fs)\n", test.Name, elapsed)
+			}
+			continue
+		}
+		passed = false
+		fmt.Printf("--- FAIL: %s (%.2fs)\n", test.Name, elapsed)
+		fmt.Printf("    %s:%d:%d: %s\n", file, result.Error.Line, result.Error.Column, result.Error.Message)
+	}
+
+	status := fmt.Sprintf("%.3fs", time.Since(start).Seconds())
+	if cov != nil {
+		status += "\t" + cov.Summary(file).String()
+	}
+	switch {
+	case ran == 0:
+		fmt.Printf("?   \t%s\t[no tests to run]\n", file)
+	case passed:
+		fmt.Printf("ok  \t%s\t%s\n", file, status)
+	default:
+		fmt.Printf("FAIL\t%s\t%s\n", file, status)
+	}
+	return passed
+}
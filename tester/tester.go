@@ -0,0 +1,88 @@
+// Package tester runs the tests written in Magot: the functions bound by the
+// top-level let statements of a program whose names start with "test_".
+package tester
+
+import (
+	"magot/ast"
+	"magot/evaluator"
+	"magot/object"
+	"strings"
+	"time"
+)
+
+// PREFIX starts the names of the test functions.
+const PREFIX = "test_"
+
+// Test is a test function of a program.
+type Test struct {
+	Name   string
+	Line   int
+	Column int
+}
+
+// Result is the outcome of running a test, Error being nil when it passed.
+type Result struct {
+	Test
+	Error   *object.Error
+	Elapsed time.Duration
+}
+
+func (r Result) Passed() bool {
+	return r.Error == nil
+}
+
+// Discover returns the tests of a program in source order.
+func Discover(program *ast.Program) []Test {
+	tests := []Test{}
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, PREFIX) {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); !ok {
+			continue
+		}
+		tests = append(tests, Test{Name: let.Name.Value, Line: let.Name.Token.Line, Column: let.Name.Token.Column})
+	}
+	return tests
+}
+
+// Run evaluates the program in a new environment with the context given, so
+// that tests do not see each other's changes, then calls the test function
+// without arguments. The test fails when either returns an error.
+func Run(program *ast.Program, test Test, context *evaluator.Context) Result {
+	start := time.Now()
+	result := Result{Test: test}
+	result.Error = run(program, test, context)
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+func run(program *ast.Program, test Test, context *evaluator.Context) *object.Error {
+	env := object.NewEnvironment()
+	evaluator.SetContext(env, context)
+	if err, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		return err
+	}
+
+	fn, ok := env.Get(test.Name)
+	if !ok {
+		return &object.Error{Message: "test function not found: " + test.Name, Line: test.Line, Column: test.Column}
+	}
+	function, ok := fn.(*object.Function)
+	if !ok || len(function.Parameters) != 0 {
+		return &object.Error{Message: "test functions take no arguments", Line: test.Line, Column: test.Column}
+	}
+	// the call is evaluated as a program for its goroutines to be told
+	// deadlocked
+	call := &ast.CallExpression{Function: &ast.Identifier{Value: test.Name}}
+	calling := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: call}}}
+	err, ok := evaluator.Eval(calling, env).(*object.Error)
+	if !ok {
+		return nil
+	}
+	if err.Line == 0 {
+		err.Line, err.Column = test.Line, test.Column
+	}
+	return err
+}
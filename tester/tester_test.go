@@ -0,0 +1,93 @@
+package tester
+
+import (
+	"magot/ast"
+	"magot/lexer"
+	"magot/parser"
+	"testing"
+)
+
+const source = `let calls = [];
+let add = fn(a, b) { a + b };
+
+let test_add = fn() {
+  assert_eq(add(1, 2), 3);
+};
+
+let test_fail = fn() {
+  assert_eq(add(1, 2), 4);
+};
+let test_value = 5;
+let helper_test = fn() { 1 };
+
+let test_params = fn(x) { x };
+let test_runtime = fn() {
+  add(1, true)
+};
+let test_deadlock = fn() { send(channel(), 1) };
+`
+
+func TestDiscover(t *testing.T) {
+	tests := Discover(parse(t, source))
+
+	expected := []Test{
+		{Name: "test_add", Line: 4, Column: 5},
+		{Name: "test_fail", Line: 8, Column: 5},
+		{Name: "test_params", Line: 14, Column: 5},
+		{Name: "test_runtime", Line: 15, Column: 5},
+		{Name: "test_deadlock", Line: 18, Column: 5},
+	}
+	if len(tests) != len(expected) {
+		t.Fatalf("wrong number of tests. expected=%d, got=%d (%+v)", len(expected), len(tests), tests)
+	}
+	for i, test := range tests {
+		if test != expected[i] {
+			t.Errorf("wrong test %d. expected=%+v, got=%+v", i, expected[i], test)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	program := parse(t, source)
+	tests := []struct {
+		name    string
+		message string
+		line    int
+		column  int
+	}{
+		{"test_add", "", 0, 0},
+		{"test_fail", "assertion failed: expected 4, got 3", 9, 3},
+		{"test_params", "test functions take no arguments", 14, 5},
+		{"test_runtime", "type mismatch: INTEGER + BOOLEAN", 2, 24},
+		{"test_deadlock", "all goroutines are asleep - deadlock", 18, 28},
+	}
+
+	for i, test := range Discover(program) {
+		result := Run(program, test, nil)
+		tt := tests[i]
+		if tt.message == "" {
+			if !result.Passed() {
+				t.Errorf("%s failed: %s", tt.name, result.Error.Message)
+			}
+			continue
+		}
+		if result.Passed() {
+			t.Errorf("%s passed", tt.name)
+			continue
+		}
+		if result.Error.Message != tt.message || result.Error.Line != tt.line || result.Error.Column != tt.column {
+			t.Errorf("wrong error for %s. expected=%d:%d: %s, got=%d:%d: %s", tt.name,
+				tt.line, tt.column, tt.message, result.Error.Line, result.Error.Column, result.Error.Message)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
@@ -1,10 +1,17 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Line    int       `json:"line"`   // 1-based line of the token's first character
+	Column  int       `json:"column"` // 1-based column of the token's first character
+	// Doc is the text of the comment lines right above the token, without
+	// their // markers, which documents the let statements.
+	Doc string `json:"doc,omitempty"`
 }
 
 const (
@@ -19,10 +26,20 @@ const (
 	RETURN   = "return"
 	TRUE     = "true"
 	FALSE    = "false"
+	MACRO    = "MACRO"
+	SPAWN    = "SPAWN"
+	AWAIT    = "AWAIT"
+	SELECT   = "SELECT"
+	FOR      = "FOR"
+	IN       = "IN"
+	YIELD    = "YIELD"
 
 	IDENT  = "IDENT" // Identifier
 	INT    = "INT"   // Literal
 	STRING = "STRING"
+	// INTERPOLATED is a string holding ${expression} interpolations, or $$
+	// standing for $ before a brace.
+	INTERPOLATED = "INTERPOLATED"
 
 	// Operators
 	ASSIGN = "="
@@ -30,6 +47,7 @@ const (
 	MINUS  = "-"
 	MUL    = "*"
 	DIV    = "/"
+	MOD    = "%"
 	BANG   = "!"
 	EQ     = "=="
 	NEQ    = "!="
@@ -37,6 +55,9 @@ const (
 	LT = "<"
 	GT = ">"
 
+	ARROW    = "=>"
+	ELLIPSIS = "..."
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -58,6 +79,13 @@ var keywords map[string]TokenType = map[string]TokenType{
 	"return": RETURN,
 	"true":   TRUE,
 	"false":  FALSE,
+	"macro":  MACRO,
+	"spawn":  SPAWN,
+	"await":  AWAIT,
+	"select": SELECT,
+	"for":    FOR,
+	"in":     IN,
+	"yield":  YIELD,
 }
 
 func LookupTokenType(literal string) TokenType {
@@ -66,3 +94,13 @@ func LookupTokenType(literal string) TokenType {
 	}
 	return IDENT
 }
+
+// Keywords returns the reserved words of the language, sorted.
+func Keywords() []string {
+	words := []string{}
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}